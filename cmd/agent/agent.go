@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/client/sender"
+	"github.com/ilya372317/must-have-metrics/internal/client/spool"
+	"github.com/ilya372317/must-have-metrics/internal/client/statistic"
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+)
+
+const configCheckInterval = 5 * time.Second
+
+// importBatchSize count of metrics sent by one request on import.
+const importBatchSize = statistic.ChunkForRequestSize
+
+// startedAt time, when agent process was started. It is reported to server in agent identity.
+var startedAt = time.Now()
+
+// agent running monitor built from agent config.
+type agent struct {
+	monitor      *statistic.Monitor
+	cancel       context.CancelFunc
+	wg           *sync.WaitGroup
+	cnfg         *config.AgentConfig
+	destinations []statistic.Destination
+}
+
+// startAgent build monitor from config and run it until context is done or agent is stopped.
+// State of previous monitor, if given, is inherited by new one.
+func startAgent(ctx context.Context, cnfg *config.AgentConfig, previous *statistic.Monitor) (*agent, error) {
+	labeler, err := statistic.NewLabeler(cnfg.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed create labeler: %w", err)
+	}
+	pipeline, err := statistic.NewPipeline(cnfg.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metrics rules: %w", err)
+	}
+	var aggregator *statistic.Aggregator
+	if cnfg.Aggregation.IsEnabled() {
+		aggregator, err = statistic.NewAggregator(cnfg.Aggregation)
+		if err != nil {
+			return nil, fmt.Errorf("failed create aggregator: %w", err)
+		}
+	}
+	destinations, err := newDestinations(cnfg)
+	if err != nil {
+		return nil, err
+	}
+	// Collectors and health endpoint are created last, because they listen addresses.
+	var healthServer *statistic.HealthServer
+	if cnfg.HealthAddress != "" {
+		healthServer, err = statistic.NewHealthServer(cnfg.HealthAddress)
+		if err != nil {
+			closeSenders(destinations)
+			return nil, fmt.Errorf("failed create health endpoint: %w", err)
+		}
+	}
+	collectors, err := statistic.NewCollectors(cnfg)
+	if err != nil {
+		if healthServer != nil {
+			if closeErr := healthServer.Close(); closeErr != nil {
+				logger.Log.Warn(closeErr)
+			}
+		}
+		closeSenders(destinations)
+		return nil, fmt.Errorf("failed create collectors: %w", err)
+	}
+
+	monitor := statistic.New(cnfg.RateLimit, collectors...)
+	monitor.SetMemStats(cnfg.RuntimeSource != config.RuntimeSourceRuntime)
+	monitor.SetLabeler(labeler)
+	monitor.SetPipeline(pipeline)
+	if aggregator != nil {
+		monitor.SetAggregator(aggregator)
+	}
+	if cnfg.DeltaReport {
+		monitor.SetDeltaReport(time.Duration(cnfg.FullResync) * time.Second)
+	}
+	monitor.SetDestinations(destinations...)
+	if previous != nil {
+		monitor.Inherit(previous)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	wg := &sync.WaitGroup{}
+	if healthServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			healthServer.Run(runCtx, monitor)
+		}()
+	}
+	wg.Add(1)
+	go monitor.CollectStat(runCtx, wg, time.Duration(cnfg.PollInterval)*time.Second)
+	wg.Add(1)
+	go monitor.ReportStat(runCtx, wg, time.Duration(cnfg.ReportInterval)*time.Second)
+
+	return &agent{monitor: monitor, cancel: cancel, wg: wg, cnfg: cnfg, destinations: destinations}, nil
+}
+
+// newDestinations create destinations of reports with own senders and spools.
+// In failover mode all senders are combined into single destination.
+// If output is configured, reports are only written to stdout or file.
+func newDestinations(cnfg *config.AgentConfig) ([]statistic.Destination, error) {
+	if cnfg.ShouldWriteOutput() {
+		outputSender, err := sender.NewOutputSender(cnfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed create output: %w", err)
+		}
+		return []statistic.Destination{{Sender: outputSender}}, nil
+	}
+	return newRemoteDestinations(cnfg)
+}
+
+// newRemoteDestinations create destinations of reports on servers, output is not used.
+func newRemoteDestinations(cnfg *config.AgentConfig) ([]statistic.Destination, error) {
+	destinationConfigs := cnfg.DestinationList()
+	identity := newIdentity(cnfg)
+	senders := make([]sender.ReportSender, 0, len(destinationConfigs))
+	names := make(map[string]struct{}, len(destinationConfigs))
+	for _, destinationConfig := range destinationConfigs {
+		if _, ok := names[destinationConfig.Name]; ok {
+			return nil, fmt.Errorf("duplicate destination name %q", destinationConfig.Name)
+		}
+		names[destinationConfig.Name] = struct{}{}
+		reportSender, err := sender.NewDestinationSender(cnfg, destinationConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed create sender: %w", err)
+		}
+		reportSender.SetIdentity(identity)
+		senders = append(senders, reportSender)
+	}
+
+	switch cnfg.DestinationMode {
+	case config.DestinationModeFailover:
+		destination := statistic.Destination{Sender: sender.NewFailover(senders...)}
+		if err := setSpool(cnfg, &destination, 1); err != nil {
+			return nil, err
+		}
+		return []statistic.Destination{destination}, nil
+	case "", config.DestinationModeBroadcast:
+		destinations := make([]statistic.Destination, 0, len(senders))
+		for i, destinationConfig := range destinationConfigs {
+			destination := statistic.Destination{Name: destinationConfig.Name, Sender: senders[i]}
+			if err := setSpool(cnfg, &destination, len(destinationConfigs)); err != nil {
+				return nil, err
+			}
+			destinations = append(destinations, destination)
+		}
+		return destinations, nil
+	default:
+		return nil, fmt.Errorf("unknown destination mode %q", cnfg.DestinationMode)
+	}
+}
+
+// newIdentity create identity, which agent sends to server with every batch.
+func newIdentity(cnfg *config.AgentConfig) dto.AgentIdentity {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.Log.Warnf("failed get hostname: %v", err)
+	}
+	return dto.AgentIdentity{
+		Started:        startedAt,
+		ID:             cnfg.ID(),
+		Hostname:       hostname,
+		Version:        buildVersion,
+		ReportInterval: cnfg.ReportInterval,
+	}
+}
+
+// setSpool open spool of one of count destinations. If spool is not configured, only spool left
+// by previous shutdown with metrics to send is opened, so they are sent before new reports.
+// Such spool is used until agent is stopped.
+func setSpool(cnfg *config.AgentConfig, destination *statistic.Destination, count int) error {
+	dir := destinationSpoolDir(cnfg.ShutdownSpoolDir(), destination.Name, count)
+	if !cnfg.ShouldSpoolData() {
+		if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	}
+	metricsSpool, err := spool.New(dir, int64(cnfg.SpoolMaxSize), statistic.ChunkForRequestSize)
+	if err != nil {
+		return fmt.Errorf("failed open spool: %w", err)
+	}
+	if cnfg.ShouldSpoolData() || metricsSpool.Len() > 0 {
+		destination.Spool = metricsSpool
+	}
+	return nil
+}
+
+// destinationSpoolDir return spool dir of destination. Each of several destinations has own subdirectory.
+func destinationSpoolDir(dir string, name string, count int) string {
+	if count > 1 {
+		return filepath.Join(dir, name)
+	}
+	return dir
+}
+
+// stop monitor and wait until started reports are finished.
+func (a *agent) stop() {
+	a.cancel()
+	a.wg.Wait()
+	closeSenders(a.destinations)
+}
+
+// shutdown stop agent, collect metrics once more and send final report within shutdown timeout.
+// Reports in flight are cancelled, when timeout expires, and their metrics are sent by final report.
+// Metrics failed to send are kept in spools and sent on next start, even if spool is not configured.
+func (a *agent) shutdown() {
+	if a.cnfg.ShutdownTimeout == 0 {
+		a.stop()
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.cnfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+	stopCancelReports := context.AfterFunc(ctx, a.monitor.CancelReports)
+	defer stopCancelReports()
+	a.cancel()
+	a.wg.Wait()
+	a.setShutdownSpools()
+	a.monitor.Flush(ctx)
+	closeSenders(a.destinations)
+}
+
+// setShutdownSpools open spools in shutdown spool dir for server destinations without spool.
+func (a *agent) setShutdownSpools() {
+	if a.cnfg.ShouldWriteOutput() {
+		return
+	}
+	for i := range a.destinations {
+		if a.destinations[i].Spool != nil {
+			continue
+		}
+		dir := destinationSpoolDir(a.cnfg.ShutdownSpoolDir(), a.destinations[i].Name, len(a.destinations))
+		metricsSpool, err := spool.New(dir, int64(a.cnfg.SpoolMaxSize), statistic.ChunkForRequestSize)
+		if err != nil {
+			logger.Log.Warnf("failed open shutdown spool: %v", err)
+			continue
+		}
+		a.destinations[i].Spool = metricsSpool
+	}
+	a.monitor.SetDestinations(a.destinations...)
+}
+
+// closeSenders close senders, which hold files.
+func closeSenders(destinations []statistic.Destination) {
+	for _, destination := range destinations {
+		if closer, ok := destination.Sender.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				logger.Log.Warnf("failed close sender: %v", err)
+			}
+		}
+	}
+}
+
+// importReports send metrics from output file in json format to every server destination.
+// Configured output is ignored, so imported metrics are not written back to it.
+// Spools are not used, failed import can be repeated.
+func importReports(ctx context.Context, cnfg *config.AgentConfig) error {
+	destinations, err := newRemoteDestinations(cnfg)
+	if err != nil {
+		return err
+	}
+	defer closeSenders(destinations)
+	for _, destination := range destinations {
+		if err = importTo(ctx, cnfg.ImportPath, destination); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importTo(ctx context.Context, path string, destination statistic.Destination) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed open import file: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			logger.Log.Warnf("failed close import file: %v", closeErr)
+		}
+	}()
+	sent, err := sender.Replay(ctx, file, destination.Sender, importBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed import %s after %d metrics: %w", path, sent, err)
+	}
+	logger.Log.Infof("imported %d metrics from %s%s", sent, path, destinationSuffix(destination))
+	return nil
+}
+
+func destinationSuffix(destination statistic.Destination) string {
+	if destination.Name == "" {
+		return ""
+	}
+	return " to " + destination.Name
+}
+
+// watchReload notify about SIGHUP and changes of config file.
+func watchReload(ctx context.Context, configPath string) <-chan struct{} {
+	reload := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case reload <- struct{}{}:
+		default:
+		}
+	}
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hangup)
+		ticker := time.NewTicker(configCheckInterval)
+		defer ticker.Stop()
+		modTime := configModTime(configPath)
+		for {
+			select {
+			case <-hangup:
+				logger.Log.Info("SIGHUP received, reload config")
+				notify()
+			case <-ticker.C:
+				if configPath == "" {
+					continue
+				}
+				current := configModTime(configPath)
+				if !current.Equal(modTime) {
+					modTime = current
+					logger.Log.Infof("config file %s changed, reload config", configPath)
+					notify()
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return reload
+}
+
+func configModTime(configPath string) time.Time {
+	if configPath == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/client/sender"
+	"github.com/ilya372317/must-have-metrics/internal/client/spool"
+	"github.com/ilya372317/must-have-metrics/internal/client/statistic"
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent_shutdownWithoutSpool(t *testing.T) {
+	require.NoError(t, logger.Init())
+	t.Setenv("TMPDIR", t.TempDir())
+	cnfg := &config.AgentConfig{AgentID: "web-1", ShutdownTimeout: 1}
+	require.False(t, cnfg.ShouldSpoolData())
+
+	restarted := statistic.Destination{Sender: sender.ReportSenderFunc(func(context.Context, string) error {
+		return nil
+	})}
+	require.NoError(t, setSpool(cnfg, &restarted, 1))
+	assert.Nil(t, restarted.Spool, "spool is not opened without metrics left by shutdown")
+	_, err := os.Stat(cnfg.ShutdownSpoolDir())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	monitor := statistic.New(1)
+	destinations := []statistic.Destination{{Sender: sender.ReportSenderFunc(func(context.Context, string) error {
+		return errors.New("server is down")
+	})}}
+	monitor.SetDestinations(destinations...)
+	_, cancel := context.WithCancel(context.Background())
+	running := &agent{monitor: monitor, cancel: cancel, wg: &sync.WaitGroup{}, cnfg: cnfg, destinations: destinations}
+	running.shutdown()
+
+	shutdownSpool, err := spool.New(cnfg.ShutdownSpoolDir(), 0, statistic.ChunkForRequestSize)
+	require.NoError(t, err)
+	assert.Equal(t, 1, shutdownSpool.Len(), "final report failed to send is kept")
+
+	require.NoError(t, setSpool(cnfg, &restarted, 1))
+	require.NotNil(t, restarted.Spool, "metrics left by shutdown are sent on next start")
+	assert.Equal(t, 1, restarted.Spool.Len())
+}
+
+func TestAgent_shutdownDeadline(t *testing.T) {
+	require.NoError(t, logger.Init())
+	t.Setenv("TMPDIR", t.TempDir())
+	requests := &atomic.Int64{}
+	ts := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		writer.Header().Set("Retry-After", "5")
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	cnfg := &config.AgentConfig{
+		AgentID:         "web-1",
+		RateLimit:       1,
+		RequestTimeout:  5,
+		ShutdownTimeout: 1,
+		RetryCount:      100,
+	}
+	reportSender, err := sender.NewDestinationSender(cnfg,
+		config.DestinationConfig{Address: strings.TrimPrefix(ts.URL, "http://")})
+	require.NoError(t, err)
+
+	monitor := statistic.New(cnfg.RateLimit)
+	destinations := []statistic.Destination{{Sender: reportSender}}
+	monitor.SetDestinations(destinations...)
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	go monitor.CollectStat(ctx, wg, 10*time.Millisecond)
+	go monitor.ReportStat(ctx, wg, 20*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return requests.Load() > 0
+	}, 5*time.Second, 10*time.Millisecond, "report is in flight and waits for retry")
+
+	running := &agent{monitor: monitor, cancel: cancel, wg: wg, cnfg: cnfg, destinations: destinations}
+	start := time.Now()
+	running.shutdown()
+	assert.Less(t, time.Since(start), 3*time.Second, "in flight reports are cancelled at shutdown deadline")
+
+	shutdownSpool, err := spool.New(cnfg.ShutdownSpoolDir(), 0, statistic.ChunkForRequestSize)
+	require.NoError(t, err)
+	assert.Positive(t, shutdownSpool.Len(), "undelivered metrics are kept")
+}
@@ -5,12 +5,8 @@ import (
 	"context"
 	"fmt"
 	"os/signal"
-	"sync"
 	"syscall"
-	"time"
 
-	"github.com/ilya372317/must-have-metrics/internal/client/sender"
-	"github.com/ilya372317/must-have-metrics/internal/client/statistic"
 	"github.com/ilya372317/must-have-metrics/internal/config"
 	"github.com/ilya372317/must-have-metrics/internal/logger"
 	"github.com/ilya372317/must-have-metrics/internal/utils"
@@ -32,29 +28,72 @@ func main() {
 	if err := godotenv.Load(utils.Root + "/.env-agent"); err != nil {
 		logger.Log.Warnf("failed load .env-agent file: %v", err)
 	}
-	cnfg, err := config.NewAgent()
+	configLoader := config.NewAgentLoader()
+	cnfg, err := configLoader.Load()
 	if err != nil {
 		logger.Log.Panicf("failed get config: %v", err)
 	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	defer stop()
-	monitor := statistic.New(cnfg.RateLimit)
-	wg := &sync.WaitGroup{}
-	wg.Add(1)
-	go monitor.CollectStat(ctx, wg, time.Duration(cnfg.PollInterval)*time.Second)
-	wg.Add(1)
-	go monitor.ReportStat(
-		ctx,
-		wg,
-		cnfg,
-		time.Duration(cnfg.ReportInterval)*time.Second,
-		sender.SendReport,
-	)
+	if cnfg.ImportPath != "" {
+		if err = importReports(ctx, cnfg); err != nil {
+			stop()
+			logger.Log.Fatalf("failed import reports: %v", err)
+		}
+		return
+	}
+	remote := newRemoteSettings(cnfg)
+	if remote != nil {
+		if _, err = remote.refresh(ctx); err != nil {
+			logger.Log.Warnf("failed get agent settings from server, use local config: %v", err)
+		}
+	}
+	running, err := startAgent(ctx, remote.apply(cnfg), nil)
+	if err != nil && remote != nil {
+		logger.Log.Errorf("failed apply agent settings from server, use local config: %v", err)
+		remote.reset()
+		running, err = startAgent(ctx, cnfg, nil)
+	}
+	if err != nil {
+		logger.Log.Panicf("failed start agent: %v", err)
+	}
+	current := remote.apply(cnfg)
 	fmt.Println(
 		"Build version: ", buildVersion, "\n",
 		"Build date: ", buildDate, "\n",
 		"Build commit: ", buildCommit,
 	)
-	wg.Wait()
+
+	reload := watchReload(ctx, cnfg.ConfigPath)
+	remoteChanged := remote.watch(ctx)
+	for {
+		select {
+		case <-reload:
+		case <-remoteChanged:
+		case <-ctx.Done():
+			running.shutdown()
+			logger.Log.Info("agent was gracefully shutdown.")
+			return
+		}
+
+		local, err := configLoader.Load()
+		if err != nil {
+			logger.Log.Errorf("failed reload config, keep previous one: %v", err)
+			continue
+		}
+		reloaded := remote.apply(local)
+		running.stop()
+		next, err := startAgent(ctx, reloaded, running.monitor)
+		if err != nil {
+			logger.Log.Errorf("failed apply reloaded config, keep previous one: %v", err)
+			if next, err = startAgent(ctx, current, running.monitor); err != nil {
+				logger.Log.Panicf("failed restart agent: %v", err)
+			}
+		} else {
+			current = reloaded
+			logger.Log.Info("agent config is reloaded")
+		}
+		running = next
+	}
 }
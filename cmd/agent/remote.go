@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/client/remoteconfig"
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+)
+
+// remoteSettings last agent settings received from server.
+// While server is unreachable, last received settings are kept. Agent uses only local config,
+// if server was unreachable on start or does not serve settings.
+type remoteSettings struct {
+	fetcher  *remoteconfig.Fetcher
+	settings *config.RemoteAgentConfig
+	raw      []byte
+	interval time.Duration
+	mu       sync.Mutex
+}
+
+// newRemoteSettings create settings polled from server. Returns nil, if polling is disabled.
+// Address of server and polling interval are taken from config on start only.
+func newRemoteSettings(cnfg *config.AgentConfig) *remoteSettings {
+	if !cnfg.ShouldPollRemoteConfig() {
+		return nil
+	}
+	return &remoteSettings{
+		fetcher:  remoteconfig.NewFetcher(cnfg),
+		interval: time.Duration(cnfg.RemoteConfig) * time.Second,
+	}
+}
+
+// refresh request settings from server. Returns true, if settings are changed.
+func (r *remoteSettings) refresh(ctx context.Context) (bool, error) {
+	raw, settings, err := r.fetcher.Fetch(ctx)
+	if errors.Is(err, remoteconfig.ErrNotServed) {
+		raw, settings, err = nil, nil, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if bytes.Equal(raw, r.raw) {
+		return false, nil
+	}
+	r.raw = raw
+	r.settings = settings
+	return true, nil
+}
+
+// apply return local config with settings received from server.
+func (r *remoteSettings) apply(cnfg *config.AgentConfig) *config.AgentConfig {
+	if r == nil {
+		return cnfg
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return cnfg.ApplyRemote(r.settings)
+}
+
+// reset forget received settings, so local config is used until settings are changed on server.
+func (r *remoteSettings) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.settings = nil
+}
+
+// watch poll server by interval and notify about changed settings.
+func (r *remoteSettings) watch(ctx context.Context) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+	if r == nil {
+		return changed
+	}
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ok, err := r.refresh(ctx)
+				if err != nil {
+					logger.Log.Warnf("failed poll agent settings from server, keep current settings: %v", err)
+					continue
+				}
+				if ok {
+					logger.Log.Info("agent settings are changed on server, reload config")
+					select {
+					case changed <- struct{}{}:
+					default:
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return changed
+}
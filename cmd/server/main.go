@@ -84,6 +84,16 @@ func run() error {
 		if err = service.FillFromFilesystem(ctx, repository, cnfg.FilePath); err != nil {
 			logger.Log.Warn(err)
 		}
+		if !cnfg.ShouldConnectToDatabase() {
+			err = service.FillAgentsFromFilesystem(ctx, repository, service.AgentsFilePath(cnfg.FilePath))
+			if err != nil {
+				logger.Log.Warn(err)
+			}
+		}
+	}
+	if cnfg.StaleIntervals > 0 {
+		wg.Add(1)
+		go service.NewStaleTracker(repository, cnfg.StaleIntervals).Run(ctx, wg)
 	}
 
 	fmt.Println(
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS agent_series;
+DROP TABLE IF EXISTS agents;
\ No newline at end of file
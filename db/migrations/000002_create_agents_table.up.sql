@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS agents
+(
+    id              VARCHAR(300) NOT NULL PRIMARY KEY,
+    hostname        VARCHAR(300) NOT NULL DEFAULT '',
+    version         VARCHAR(100) NOT NULL DEFAULT '',
+    started_at      TIMESTAMPTZ  NOT NULL,
+    last_seen       TIMESTAMPTZ  NOT NULL,
+    report_interval BIGINT       NOT NULL DEFAULT 0,
+    metric_count    BIGINT       NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS agent_series
+(
+    agent_id    VARCHAR(300) NOT NULL REFERENCES agents (id) ON DELETE CASCADE,
+    series_hash BIGINT       NOT NULL,
+    started_at  TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (agent_id, series_hash)
+)
@@ -0,0 +1,99 @@
+// Package remoteconfig polls agent settings served by server.
+package remoteconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/signature"
+)
+
+const (
+	configPath      = "/agent/config"
+	signHeader      = "HashSHA256"
+	agentIDParam    = "agent_id"
+	timestampParam  = "timestamp"
+	maxResponseSize = 1024 * 1024
+)
+
+// ErrNotServed returned, when server does not serve agent settings.
+var ErrNotServed = errors.New("agent settings are not served by server")
+
+// Fetcher requests agent settings from server.
+type Fetcher struct {
+	client    *http.Client
+	url       string
+	agentID   string
+	secretKey string
+}
+
+// NewFetcher constructor for Fetcher. Settings are requested from first destination of agent.
+func NewFetcher(agentConfig *config.AgentConfig) *Fetcher {
+	destination := agentConfig.DestinationList()[0]
+	protocol := destination.Protocol
+	if protocol == "" {
+		protocol = config.ProtocolHTTP
+	}
+	return &Fetcher{
+		client:    &http.Client{Timeout: time.Duration(agentConfig.RequestTimeout) * time.Second},
+		url:       protocol + "://" + destination.Address + configPath,
+		agentID:   agentConfig.ID(),
+		secretKey: destination.SecretKey,
+	}
+}
+
+// Fetch request settings from server. Returns settings in json format and parsed ones.
+// When secret key is configured, query with agent id and current time is signed and signature of response is checked.
+func (f *Fetcher) Fetch(ctx context.Context) ([]byte, *config.RemoteAgentConfig, error) {
+	query := url.Values{
+		agentIDParam:   []string{f.agentID},
+		timestampParam: []string{strconv.FormatInt(time.Now().Unix(), 10)},
+	}.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url+"?"+query, http.NoBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed create settings request: %w", err)
+	}
+	if f.secretKey != "" {
+		request.Header.Set(signHeader, f.sign([]byte(query)))
+	}
+	response, err := f.client.Do(request)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed request agent settings: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxResponseSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed read agent settings: %w", err)
+	}
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return nil, nil, ErrNotServed
+	case response.StatusCode != http.StatusOK:
+		return nil, nil, fmt.Errorf("failed get agent settings, status %d: %s", response.StatusCode, bytes.TrimSpace(body))
+	}
+	if f.secretKey != "" && response.Header.Get(signHeader) != f.sign(body) {
+		return nil, nil, errors.New("invalid sign of agent settings")
+	}
+
+	settings := &config.RemoteAgentConfig{}
+	if err = json.Unmarshal(body, settings); err != nil {
+		return nil, nil, fmt.Errorf("invalid agent settings: %w", err)
+	}
+	return body, settings, nil
+}
+
+func (f *Fetcher) sign(body []byte) string {
+	return base64.StdEncoding.EncodeToString(signature.CreateSign(body, f.secretKey))
+}
@@ -0,0 +1,107 @@
+package remoteconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/router"
+	"github.com/ilya372317/must-have-metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetcher_Fetch(t *testing.T) {
+	require.NoError(t, logger.Init())
+	agentConfigPath := filepath.Join(t.TempDir(), "agents.json")
+	require.NoError(t, os.WriteFile(agentConfigPath, []byte(`{
+		"default": {"poll_interval": 2},
+		"agents": {"web-1": {"settings": {"poll_interval": 1, "runtime_source": "runtime"}}}
+	}`), 0o600))
+
+	tests := []struct {
+		name         string
+		serverConfig *config.ServerConfig
+		agentKey     string
+		wantErr      error
+		want         *config.RemoteAgentConfig
+	}{
+		{
+			name:         "signed case",
+			serverConfig: &config.ServerConfig{AgentConfig: agentConfigPath, SecretKey: "secret"},
+			agentKey:     "secret",
+			want:         &config.RemoteAgentConfig{PollInterval: 1, RuntimeSource: config.RuntimeSourceRuntime},
+		},
+		{
+			name:         "unsigned case",
+			serverConfig: &config.ServerConfig{AgentConfig: agentConfigPath},
+			want:         &config.RemoteAgentConfig{PollInterval: 1, RuntimeSource: config.RuntimeSourceRuntime},
+		},
+		{
+			name:         "not served case",
+			serverConfig: &config.ServerConfig{},
+			wantErr:      ErrNotServed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(router.AlertRouter(storage.NewInMemoryStorage(), tt.serverConfig))
+			defer ts.Close()
+
+			fetcher := NewFetcher(&config.AgentConfig{
+				Host:           strings.TrimPrefix(ts.URL, "http://"),
+				SecretKey:      tt.agentKey,
+				AgentID:        "web-1",
+				RequestTimeout: 1,
+			})
+			raw, got, err := fetcher.Fetch(context.Background())
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.JSONEq(t, `{"poll_interval": 1, "runtime_source": "runtime"}`, string(raw))
+		})
+	}
+}
+
+func TestFetcher_FetchInvalidSign(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(signHeader, "forged")
+		_, _ = w.Write([]byte(`{"poll_interval": 1}`))
+	}))
+	defer ts.Close()
+
+	fetcher := NewFetcher(&config.AgentConfig{
+		Host:           strings.TrimPrefix(ts.URL, "http://"),
+		SecretKey:      "secret",
+		RequestTimeout: 1,
+	})
+	_, _, err := fetcher.Fetch(context.Background())
+	require.Error(t, err)
+}
+
+func TestFetcher_FetchWrongKey(t *testing.T) {
+	require.NoError(t, logger.Init())
+	agentConfigPath := filepath.Join(t.TempDir(), "agents.json")
+	require.NoError(t, os.WriteFile(agentConfigPath, []byte(`{"default": {"poll_interval": 2}}`), 0o600))
+	ts := httptest.NewServer(router.AlertRouter(storage.NewInMemoryStorage(),
+		&config.ServerConfig{AgentConfig: agentConfigPath, SecretKey: "secret"}))
+	defer ts.Close()
+
+	fetcher := NewFetcher(&config.AgentConfig{
+		Host:           strings.TrimPrefix(ts.URL, "http://"),
+		SecretKey:      "other",
+		AgentID:        "web-1",
+		RequestTimeout: 1,
+	})
+	_, _, err := fetcher.Fetch(context.Background())
+	require.Error(t, err, "query signed by other key is rejected")
+}
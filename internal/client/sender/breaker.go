@@ -0,0 +1,111 @@
+package sender
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+)
+
+// ErrCircuitOpen returned without sending request, while server is considered unavailable.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// States of circuit breaker.
+const (
+	CircuitClosed = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+// circuitBreaker stop sending requests after threshold of consecutive failures.
+// After timeout one probe request is allowed, its result close or open circuit again.
+type circuitBreaker struct {
+	now      func() time.Time
+	openedAt time.Time
+	timeout  time.Duration
+	failures uint
+	// threshold of consecutive failures, zero disable breaker.
+	threshold uint
+	state     int
+	probing   bool
+	mu        sync.Mutex
+}
+
+func newCircuitBreaker(threshold uint, timeout time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		now:       time.Now,
+		threshold: threshold,
+		timeout:   timeout,
+	}
+}
+
+// allow check if request can be sent.
+func (b *circuitBreaker) allow() error {
+	if b.threshold == 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.timeout {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		logger.Log.Infof("circuit breaker is half-open, probe server")
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// done record result of allowed request. Failure means server is unavailable.
+func (b *circuitBreaker) done(failure bool) {
+	if b.threshold == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !failure {
+		if b.state != CircuitClosed {
+			logger.Log.Infof("circuit breaker is closed, server is available")
+		}
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		if b.state != CircuitOpen {
+			logger.Log.Warnf("circuit breaker is open after %d failures, pause sending for %s", b.failures, b.timeout)
+		}
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// release finish allowed request without result, for example cancelled one.
+// Failures and state are kept, probe may be sent again.
+func (b *circuitBreaker) release() {
+	if b.threshold == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// State return current state of breaker.
+func (b *circuitBreaker) State() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,65 @@
+package sender
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	require.NoError(t, logger.Init())
+	now := time.Now()
+	breaker := newCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time {
+		return now
+	}
+
+	require.NoError(t, breaker.allow())
+	breaker.done(true)
+	require.NoError(t, breaker.allow())
+	breaker.done(true)
+	assert.Equal(t, CircuitOpen, breaker.State())
+	assert.ErrorIs(t, breaker.allow(), ErrCircuitOpen)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, breaker.allow(), "probe is allowed after timeout")
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+	assert.ErrorIs(t, breaker.allow(), ErrCircuitOpen, "only one probe is allowed")
+	breaker.done(true)
+	assert.Equal(t, CircuitOpen, breaker.State(), "failed probe open circuit again")
+
+	now = now.Add(time.Minute)
+	require.NoError(t, breaker.allow())
+	breaker.done(false)
+	assert.Equal(t, CircuitClosed, breaker.State())
+	require.NoError(t, breaker.allow())
+}
+
+func TestCircuitBreakerRelease(t *testing.T) {
+	require.NoError(t, logger.Init())
+	now := time.Now()
+	breaker := newCircuitBreaker(1, time.Minute)
+	breaker.now = func() time.Time {
+		return now
+	}
+
+	require.NoError(t, breaker.allow())
+	breaker.done(true)
+	now = now.Add(time.Minute)
+	require.NoError(t, breaker.allow())
+	breaker.release()
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+	require.NoError(t, breaker.allow(), "probe is allowed again after released one")
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	breaker := newCircuitBreaker(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, breaker.allow())
+		breaker.done(true)
+	}
+	assert.Equal(t, CircuitClosed, breaker.State())
+}
@@ -0,0 +1,51 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Failover ReportSender, which send report to first sender accepted it.
+// Senders are tried in given order, so first one is primary.
+type Failover struct {
+	senders []ReportSender
+}
+
+// NewFailover constructor for Failover.
+func NewFailover(senders ...ReportSender) *Failover {
+	return &Failover{senders: senders}
+}
+
+// Send report by senders in order until any of them succeed.
+// Returns joined errors of all senders, if report was not sent.
+func (f *Failover) Send(ctx context.Context, body string) error {
+	errs := make([]error, 0, len(f.senders))
+	for i, s := range f.senders {
+		err := s.Send(ctx, body)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("destination %d: %w", i, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CircuitState return state of circuit breaker of first sender, which circuit is not open.
+func (f *Failover) CircuitState() int {
+	state := CircuitClosed
+	for _, s := range f.senders {
+		breaker, ok := s.(interface{ CircuitState() int })
+		if !ok {
+			return CircuitClosed
+		}
+		state = breaker.CircuitState()
+		if state != CircuitOpen {
+			return state
+		}
+	}
+	return state
+}
@@ -0,0 +1,71 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubSender struct {
+	err   error
+	state int
+	sent  int
+}
+
+func (s *stubSender) Send(context.Context, string) error {
+	s.sent++
+	return s.err
+}
+
+func (s *stubSender) CircuitState() int {
+	return s.state
+}
+
+func TestFailover_Send(t *testing.T) {
+	tests := []struct {
+		name          string
+		primaryErr    error
+		secondaryErr  error
+		wantPrimary   int
+		wantSecondary int
+		wantErr       bool
+	}{
+		{name: "primary available case", wantPrimary: 1},
+		{name: "primary down case", primaryErr: errors.New("down"), wantPrimary: 1, wantSecondary: 1},
+		{
+			name:          "all down case",
+			primaryErr:    errors.New("down"),
+			secondaryErr:  errors.New("down"),
+			wantPrimary:   1,
+			wantSecondary: 1,
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &stubSender{err: tt.primaryErr}
+			secondary := &stubSender{err: tt.secondaryErr}
+			err := NewFailover(primary, secondary).Send(context.Background(), `[]`)
+			assert.Equal(t, tt.wantPrimary, primary.sent)
+			assert.Equal(t, tt.wantSecondary, secondary.sent)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestFailover_CircuitState(t *testing.T) {
+	primary := &stubSender{state: CircuitOpen}
+	secondary := &stubSender{state: CircuitClosed}
+	failover := NewFailover(primary, secondary)
+	assert.Equal(t, CircuitClosed, failover.CircuitState())
+
+	secondary.state = CircuitOpen
+	assert.Equal(t, CircuitOpen, failover.CircuitState())
+}
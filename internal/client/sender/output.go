@@ -0,0 +1,187 @@
+package sender
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/promtext"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+// OutputSender ReportSender, which write reports to stdout or rotating file instead of sending them to server.
+//
+// In json format every metric is written as separate line in /updates format, so output file can be
+// imported by Replay. In prometheus format output is a stream of expositions: every report is written
+// in text exposition format and is terminated by "# EOF" line, so readers split output by that line.
+// Counters are written with cumulative values since agent start, names are sanitized
+// and labels put into ID by labels mode are written as labels.
+type OutputSender struct {
+	writer io.Writer
+	closer io.Closer
+	totals map[string]int64
+	format string
+	mu     sync.Mutex
+}
+
+// expositionEnd line, which terminates every report written in prometheus format.
+const expositionEnd = "# EOF\n"
+
+// NewOutputSender constructor for OutputSender.
+func NewOutputSender(agentConfig *config.AgentConfig) (*OutputSender, error) {
+	s := &OutputSender{format: agentConfig.OutputFormat, totals: make(map[string]int64)}
+	switch s.format {
+	case "":
+		s.format = config.OutputFormatJSON
+	case config.OutputFormatJSON, config.OutputFormatPrometheus:
+	default:
+		return nil, fmt.Errorf("unknown output format %q", agentConfig.OutputFormat)
+	}
+
+	if agentConfig.Output == config.OutputStdout {
+		s.writer = os.Stdout
+		return s, nil
+	}
+	file, err := openRotatingFile(agentConfig.Output, int64(agentConfig.OutputMaxSize), int(agentConfig.OutputMaxFiles))
+	if err != nil {
+		return nil, err
+	}
+	s.writer = file
+	s.closer = file
+	return s, nil
+}
+
+// Send write report. Every report is written by single write, so rotated files contain whole reports.
+func (s *OutputSender) Send(_ context.Context, body string) error {
+	metricsList := make([]dto.Metrics, 0)
+	if err := json.Unmarshal([]byte(body), &metricsList); err != nil {
+		return fmt.Errorf("invalid report: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buffer := bytes.Buffer{}
+	if s.format == config.OutputFormatPrometheus {
+		if err := promtext.Write(&buffer, s.toFamilies(metricsList)); err != nil {
+			return err
+		}
+		buffer.WriteString(expositionEnd)
+	} else {
+		encoder := json.NewEncoder(&buffer)
+		for _, metrics := range metricsList {
+			if err := encoder.Encode(metrics); err != nil {
+				return fmt.Errorf("failed encode metrics %s: %w", metrics.ID, err)
+			}
+		}
+	}
+
+	if _, err := s.writer.Write(buffer.Bytes()); err != nil {
+		return fmt.Errorf("failed write report: %w", err)
+	}
+	return nil
+}
+
+// Close close output file.
+func (s *OutputSender) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// toFamilies group metrics by name and type, series with different labels get into the same family.
+// Counter deltas are added to totals of series. Should be called under lock.
+func (s *OutputSender) toFamilies(metricsList []dto.Metrics) []promtext.Family {
+	families := make([]promtext.Family, 0, len(metricsList))
+	positions := make(map[string]int, len(metricsList))
+	for _, metrics := range metricsList {
+		name, labels := exposedName(metrics)
+		sample := promtext.Sample{Labels: labels}
+		familyType := promtext.TypeGauge
+		if metrics.MType == entity.TypeCounter {
+			familyType = promtext.TypeCounter
+			id := promtext.SeriesID(name, labels)
+			if metrics.Delta != nil {
+				s.totals[id] += *metrics.Delta
+			}
+			sample.Value = float64(s.totals[id])
+		} else if metrics.Value != nil {
+			sample.Value = *metrics.Value
+		}
+
+		key := familyType + ":" + name
+		i, ok := positions[key]
+		if !ok {
+			i = len(families)
+			positions[key] = i
+			families = append(families, promtext.Family{Name: name, Type: familyType})
+		}
+		families[i].Samples = append(families[i].Samples, sample)
+	}
+	return families
+}
+
+// exposedName return sanitized metric name and labels. Labels put into ID by labels mode are moved to labels.
+func exposedName(metrics dto.Metrics) (string, map[string]string) {
+	name, labels, err := promtext.ParseSeriesID(metrics.ID)
+	if err != nil || len(labels) == 0 {
+		return promtext.SanitizeName(metrics.ID), metrics.Labels
+	}
+	for key, value := range metrics.Labels {
+		labels[key] = value
+	}
+	return promtext.SanitizeName(name), labels
+}
+
+// Replay send metrics from output file in json format by batches not bigger than batchSize.
+// Returns count of sent metrics.
+func Replay(ctx context.Context, r io.Reader, s ReportSender, batchSize int) (int, error) {
+	scanner := bufio.NewScanner(r)
+	batch := make([]dto.Metrics, 0, batchSize)
+	sent := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		body, err := json.Marshal(batch)
+		if err != nil {
+			return fmt.Errorf("failed serialize metrics: %w", err)
+		}
+		if err = s.Send(ctx, string(body)); err != nil {
+			return err
+		}
+		sent += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		metrics := dto.Metrics{}
+		if err := json.Unmarshal(scanner.Bytes(), &metrics); err != nil {
+			return sent, fmt.Errorf("invalid metrics on line %d: %w", line, err)
+		}
+		batch = append(batch, metrics)
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return sent, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return sent, fmt.Errorf("failed read metrics: %w", err)
+	}
+	if err := flush(); err != nil {
+		return sent, err
+	}
+	return sent, nil
+}
@@ -0,0 +1,204 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const outputReport = `[{"id":"Alloc","type":"gauge","value":1.5,"labels":{"host":"web-1"}},` +
+	`{"id":"Alloc","type":"gauge","value":2,"labels":{"host":"web-2"}},` +
+	`{"id":"PollCount","type":"counter","delta":3}]`
+
+func TestOutputSender_Send(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "json format case",
+			format: config.OutputFormatJSON,
+			body:   outputReport,
+			want: `{"value":1.5,"labels":{"host":"web-1"},"id":"Alloc","type":"gauge"}` + "\n" +
+				`{"value":2,"labels":{"host":"web-2"},"id":"Alloc","type":"gauge"}` + "\n" +
+				`{"delta":3,"id":"PollCount","type":"counter"}` + "\n",
+		},
+		{
+			name:   "prometheus format case",
+			format: config.OutputFormatPrometheus,
+			body:   outputReport,
+			want: "# TYPE Alloc gauge\n" +
+				`Alloc{host="web-1"} 1.5` + "\n" +
+				`Alloc{host="web-2"} 2` + "\n" +
+				"# TYPE PollCount counter\n" +
+				"PollCount 3\n" +
+				"# EOF\n",
+		},
+		{
+			name:   "prometheus format with labels in id case",
+			format: config.OutputFormatPrometheus,
+			body:   `[{"id":"web-1.Alloc{host=\"web-1\"}","type":"gauge","value":2}]`,
+			want: "# TYPE web_1_Alloc gauge\n" +
+				`web_1_Alloc{host="web-1"} 2` + "\n" +
+				"# EOF\n",
+		},
+		{
+			name:    "invalid report case",
+			format:  config.OutputFormatJSON,
+			body:    `{`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "reports", "metrics.out")
+			s, err := NewOutputSender(&config.AgentConfig{Output: path, OutputFormat: tt.format})
+			require.NoError(t, err)
+			err = s.Send(context.Background(), tt.body)
+			require.NoError(t, s.Close())
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(content))
+		})
+	}
+}
+
+func TestOutputSender_SendPrometheusCounters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.out")
+	s, err := NewOutputSender(&config.AgentConfig{Output: path, OutputFormat: config.OutputFormatPrometheus})
+	require.NoError(t, err)
+	require.NoError(t, s.Send(context.Background(), `[{"id":"PollCount","type":"counter","delta":3}]`))
+	require.NoError(t, s.Send(context.Background(), `[{"id":"PollCount","type":"counter","delta":2}]`))
+	require.NoError(t, s.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "# TYPE PollCount counter\nPollCount 3\n# EOF\n"+
+		"# TYPE PollCount counter\nPollCount 5\n# EOF\n", string(content), "counters are cumulative")
+}
+
+func TestNewOutputSender_UnknownFormat(t *testing.T) {
+	_, err := NewOutputSender(&config.AgentConfig{Output: config.OutputStdout, OutputFormat: "xml"})
+	require.Error(t, err)
+}
+
+func TestOutputSender_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.out")
+	report := `[{"id":"PollCount","type":"counter","delta":1}]`
+	line := `{"delta":1,"id":"PollCount","type":"counter"}` + "\n"
+	s, err := NewOutputSender(&config.AgentConfig{
+		Output:         path,
+		OutputFormat:   config.OutputFormatJSON,
+		OutputMaxSize:  uint(len(line)),
+		OutputMaxFiles: 2,
+	})
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, s.Send(context.Background(), report))
+	}
+	require.NoError(t, s.Close())
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		content, err := os.ReadFile(name)
+		require.NoError(t, err)
+		assert.Equal(t, line, string(content))
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestOutputSender_RotateFailed(t *testing.T) {
+	require.NoError(t, logger.Init())
+	path := filepath.Join(t.TempDir(), "metrics.out")
+	require.NoError(t, os.MkdirAll(filepath.Join(path+".1", "busy"), 0o750))
+	report := `[{"id":"PollCount","type":"counter","delta":1}]`
+	line := `{"delta":1,"id":"PollCount","type":"counter"}` + "\n"
+	s, err := NewOutputSender(&config.AgentConfig{
+		Output:         path,
+		OutputFormat:   config.OutputFormatJSON,
+		OutputMaxSize:  uint(len(line)),
+		OutputMaxFiles: 1,
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.Send(context.Background(), report))
+	require.NoError(t, s.Send(context.Background(), report), "report is written to not rotated file")
+	require.NoError(t, s.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, line+line, string(content))
+}
+
+func TestReplay(t *testing.T) {
+	lines := []string{
+		`{"id":"Alloc","type":"gauge","value":1.5}`,
+		``,
+		`{"id":"PollCount","type":"counter","delta":3}`,
+		`{"id":"Sys","type":"gauge","value":7}`,
+	}
+	tests := []struct {
+		name        string
+		input       string
+		sendErr     error
+		wantBatches []int
+		wantSent    int
+		wantErr     bool
+	}{
+		{
+			name:        "success case",
+			input:       strings.Join(lines, "\n"),
+			wantBatches: []int{2, 1},
+			wantSent:    3,
+		},
+		{
+			name:        "invalid line case",
+			input:       lines[0] + "\n{\n" + lines[2],
+			wantBatches: []int{},
+			wantErr:     true,
+		},
+		{
+			name:        "send error case",
+			input:       strings.Join(lines, "\n"),
+			sendErr:     errors.New("down"),
+			wantBatches: []int{2},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batches := make([]int, 0)
+			s := ReportSenderFunc(func(_ context.Context, body string) error {
+				metricsList := make([]dto.Metrics, 0)
+				require.NoError(t, json.Unmarshal([]byte(body), &metricsList))
+				batches = append(batches, len(metricsList))
+				return tt.sendErr
+			})
+			sent, err := Replay(context.Background(), strings.NewReader(tt.input), s, 2)
+			assert.Equal(t, tt.wantBatches, batches)
+			assert.Equal(t, tt.wantSent, sent)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
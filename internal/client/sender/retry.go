@@ -0,0 +1,100 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	minRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff = 10 * time.Second
+)
+
+// retriableError error of request, which may succeed if request is repeated later.
+type retriableError struct {
+	err error
+	// retryAfter delay requested by server, zero if not set.
+	retryAfter time.Duration
+}
+
+func (e *retriableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retriableError) Unwrap() error {
+	return e.err
+}
+
+// classifyResponse wrap error of request into retriableError, if it is caused by connection error,
+// server error or rate limit with Retry-After header.
+func classifyResponse(ctx context.Context, response *resty.Response, err error) error {
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return &retriableError{err: err}
+	}
+	status := response.StatusCode()
+	if status == http.StatusOK {
+		return nil
+	}
+	statusErr := &unexpectedStatusError{status: status}
+	if status >= http.StatusInternalServerError {
+		return &retriableError{err: statusErr}
+	}
+	if status == http.StatusTooManyRequests {
+		if retryAfter, ok := parseRetryAfter(response.Header().Get("Retry-After"), time.Now()); ok {
+			return &retriableError{err: statusErr, retryAfter: retryAfter}
+		}
+	}
+	return statusErr
+}
+
+type unexpectedStatusError struct {
+	status int
+}
+
+func (e *unexpectedStatusError) Error() string {
+	return "unexpected status " + strconv.Itoa(e.status)
+}
+
+// parseRetryAfter parse Retry-After header given in seconds or as HTTP date.
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// retryDelay return delay before given retry attempt: exponential backoff with full jitter
+// in upper half of interval. Delay requested by server takes precedence.
+// Returns false, if server requested delay longer than maxRetryBackoff, such report
+// is not retried by sender and is left to spool, so report worker is not blocked for that time.
+func retryDelay(attempt uint, err error) (time.Duration, bool) {
+	var retriable *retriableError
+	if errors.As(err, &retriable) && retriable.retryAfter > 0 {
+		return retriable.retryAfter, retriable.retryAfter <= maxRetryBackoff
+	}
+	backoff := maxRetryBackoff
+	if attempt < 16 {
+		backoff = min(minRetryBackoff<<attempt, maxRetryBackoff)
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)), true
+}
+
+func isRetriable(err error) bool {
+	var retriable *retriableError
+	return errors.As(err, &retriable)
+}
@@ -0,0 +1,126 @@
+package sender
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+)
+
+const (
+	outputDirPerm  = 0o750
+	outputFilePerm = 0o640
+)
+
+// rotatingFile file, which is renamed to path.1 after it exceeds max size.
+// Older files are shifted to path.2 and so on, files over maxFiles are removed.
+// If rotation fails, file is reopened and writes continue to not rotated file.
+type rotatingFile struct {
+	file     *os.File
+	path     string
+	size     int64
+	maxSize  int64
+	maxFiles int
+}
+
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), outputDirPerm); err != nil {
+		return nil, fmt.Errorf("failed create output dir: %w", err)
+	}
+	r := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write append p to file. File is rotated before write, if write exceeds max size.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			if r.file == nil {
+				return 0, err
+			}
+			logger.Log.Warnf("failed rotate output file, keep writing to %s: %v", r.path, err)
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	if err != nil {
+		return n, fmt.Errorf("failed write output file: %w", err)
+	}
+	return n, nil
+}
+
+// Close close current file.
+func (r *rotatingFile) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed close output file: %w", err)
+	}
+	return nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, outputFilePerm)
+	if err != nil {
+		return fmt.Errorf("failed open output file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed stat output file: %w", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// rotate move current file to backup and open new one. Current file is reopened, if it can not be moved,
+// so failed rotation is retried by next write. File is left closed only if it can not be opened at all.
+func (r *rotatingFile) rotate() error {
+	err := r.file.Close()
+	r.file = nil
+	if err != nil {
+		err = fmt.Errorf("failed close output file: %w", err)
+	} else {
+		err = r.shift()
+	}
+	if openErr := r.open(); openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	return err
+}
+
+// shift move current file to path.1 and older backups to next positions.
+func (r *rotatingFile) shift() error {
+	if r.maxFiles <= 0 {
+		if err := os.Remove(r.path); err != nil {
+			return fmt.Errorf("failed remove output file: %w", err)
+		}
+		return nil
+	}
+	for i := r.maxFiles - 1; i > 0; i-- {
+		if err := os.Rename(r.backupPath(i), r.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed rotate output file: %w", err)
+		}
+	}
+	if err := os.Rename(r.path, r.backupPath(1)); err != nil {
+		return fmt.Errorf("failed rotate output file: %w", err)
+	}
+	return nil
+}
+
+func (r *rotatingFile) backupPath(i int) string {
+	return r.path + "." + strconv.Itoa(i)
+}
@@ -1,33 +1,141 @@
 package sender
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
 	"github.com/go-resty/resty/v2"
 	"github.com/ilya372317/must-have-metrics/internal/cmiddleware"
 	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
 	"github.com/ilya372317/must-have-metrics/internal/logger"
 )
 
-const failedSaveDataErrPattern = "failed to save data on server: %v\n"
+const idleConnTimeout = 90 * time.Second
 
 // ReportSender interface for somehow sending report on server.
-type ReportSender func(agentConfig *config.AgentConfig, requestURL, body string)
+type ReportSender interface {
+	// Send deliver report body. Returns error, if server not accepted data.
+	Send(ctx context.Context, body string) error
+}
+
+// ReportSenderFunc adapter to use ordinary function as ReportSender.
+type ReportSenderFunc func(ctx context.Context, body string) error
 
-// SendReport implementation of ReportSender interface wich send report on server by http request.
-func SendReport(agentConfig *config.AgentConfig, requestURL, body string) {
-	c := resty.New()
+// Send call f(ctx, body).
+func (f ReportSenderFunc) Send(ctx context.Context, body string) error {
+	return f(ctx, body)
+}
+
+// HTTPSender implementation of ReportSender interface wich send report on server by http request.
+// Sender is safe for concurrent use and keeps connections to server alive between reports.
+type HTTPSender struct {
+	client     *resty.Client
+	breaker    *circuitBreaker
+	requestURL string
+	retries    uint
+}
+
+// NewHTTPSender constructor for HTTPSender, which send reports to agent address.
+func NewHTTPSender(agentConfig *config.AgentConfig) (*HTTPSender, error) {
+	return NewDestinationSender(agentConfig, config.DestinationConfig{
+		Address:   agentConfig.Host,
+		SecretKey: agentConfig.SecretKey,
+		CryptoKey: agentConfig.CryptoKey,
+	})
+}
+
+// NewDestinationSender constructor for HTTPSender, which send reports to given destination.
+// Public key for cipher data is read only once. Failed requests are retried,
+// while server is unavailable requests are stopped by circuit breaker.
+func NewDestinationSender(agentConfig *config.AgentConfig, destination config.DestinationConfig) (*HTTPSender, error) {
+	protocol := destination.Protocol
+	switch protocol {
+	case "":
+		protocol = config.ProtocolHTTP
+	case config.ProtocolHTTP, config.ProtocolHTTPS:
+	default:
+		return nil, fmt.Errorf("unknown protocol %q of destination %s", protocol, destination.Address)
+	}
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        int(agentConfig.RateLimit),
+		MaxIdleConnsPerHost: int(agentConfig.RateLimit),
+		IdleConnTimeout:     idleConnTimeout,
+	}
+	c := resty.New().
+		SetTransport(transport).
+		SetTimeout(time.Duration(agentConfig.RequestTimeout) * time.Second)
 
-	if agentConfig.ShouldSignData() {
-		c.OnBeforeRequest(cmiddleware.WithSignature(agentConfig.SecretKey))
+	if destination.ShouldSignData() {
+		c.OnBeforeRequest(cmiddleware.WithSignature(destination.SecretKey))
 	}
 	c.OnBeforeRequest(cmiddleware.WithCompress())
-	if agentConfig.ShouldCipherData() {
-		c.OnBeforeRequest(cmiddleware.WithRSACrypt(agentConfig.CryptoKey))
+	if destination.ShouldCipherData() {
+		publicKey, err := cmiddleware.LoadPublicKey(destination.CryptoKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed create http sender: %w", err)
+		}
+		c.OnBeforeRequest(cmiddleware.WithRSAPublicKey(publicKey))
 	}
 
-	_, err := c.R().SetBody(body).
-		Post(requestURL)
+	return &HTTPSender{
+		client:     c,
+		breaker:    newCircuitBreaker(agentConfig.BreakerThreshold, time.Duration(agentConfig.BreakerTimeout)*time.Second),
+		retries:    agentConfig.RetryCount,
+		requestURL: protocol + "://" + destination.Address + "/updates",
+	}, nil
+}
+
+// Send report on server. Connection errors, server errors and rate limits with Retry-After are retried.
+// Returns error, if request failed or server not accepted data.
+func (s *HTTPSender) Send(ctx context.Context, body string) error {
+	for attempt := uint(0); ; attempt++ {
+		err := s.send(ctx, body)
+		if err == nil || !isRetriable(err) || attempt >= s.retries {
+			return err
+		}
+		delay, ok := retryDelay(attempt, err)
+		if !ok {
+			logger.Log.Warnf("failed send report, server requested retry in %s: %v", delay, err)
+			return err
+		}
+		logger.Log.Warnf("failed send report, retry in %s: %v", delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("failed to save data on server: %w", ctx.Err())
+		}
+	}
+}
+
+// SetIdentity set headers, by which server identify agent in every request.
+func (s *HTTPSender) SetIdentity(identity dto.AgentIdentity) {
+	s.client.SetHeaders(identity.Headers())
+}
+
+// CircuitState return state of circuit breaker.
+func (s *HTTPSender) CircuitState() int {
+	return s.breaker.State()
+}
+
+func (s *HTTPSender) send(ctx context.Context, body string) error {
+	if err := s.breaker.allow(); err != nil {
+		return fmt.Errorf("failed to save data on server: %w", err)
+	}
+	response, err := s.client.R().SetContext(ctx).SetBody(body).
+		Post(s.requestURL)
+	err = classifyResponse(ctx, response, err)
+	if ctx.Err() != nil {
+		// Cancelled request says nothing about server availability.
+		s.breaker.release()
+	} else {
+		s.breaker.done(isRetriable(err))
+	}
 	if err != nil {
-		logger.Log.Errorf(failedSaveDataErrPattern, err)
-		return
+		return fmt.Errorf("failed to save data on server: %w", err)
 	}
+	return nil
 }
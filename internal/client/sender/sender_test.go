@@ -0,0 +1,231 @@
+package sender
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPSender_Send(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		delay   time.Duration
+		wantErr bool
+	}{
+		{name: "success case", status: http.StatusOK},
+		{name: "not accepted case", status: http.StatusBadRequest, wantErr: true},
+		{name: "timeout case", status: http.StatusOK, delay: 1500 * time.Millisecond, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/updates", r.URL.Path)
+				assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+				time.Sleep(tt.delay)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			s, err := NewHTTPSender(&config.AgentConfig{
+				Host:           strings.TrimPrefix(server.URL, "http://"),
+				RateLimit:      1,
+				RequestTimeout: 1,
+			})
+			require.NoError(t, err)
+			err = s.Send(context.Background(), `[]`)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestNewHTTPSender(t *testing.T) {
+	keyPath := t.TempDir() + "/public-key.pem"
+	require.NoError(t, os.WriteFile(keyPath, []byte("not a key"), 0o600))
+
+	_, err := NewHTTPSender(&config.AgentConfig{CryptoKey: keyPath})
+	require.Error(t, err, "invalid public key is reported once on create")
+}
+
+func TestNewDestinationSender(t *testing.T) {
+	s, err := NewDestinationSender(&config.AgentConfig{}, config.DestinationConfig{
+		Address:  "new.example.com:443",
+		Protocol: config.ProtocolHTTPS,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://new.example.com:443/updates", s.requestURL)
+
+	_, err = NewDestinationSender(&config.AgentConfig{}, config.DestinationConfig{Protocol: "grpc"})
+	require.Error(t, err)
+}
+
+func TestHTTPSender_SendRetry(t *testing.T) {
+	require.NoError(t, logger.Init())
+	tests := []struct {
+		name         string
+		statuses     []int
+		retryAfter   string
+		wantRequests int
+		wantErr      bool
+	}{
+		{name: "server error case", statuses: []int{http.StatusBadGateway, http.StatusOK}, wantRequests: 2},
+		{
+			name:         "rate limit with retry after case",
+			statuses:     []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:   "0",
+			wantRequests: 2,
+		},
+		{
+			name:         "rate limit with long retry after case",
+			statuses:     []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:   "60",
+			wantRequests: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "rate limit without retry after case",
+			statuses:     []int{http.StatusTooManyRequests},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+		{name: "bad request case", statuses: []int{http.StatusBadRequest}, wantRequests: 1, wantErr: true},
+		{
+			name:         "retries exceeded case",
+			statuses:     []int{http.StatusInternalServerError, http.StatusInternalServerError},
+			wantRequests: 2,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statuses[min(requests, len(tt.statuses)-1)])
+				requests++
+			}))
+			defer server.Close()
+
+			s, err := NewHTTPSender(&config.AgentConfig{
+				Host:       strings.TrimPrefix(server.URL, "http://"),
+				RetryCount: 1,
+			})
+			require.NoError(t, err)
+			err = s.Send(context.Background(), `[]`)
+			assert.Equal(t, tt.wantRequests, requests)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestHTTPSender_SendCircuitOpen(t *testing.T) {
+	require.NoError(t, logger.Init())
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	s, err := NewHTTPSender(&config.AgentConfig{
+		Host:             strings.TrimPrefix(server.URL, "http://"),
+		BreakerThreshold: 2,
+		BreakerTimeout:   60,
+	})
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.Error(t, s.Send(context.Background(), `[]`))
+	}
+	assert.Equal(t, 2, requests, "requests are not sent while circuit is open")
+	assert.Equal(t, CircuitOpen, s.CircuitState())
+	assert.ErrorIs(t, s.Send(context.Background(), `[]`), ErrCircuitOpen)
+}
+
+func TestHTTPSender_SendCancelled(t *testing.T) {
+	require.NoError(t, logger.Init())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	s, err := NewHTTPSender(&config.AgentConfig{
+		Host:             strings.TrimPrefix(server.URL, "http://"),
+		BreakerThreshold: 2,
+		BreakerTimeout:   60,
+	})
+	require.NoError(t, err)
+	require.Error(t, s.Send(context.Background(), `[]`))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, s.Send(ctx, `[]`))
+	assert.Equal(t, CircuitClosed, s.CircuitState())
+	require.Error(t, s.Send(context.Background(), `[]`))
+	assert.Equal(t, CircuitOpen, s.CircuitState(), "cancelled request is not counted as success")
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		wantOk bool
+	}{
+		{name: "seconds case", header: "3", want: 3 * time.Second, wantOk: true},
+		{name: "date case", header: "Mon, 01 Jan 2024 00:00:10 GMT", want: 10 * time.Second, wantOk: true},
+		{name: "empty case", header: ""},
+		{name: "invalid case", header: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.header, now)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHTTPSender_SetIdentity(t *testing.T) {
+	identity := dto.AgentIdentity{
+		Started:        time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		ID:             "web-1",
+		Hostname:       "web-host",
+		Version:        "v1.0.0",
+		ReportInterval: 10,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, err := dto.NewAgentIdentityFromRequest(r)
+		assert.NoError(t, err)
+		assert.Equal(t, &identity, got)
+	}))
+	defer server.Close()
+
+	s, err := NewHTTPSender(&config.AgentConfig{
+		Host:           strings.TrimPrefix(server.URL, "http://"),
+		RateLimit:      1,
+		RequestTimeout: 1,
+	})
+	require.NoError(t, err)
+	s.SetIdentity(identity)
+	require.NoError(t, s.Send(context.Background(), `[]`))
+}
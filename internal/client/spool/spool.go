@@ -0,0 +1,306 @@
+// Package spool implements persistent on-disk queue of metrics, which agent failed to send.
+//
+// Queue consists of segment files with lists of metrics in /updates format.
+// New metrics are merged into the last segment: counter deltas are summed up
+// and gauges are superseded by newer values, so size of queue is bounded by number of series.
+// Only head segment, which is being sent at the moment, is not changed.
+package spool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/promtext"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+const (
+	segmentExt        = ".json"
+	tmpExt            = ".tmp"
+	segmentNameFormat = "%020d" + segmentExt
+	dirPerm           = 0o700
+	filePerm          = 0o600
+	minBackoff        = time.Second
+	maxBackoff        = time.Minute
+)
+
+// ErrFull returned when metrics can not be queued without exceeding size limit.
+var ErrFull = errors.New("spool size limit exceeded")
+
+// SendFunc sends metrics to server.
+type SendFunc func(ctx context.Context, metrics []dto.Metrics) error
+
+type segment struct {
+	seq  uint64
+	size int64
+}
+
+// Spool persistent queue of metrics.
+type Spool struct {
+	notify    chan struct{}
+	dir       string
+	segments  []segment
+	maxSize   int64
+	batchSize int
+	nextSeq   uint64
+	inFlight  bool
+	mu        sync.Mutex
+}
+
+// New constructor for Spool. Segments left in dir by previous agent run are restored.
+// Metrics are drained by batches not bigger than batchSize.
+func New(dir string, maxSize int64, batchSize int) (*Spool, error) {
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, fmt.Errorf("failed create spool dir: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed read spool dir: %w", err)
+	}
+
+	s := &Spool{
+		notify:    make(chan struct{}, 1),
+		dir:       dir,
+		maxSize:   maxSize,
+		batchSize: batchSize,
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, tmpExt) {
+			_ = os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed stat spool segment: %w", err)
+		}
+		s.segments = append(s.segments, segment{seq: seq, size: info.Size()})
+	}
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].seq < s.segments[j].seq
+	})
+	if len(s.segments) > 0 {
+		s.nextSeq = s.segments[len(s.segments)-1].seq + 1
+		s.notify <- struct{}{}
+	}
+
+	return s, nil
+}
+
+// Len return count of queued segments.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.segments)
+}
+
+// Push add metrics to queue.
+func (s *Spool) Push(metrics []dto.Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last := len(s.segments) - 1
+	if last < 0 || (last == 0 && s.inFlight) {
+		if err := s.writeSegment(len(s.segments), s.nextSeq, metrics); err != nil {
+			return err
+		}
+		s.nextSeq++
+	} else {
+		queued, err := s.readSegment(s.segments[last].seq)
+		if err != nil {
+			return err
+		}
+		if err = s.writeSegment(last, s.segments[last].seq, merge(queued, metrics)); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Drain send queued metrics in order until context is done.
+// After failed send next attempt is made with exponential backoff.
+func (s *Spool) Drain(ctx context.Context, send SendFunc) {
+	backoff := minBackoff
+	for {
+		batch, err := s.next()
+		if err != nil {
+			logger.Log.Errorf("failed read spool: %v", err)
+		}
+		if len(batch) == 0 && err == nil {
+			select {
+			case <-s.notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err == nil {
+			err = send(ctx, batch)
+			if err == nil {
+				err = s.commit(len(batch))
+			} else {
+				s.release()
+				logger.Log.Warnf("failed send spooled metrics, retry in %s: %v", backoff, err)
+			}
+		}
+		if err == nil {
+			backoff = minBackoff
+			continue
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// next return first batch of head segment and mark head as in flight.
+func (s *Spool) next() ([]dto.Metrics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.segments) == 0 {
+		return nil, nil
+	}
+	metrics, err := s.readSegment(s.segments[0].seq)
+	if err != nil {
+		s.removeHead()
+		return nil, err
+	}
+	s.inFlight = true
+	if len(metrics) > s.batchSize {
+		metrics = metrics[:s.batchSize]
+	}
+	return metrics, nil
+}
+
+// commit remove sent batch of given size from head segment.
+func (s *Spool) commit(sent int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight = false
+	metrics, err := s.readSegment(s.segments[0].seq)
+	if err != nil {
+		s.removeHead()
+		return err
+	}
+	if sent >= len(metrics) {
+		s.removeHead()
+		return nil
+	}
+	return s.writeSegment(0, s.segments[0].seq, metrics[sent:])
+}
+
+func (s *Spool) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight = false
+}
+
+func (s *Spool) removeHead() {
+	if err := os.Remove(s.segmentPath(s.segments[0].seq)); err != nil && !os.IsNotExist(err) {
+		logger.Log.Errorf("failed remove spool segment: %v", err)
+	}
+	s.segments = s.segments[1:]
+}
+
+func (s *Spool) readSegment(seq uint64) ([]dto.Metrics, error) {
+	content, err := os.ReadFile(s.segmentPath(seq))
+	if err != nil {
+		return nil, fmt.Errorf("failed read spool segment: %w", err)
+	}
+	metrics := make([]dto.Metrics, 0)
+	if err = json.Unmarshal(content, &metrics); err != nil {
+		return nil, fmt.Errorf("invalid spool segment %d: %w", seq, err)
+	}
+	return metrics, nil
+}
+
+// writeSegment atomically write segment at given position of segments list.
+// Returns ErrFull, if spool size exceed limit after write.
+func (s *Spool) writeSegment(position int, seq uint64, metrics []dto.Metrics) error {
+	content, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("failed serialize spool segment: %w", err)
+	}
+	size := int64(len(content))
+	for i, queued := range s.segments {
+		if i != position {
+			size += queued.size
+		}
+	}
+	if s.maxSize > 0 && size > s.maxSize {
+		return ErrFull
+	}
+
+	path := s.segmentPath(seq)
+	if err = os.WriteFile(path+tmpExt, content, filePerm); err != nil {
+		return fmt.Errorf("failed write spool segment: %w", err)
+	}
+	if err = os.Rename(path+tmpExt, path); err != nil {
+		return fmt.Errorf("failed write spool segment: %w", err)
+	}
+
+	written := segment{seq: seq, size: int64(len(content))}
+	if position == len(s.segments) {
+		s.segments = append(s.segments, written)
+	} else {
+		s.segments[position] = written
+	}
+	return nil
+}
+
+func (s *Spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf(segmentNameFormat, seq))
+}
+
+func seriesKey(metrics dto.Metrics) string {
+	return metrics.MType + ":" + promtext.SeriesID(metrics.ID, metrics.Labels)
+}
+
+// merge add newer metrics to queued ones. Counter deltas are summed up, gauges are replaced.
+func merge(queued, newer []dto.Metrics) []dto.Metrics {
+	positions := make(map[string]int, len(queued))
+	for i, metrics := range queued {
+		positions[seriesKey(metrics)] = i
+	}
+	for _, metrics := range newer {
+		key := seriesKey(metrics)
+		i, ok := positions[key]
+		if !ok {
+			positions[key] = len(queued)
+			queued = append(queued, metrics)
+			continue
+		}
+		if metrics.MType == entity.TypeCounter && queued[i].Delta != nil && metrics.Delta != nil {
+			delta := *queued[i].Delta + *metrics.Delta
+			queued[i].Delta = &delta
+			continue
+		}
+		queued[i] = metrics
+	}
+	return queued
+}
@@ -0,0 +1,109 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func counter(id string, delta int64) dto.Metrics {
+	return dto.Metrics{ID: id, MType: entity.TypeCounter, Delta: &delta}
+}
+
+func gauge(id string, value float64) dto.Metrics {
+	return dto.Metrics{ID: id, MType: entity.TypeGauge, Value: &value}
+}
+
+func TestMerge(t *testing.T) {
+	got := merge(
+		[]dto.Metrics{counter("PollCount", 2), gauge("Alloc", 10)},
+		[]dto.Metrics{counter("PollCount", 3), gauge("Alloc", 20), gauge("Sys", 5)},
+	)
+	assert.Equal(t, []dto.Metrics{counter("PollCount", 5), gauge("Alloc", 20), gauge("Sys", 5)}, got)
+}
+
+func TestSpool_Push(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir, 0, 10)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Push([]dto.Metrics{counter("PollCount", 1), gauge("Alloc", 1)}))
+	require.NoError(t, s.Push([]dto.Metrics{counter("PollCount", 2), gauge("Alloc", 2)}))
+	assert.Equal(t, 1, s.Len())
+
+	batch, err := s.next()
+	require.NoError(t, err)
+	assert.Equal(t, []dto.Metrics{counter("PollCount", 3), gauge("Alloc", 2)}, batch)
+
+	// head segment is in flight, new metrics go to the next segment.
+	require.NoError(t, s.Push([]dto.Metrics{counter("PollCount", 4)}))
+	require.NoError(t, s.Push([]dto.Metrics{counter("PollCount", 5)}))
+	assert.Equal(t, 2, s.Len())
+
+	restored, err := New(dir, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, restored.Len())
+	require.NoError(t, restored.Push([]dto.Metrics{gauge("Alloc", 3)}))
+	assert.Equal(t, 2, restored.Len())
+	tail, err := restored.readSegment(restored.segments[1].seq)
+	require.NoError(t, err)
+	assert.Equal(t, []dto.Metrics{counter("PollCount", 9), gauge("Alloc", 3)}, tail)
+}
+
+func TestSpool_PushFull(t *testing.T) {
+	s, err := New(t.TempDir(), 64, 10)
+	require.NoError(t, err)
+	require.NoError(t, s.Push([]dto.Metrics{counter("PollCount", 1)}))
+	err = s.Push([]dto.Metrics{gauge("Alloc", 1), gauge("Sys", 1)})
+	require.ErrorIs(t, err, ErrFull)
+
+	batch, err := s.next()
+	require.NoError(t, err)
+	assert.Equal(t, []dto.Metrics{counter("PollCount", 1)}, batch)
+}
+
+func TestSpool_Drain(t *testing.T) {
+	require.NoError(t, logger.Init())
+	s, err := New(t.TempDir(), 0, 2)
+	require.NoError(t, err)
+	require.NoError(t, s.Push([]dto.Metrics{counter("PollCount", 1), gauge("Alloc", 1), gauge("Sys", 1)}))
+
+	mu := sync.Mutex{}
+	attempts := 0
+	sent := make([][]dto.Metrics, 0)
+	send := func(_ context.Context, metrics []dto.Metrics) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			return errors.New("server is down")
+		}
+		sent = append(sent, metrics)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Drain(ctx, send)
+		close(done)
+	}()
+	require.Eventually(t, func() bool {
+		return s.Len() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, [][]dto.Metrics{
+		{counter("PollCount", 1), gauge("Alloc", 1)},
+		{gauge("Sys", 1)},
+	}, sent)
+}
@@ -0,0 +1,151 @@
+package statistic
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+// Aggregation modes of gauges.
+const (
+	// AggregationLast report only last value of gauge.
+	AggregationLast = "last"
+	// AggregationSuffixed report min, max and average over report interval as gauges with suffixes.
+	AggregationSuffixed = "suffixed"
+	// AggregationSummary report min, max and sum as gauges with suffixes and count of samples as counter,
+	// so average can be calculated on server for any period.
+	AggregationSummary = "summary"
+)
+
+// Suffixes of aggregated gauges.
+const (
+	SuffixMin   = "_min"
+	SuffixMax   = "_max"
+	SuffixAvg   = "_avg"
+	SuffixSum   = "_sum"
+	SuffixCount = "_count"
+)
+
+// Aggregator accumulates gauges sampled on every poll and reports aggregates once per report interval.
+// Last value is always reported under original metric name.
+type Aggregator struct {
+	modes       map[string]string
+	windows     map[string]*gaugeWindow
+	defaultMode string
+}
+
+type gaugeWindow struct {
+	mode  string
+	gauge MonitorValue
+	min   float64
+	max   float64
+	sum   float64
+	count int64
+}
+
+// NewAggregator constructor for Aggregator.
+func NewAggregator(cnfg config.AggregationConfig) (*Aggregator, error) {
+	for name, mode := range cnfg.Metrics {
+		if err := validateAggregationMode(mode); err != nil {
+			return nil, fmt.Errorf("invalid aggregation of %s: %w", name, err)
+		}
+	}
+	if err := validateAggregationMode(cnfg.Default); err != nil {
+		return nil, fmt.Errorf("invalid default aggregation: %w", err)
+	}
+
+	return &Aggregator{
+		modes:       cnfg.Metrics,
+		windows:     make(map[string]*gaugeWindow),
+		defaultMode: cnfg.Default,
+	}, nil
+}
+
+// SetAggregator set aggregation of gauges over report interval.
+func (monitor *Monitor) SetAggregator(aggregator *Aggregator) {
+	monitor.aggregator = aggregator
+}
+
+func validateAggregationMode(mode string) error {
+	switch mode {
+	case "", AggregationLast, AggregationSuffixed, AggregationSummary:
+		return nil
+	default:
+		return fmt.Errorf("unknown aggregation mode %q", mode)
+	}
+}
+
+func (a *Aggregator) mode(name string) string {
+	if mode, ok := a.modes[name]; ok {
+		return mode
+	}
+	return a.defaultMode
+}
+
+// observe add sampled gauge to window of current report interval.
+func (a *Aggregator) observe(value MonitorValue) {
+	if value.Type != entity.TypeGauge {
+		return
+	}
+	mode := a.mode(value.Name)
+	if mode != AggregationSuffixed && mode != AggregationSummary {
+		return
+	}
+
+	window, ok := a.windows[value.ID()]
+	if !ok {
+		window = newGaugeWindow(value, mode)
+		a.windows[value.ID()] = window
+	}
+	window.min = math.Min(window.min, value.Value)
+	window.max = math.Max(window.max, value.Value)
+	window.sum += value.Value
+	window.count++
+}
+
+// flush write aggregates of finished report interval to data and start new interval.
+// Aggregates of gauges without samples in the interval are removed from data.
+func (a *Aggregator) flush(data map[string]MonitorValue) {
+	for id, window := range a.windows {
+		if window.count == 0 {
+			for _, suffix := range []string{SuffixMin, SuffixMax, SuffixAvg, SuffixSum} {
+				delete(data, window.derived(suffix, 0).ID())
+			}
+			delete(a.windows, id)
+			continue
+		}
+
+		aggregates := []MonitorValue{
+			window.derived(SuffixMin, window.min),
+			window.derived(SuffixMax, window.max),
+		}
+		if window.mode == AggregationSuffixed {
+			aggregates = append(aggregates, window.derived(SuffixAvg, window.sum/float64(window.count)))
+		} else {
+			count := window.derived(SuffixCount, 0)
+			count.Type = entity.TypeCounter
+			count.Delta = data[count.ID()].Delta + window.count
+			aggregates = append(aggregates, window.derived(SuffixSum, window.sum), count)
+		}
+		for _, aggregate := range aggregates {
+			data[aggregate.ID()] = aggregate
+		}
+		a.windows[id] = newGaugeWindow(window.gauge, window.mode)
+	}
+}
+
+func newGaugeWindow(gauge MonitorValue, mode string) *gaugeWindow {
+	return &gaugeWindow{gauge: gauge, mode: mode, min: math.Inf(1), max: math.Inf(-1)}
+}
+
+// derived create gauge with suffixed name and labels of aggregated gauge.
+func (w *gaugeWindow) derived(suffix string, value float64) MonitorValue {
+	return MonitorValue{
+		Name:   w.gauge.Name + suffix,
+		Type:   entity.TypeGauge,
+		Value:  value,
+		Labels: w.gauge.Labels,
+	}
+}
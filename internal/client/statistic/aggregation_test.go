@@ -0,0 +1,87 @@
+package statistic
+
+import (
+	"testing"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAggregator(t *testing.T) {
+	tests := []struct {
+		name    string
+		cnfg    config.AggregationConfig
+		wantErr bool
+	}{
+		{
+			name: "success case",
+			cnfg: config.AggregationConfig{
+				Metrics: map[string]string{"Alloc": AggregationSuffixed, "Sys": AggregationLast},
+				Default: AggregationSummary,
+			},
+		},
+		{
+			name:    "invalid metric mode case",
+			cnfg:    config.AggregationConfig{Metrics: map[string]string{"Alloc": "median"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid default mode case",
+			cnfg:    config.AggregationConfig{Default: "median"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAggregator(tt.cnfg)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMonitor_prepareReportAggregation(t *testing.T) {
+	aggregator, err := NewAggregator(config.AggregationConfig{
+		Metrics: map[string]string{"CPU": AggregationSuffixed, "Queue": AggregationSummary},
+	})
+	require.NoError(t, err)
+	monitor := New(1)
+	monitor.SetAggregator(aggregator)
+
+	for _, sample := range []float64{10, 40, 25} {
+		monitor.setGaugeValue("CPU", sample)
+		monitor.setGaugeValue("Sys", sample)
+		monitor.mergeValues([]MonitorValue{gaugeValue("Queue", sample)})
+	}
+	got := make(map[string]MonitorValue)
+	for _, value := range monitor.prepareReport() {
+		got[value.Name] = value
+	}
+	assert.Equal(t, map[string]MonitorValue{
+		"CPU":         gaugeValue("CPU", 25),
+		"CPU_min":     gaugeValue("CPU_min", 10),
+		"CPU_max":     gaugeValue("CPU_max", 40),
+		"CPU_avg":     gaugeValue("CPU_avg", 25),
+		"Sys":         gaugeValue("Sys", 25),
+		"Queue":       gaugeValue("Queue", 25),
+		"Queue_min":   gaugeValue("Queue_min", 10),
+		"Queue_max":   gaugeValue("Queue_max", 40),
+		"Queue_sum":   gaugeValue("Queue_sum", 75),
+		"Queue_count": {Name: "Queue_count", Type: entity.TypeCounter, Delta: 3},
+	}, got)
+
+	// report interval without samples.
+	monitor.prepareReport()
+	monitor.setGaugeValue("CPU", 5)
+	got = make(map[string]MonitorValue)
+	for _, value := range monitor.prepareReport() {
+		got[value.Name] = value
+	}
+	assert.Equal(t, gaugeValue("CPU_min", 5), got["CPU_min"], "new window is started after report")
+	assert.NotContains(t, got, "Queue_min", "aggregates of gauge without samples are removed")
+}
@@ -0,0 +1,222 @@
+package statistic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+const (
+	// DefaultCgroupRoot mount point of cgroup filesystem.
+	DefaultCgroupRoot = "/sys/fs/cgroup"
+
+	cgroupUnlimitedValue = "max"
+	// cgroupV1UnlimitedThreshold limits in cgroup v1 equal to page aligned max int64 when not set.
+	cgroupV1UnlimitedThreshold = 1 << 62
+	nanosecondsInMicrosecond   = 1000
+)
+
+const (
+	cgroupMemoryUsageName      = "CgroupMemoryUsage"
+	cgroupMemoryLimitName      = "CgroupMemoryLimit"
+	cgroupPidsName             = "CgroupPids"
+	cgroupPidsLimitName        = "CgroupPidsLimit"
+	cgroupCPUUsageName         = "CgroupCPUUsage"
+	cgroupCPUPeriodsName       = "CgroupCPUPeriods"
+	cgroupCPUThrottledName     = "CgroupCPUThrottledPeriods"
+	cgroupCPUThrottledTimeName = "CgroupCPUThrottledTime"
+)
+
+var errCgroupNotFound = errors.New("cgroup filesystem not found")
+
+// CgroupCollector collects container resources usage from cgroup filesystem.
+// Both cgroup v1 and v2 hierarchies are supported, version detected on every collect.
+//
+// Memory and pids are reported as gauges. Cpu usage and throttling are reported as counters,
+// time values are in microseconds.
+type CgroupCollector struct {
+	previous map[string]uint64
+	root     string
+}
+
+// NewCgroupCollector constructor for CgroupCollector. Empty root means DefaultCgroupRoot.
+func NewCgroupCollector(root string) *CgroupCollector {
+	if root == "" {
+		root = DefaultCgroupRoot
+	}
+	return &CgroupCollector{
+		root:     root,
+		previous: make(map[string]uint64),
+	}
+}
+
+// Collect read cgroup metrics of current container.
+func (c *CgroupCollector) Collect(_ context.Context) ([]MonitorValue, error) {
+	switch {
+	case fileExists(filepath.Join(c.root, "cgroup.controllers")):
+		return c.collectV2()
+	case fileExists(filepath.Join(c.root, "memory")):
+		return c.collectV1()
+	default:
+		return nil, fmt.Errorf("failed detect cgroup version in %s: %w", c.root, errCgroupNotFound)
+	}
+}
+
+func (c *CgroupCollector) collectV2() ([]MonitorValue, error) {
+	values := make([]MonitorValue, 0)
+	var errs []error
+
+	gauges := []struct {
+		name string
+		file string
+	}{
+		{name: cgroupMemoryUsageName, file: "memory.current"},
+		{name: cgroupMemoryLimitName, file: "memory.max"},
+		{name: cgroupPidsName, file: "pids.current"},
+		{name: cgroupPidsLimitName, file: "pids.max"},
+	}
+	for _, gauge := range gauges {
+		value, limited, err := readCgroupValue(filepath.Join(c.root, gauge.file))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if limited {
+			values = append(values, gaugeValue(gauge.name, float64(value)))
+		}
+	}
+
+	cpuStat, err := readCgroupStat(filepath.Join(c.root, "cpu.stat"))
+	if err != nil {
+		errs = append(errs, err)
+		return values, errors.Join(errs...)
+	}
+	values = append(values,
+		c.counterValue(cgroupCPUUsageName, cpuStat["usage_usec"]),
+		c.counterValue(cgroupCPUPeriodsName, cpuStat["nr_periods"]),
+		c.counterValue(cgroupCPUThrottledName, cpuStat["nr_throttled"]),
+		c.counterValue(cgroupCPUThrottledTimeName, cpuStat["throttled_usec"]),
+	)
+
+	return values, errors.Join(errs...)
+}
+
+func (c *CgroupCollector) collectV1() ([]MonitorValue, error) {
+	values := make([]MonitorValue, 0)
+	var errs []error
+
+	gauges := []struct {
+		name string
+		file string
+	}{
+		{name: cgroupMemoryUsageName, file: "memory/memory.usage_in_bytes"},
+		{name: cgroupMemoryLimitName, file: "memory/memory.limit_in_bytes"},
+		{name: cgroupPidsName, file: "pids/pids.current"},
+		{name: cgroupPidsLimitName, file: "pids/pids.max"},
+	}
+	for _, gauge := range gauges {
+		value, limited, err := readCgroupValue(filepath.Join(c.root, gauge.file))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if limited && value < cgroupV1UnlimitedThreshold {
+			values = append(values, gaugeValue(gauge.name, float64(value)))
+		}
+	}
+
+	usage, _, err := readCgroupValue(filepath.Join(c.root, "cpuacct/cpuacct.usage"))
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		values = append(values, c.counterValue(cgroupCPUUsageName, usage/nanosecondsInMicrosecond))
+	}
+
+	cpuStat, err := readCgroupStat(filepath.Join(c.root, "cpu/cpu.stat"))
+	if err != nil {
+		errs = append(errs, err)
+		return values, errors.Join(errs...)
+	}
+	values = append(values,
+		c.counterValue(cgroupCPUPeriodsName, cpuStat["nr_periods"]),
+		c.counterValue(cgroupCPUThrottledName, cpuStat["nr_throttled"]),
+		c.counterValue(cgroupCPUThrottledTimeName, cpuStat["throttled_time"]/nanosecondsInMicrosecond),
+	)
+
+	return values, errors.Join(errs...)
+}
+
+// counterValue convert cumulative kernel counter to delta since previous collect.
+// First observation is used as baseline and reported with zero delta.
+func (c *CgroupCollector) counterValue(name string, current uint64) MonitorValue {
+	previous, ok := c.previous[name]
+	c.previous[name] = current
+	var delta int64
+	if ok && current >= previous {
+		delta = int64(current - previous)
+	}
+
+	return MonitorValue{
+		Name:  name,
+		Type:  entity.TypeCounter,
+		Delta: delta,
+	}
+}
+
+// readCgroupValue read single value cgroup file. Second returned value is false when limit is not set.
+func readCgroupValue(path string) (uint64, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed read cgroup file: %w", err)
+	}
+	content := strings.TrimSpace(string(data))
+	if content == cgroupUnlimitedValue {
+		return 0, false, nil
+	}
+	value, err := strconv.ParseUint(content, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid value in cgroup file %s: %w", path, err)
+	}
+
+	return value, true, nil
+}
+
+// readCgroupStat read flat keyed cgroup file, like cpu.stat.
+func readCgroupStat(path string) (map[string]uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed read cgroup stat file: %w", err)
+	}
+
+	stat := make(map[string]uint64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s in cgroup file %s: %w", fields[0], path, err)
+		}
+		stat[fields[0]] = value
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed scan cgroup stat file %s: %w", path, err)
+	}
+
+	return stat, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,167 @@
+package statistic
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCgroupCollector_Collect(t *testing.T) {
+	tests := []struct {
+		name       string
+		root       string
+		wantGauges map[string]float64
+		notWant    []string
+		wantErr    bool
+	}{
+		{
+			name: "cgroup v2 case",
+			root: "testdata/cgroup/v2",
+			wantGauges: map[string]float64{
+				cgroupMemoryUsageName: 104857600,
+				cgroupMemoryLimitName: 536870912,
+				cgroupPidsName:        12,
+				cgroupPidsLimitName:   100,
+			},
+		},
+		{
+			name: "cgroup v2 without limits case",
+			root: "testdata/cgroup/v2-unlimited",
+			wantGauges: map[string]float64{
+				cgroupMemoryUsageName: 104857600,
+				cgroupPidsName:        3,
+			},
+			notWant: []string{cgroupMemoryLimitName, cgroupPidsLimitName},
+		},
+		{
+			name: "cgroup v1 case",
+			root: "testdata/cgroup/v1",
+			wantGauges: map[string]float64{
+				cgroupMemoryUsageName: 52428800,
+				cgroupPidsName:        7,
+				cgroupPidsLimitName:   64,
+			},
+			notWant: []string{cgroupMemoryLimitName},
+		},
+		{
+			name:    "cgroup not found case",
+			root:    "testdata/cgroup/not-exists",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := NewCgroupCollector(tt.root)
+			values, err := collector.Collect(context.Background())
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			got := make(map[string]MonitorValue, len(values))
+			for _, value := range values {
+				got[value.Name] = value
+			}
+			for name, want := range tt.wantGauges {
+				require.Contains(t, got, name)
+				assert.Equal(t, entity.TypeGauge, got[name].Type)
+				assert.Equal(t, want, got[name].Value)
+			}
+			for _, name := range tt.notWant {
+				assert.NotContains(t, got, name)
+			}
+			for _, name := range []string{cgroupCPUUsageName, cgroupCPUThrottledName, cgroupCPUThrottledTimeName} {
+				require.Contains(t, got, name)
+				assert.Equal(t, entity.TypeCounter, got[name].Type)
+				assert.Equal(t, int64(0), got[name].Delta)
+			}
+		})
+	}
+}
+
+func TestCgroupCollector_CollectCounterDeltas(t *testing.T) {
+	tests := []struct {
+		name        string
+		fixture     string
+		cpuStatFile string
+		newCPUStat  string
+		want        map[string]int64
+	}{
+		{
+			name:        "cgroup v2 case",
+			fixture:     "testdata/cgroup/v2",
+			cpuStatFile: "cpu.stat",
+			newCPUStat:  "usage_usec 2500000\nnr_periods 150\nnr_throttled 25\nthrottled_usec 80000\n",
+			want: map[string]int64{
+				cgroupCPUUsageName:         500000,
+				cgroupCPUPeriodsName:       50,
+				cgroupCPUThrottledName:     15,
+				cgroupCPUThrottledTimeName: 30000,
+			},
+		},
+		{
+			name:        "cgroup v1 case",
+			fixture:     "testdata/cgroup/v1",
+			cpuStatFile: "cpu/cpu.stat",
+			newCPUStat:  "nr_periods 210\nnr_throttled 21\nthrottled_time 41000000\n",
+			want: map[string]int64{
+				cgroupCPUUsageName:         0,
+				cgroupCPUPeriodsName:       10,
+				cgroupCPUThrottledName:     1,
+				cgroupCPUThrottledTimeName: 1000,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := copyFixture(t, tt.fixture)
+			collector := NewCgroupCollector(root)
+			_, err := collector.Collect(context.Background())
+			require.NoError(t, err)
+
+			err = os.WriteFile(filepath.Join(root, tt.cpuStatFile), []byte(tt.newCPUStat), 0600)
+			require.NoError(t, err)
+			values, err := collector.Collect(context.Background())
+			require.NoError(t, err)
+
+			got := make(map[string]int64, len(values))
+			for _, value := range values {
+				got[value.Name] = value.Delta
+			}
+			for name, want := range tt.want {
+				assert.Equal(t, want, got[name], name)
+			}
+		})
+	}
+}
+
+func copyFixture(t *testing.T, fixture string) string {
+	t.Helper()
+	root := t.TempDir()
+	err := filepath.WalkDir(fixture, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(fixture, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(root, relPath)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0750)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0600)
+	})
+	require.NoError(t, err)
+	return root
+}
@@ -0,0 +1,217 @@
+package statistic
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+// Collector source of metrics which Monitor polls on every poll interval.
+//
+// Gauge values replace previously collected ones, counter deltas are summed up with not reported deltas.
+// Gauges which collector stopped to return are removed from Monitor, unless collect was failed.
+type Collector interface {
+	Collect(ctx context.Context) ([]MonitorValue, error)
+}
+
+// NewCollectors create collectors enabled in agent config.
+func NewCollectors(cnfg *config.AgentConfig) ([]Collector, error) {
+	collectors := []Collector{withLabels(NewHostCollector(cnfg.HostMetrics), cnfg.HostMetrics.Labels)}
+	switch cnfg.RuntimeSource {
+	case "", config.RuntimeSourceMemStats:
+	case config.RuntimeSourceRuntime:
+		runtimeCollector, err := NewRuntimeCollector(cnfg.RuntimeMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("failed create runtime collector: %w", err)
+		}
+		collectors = append(collectors, withLabels(runtimeCollector, cnfg.RuntimeMetrics.Labels))
+	default:
+		return nil, fmt.Errorf("unknown runtime metrics source %q", cnfg.RuntimeSource)
+	}
+	if cnfg.CgroupMetrics.Enabled {
+		collectors = append(collectors, withLabels(NewCgroupCollector(cnfg.CgroupMetrics.Root), cnfg.CgroupMetrics.Labels))
+	}
+	if cnfg.ProcessMetrics.Enabled {
+		processCollector, err := NewProcessCollector(cnfg.ProcessMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("failed create process collector: %w", err)
+		}
+		collectors = append(collectors, withLabels(processCollector, cnfg.ProcessMetrics.Labels))
+	}
+	if len(cnfg.ExecMetrics.Commands) > 0 {
+		execCollector, err := NewExecCollector(cnfg.ExecMetrics, time.Duration(cnfg.PollInterval)*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("failed create exec collector: %w", err)
+		}
+		collectors = append(collectors, execCollector)
+	}
+	if len(cnfg.ScrapeMetrics.Targets) > 0 {
+		scrapeCollector, err := NewScrapeCollector(cnfg.ScrapeMetrics, time.Duration(cnfg.PollInterval)*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("failed create scrape collector: %w", err)
+		}
+		collectors = append(collectors, scrapeCollector)
+	}
+	if len(cnfg.LogMetrics.Files) > 0 {
+		logCollector, err := NewLogCollector(cnfg.LogMetrics, time.Duration(cnfg.PollInterval)*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("failed create log collector: %w", err)
+		}
+		collectors = append(collectors, logCollector)
+	}
+	if cnfg.PushMetrics.Address != "" {
+		pushCollector, err := NewPushCollector(cnfg.PushMetrics, time.Duration(cnfg.ReportInterval)*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("failed create push collector: %w", err)
+		}
+		collectors = append(collectors, withLabels(pushCollector, cnfg.PushMetrics.Labels))
+	}
+
+	return collectors, nil
+}
+
+// BackgroundCollector collector which gathers metrics in own goroutine between polls.
+// Run is started by Monitor.CollectStat and must return when context is done.
+type BackgroundCollector interface {
+	Collector
+	Run(ctx context.Context)
+}
+
+func (monitor *Monitor) startBackgroundCollectors(ctx context.Context) *sync.WaitGroup {
+	wg := &sync.WaitGroup{}
+	for _, collector := range monitor.collectors {
+		backgroundCollector, ok := collector.(BackgroundCollector)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			backgroundCollector.Run(ctx)
+		}()
+	}
+	return wg
+}
+
+func (monitor *Monitor) collectFromCollectors(ctx context.Context) {
+	for i, collector := range monitor.collectors {
+		values, err := collector.Collect(ctx)
+		if err != nil {
+			logger.Log.Warnf("failed collect metrics: %v", err)
+		}
+		monitor.Mutex.Lock()
+		monitor.mergeValues(values)
+		if err == nil {
+			monitor.collected[i] = monitor.pruneStaleGauges(monitor.collected[i], values)
+		}
+		monitor.Mutex.Unlock()
+	}
+}
+
+func (monitor *Monitor) mergeValues(values []MonitorValue) {
+	for _, value := range values {
+		id := value.ID()
+		if value.Type == entity.TypeCounter {
+			value.Delta += monitor.Data[id].Delta
+		} else if monitor.aggregator != nil {
+			monitor.aggregator.observe(value)
+		}
+		monitor.Data[id] = value
+	}
+}
+
+// pruneStaleGauges remove gauges which were collected previously, but absent in current values.
+// Returns IDs of current values for next call.
+func (monitor *Monitor) pruneStaleGauges(previous map[string]struct{}, values []MonitorValue) map[string]struct{} {
+	current := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		current[value.ID()] = struct{}{}
+	}
+	for id := range previous {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		if stale, ok := monitor.Data[id]; ok && stale.Type == entity.TypeGauge {
+			delete(monitor.Data, id)
+		}
+	}
+
+	return current
+}
+
+// sourceValues storage of values gathered by background collector from several sources.
+// Last gauges of every source are kept until source returns new ones, counters are summed up until drain.
+type sourceValues struct {
+	gauges   map[int][]MonitorValue
+	counters map[string]MonitorValue
+	mu       sync.Mutex
+}
+
+func newSourceValues() *sourceValues {
+	return &sourceValues{
+		gauges:   make(map[int][]MonitorValue),
+		counters: make(map[string]MonitorValue),
+	}
+}
+
+func (s *sourceValues) store(sourceID int, values []MonitorValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gauges := make([]MonitorValue, 0, len(values))
+	for _, value := range values {
+		if value.Type == entity.TypeCounter {
+			s.counters[value.ID()] = addDelta(s.counters[value.ID()], value)
+			continue
+		}
+		gauges = append(gauges, value)
+	}
+	s.gauges[sourceID] = gauges
+}
+
+func (s *sourceValues) drain() []MonitorValue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make([]MonitorValue, 0, len(s.counters))
+	for _, gauges := range s.gauges {
+		values = append(values, gauges...)
+	}
+	for _, counter := range s.counters {
+		values = append(values, counter)
+	}
+	s.counters = make(map[string]MonitorValue)
+
+	return values
+}
+
+// runEvery call fn immediately and then every interval until context is done.
+func runEvery(ctx context.Context, interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		fn()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// addDelta sum up delta of counter with the same series.
+func addDelta(counter, value MonitorValue) MonitorValue {
+	value.Delta += counter.Delta
+	return value
+}
+
+func gaugeValue(name string, value float64) MonitorValue {
+	return MonitorValue{
+		Name:  name,
+		Type:  entity.TypeGauge,
+		Value: value,
+	}
+}
@@ -0,0 +1,52 @@
+package statistic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubCollector struct {
+	err    error
+	rounds [][]MonitorValue
+}
+
+func (s *stubCollector) Collect(context.Context) ([]MonitorValue, error) {
+	values := s.rounds[0]
+	s.rounds = s.rounds[1:]
+	return values, s.err
+}
+
+func TestMonitor_collectFromCollectors(t *testing.T) {
+	require.NoError(t, logger.Init())
+	collector := &stubCollector{rounds: [][]MonitorValue{
+		{
+			gaugeValue("ProcessRSS_nginx", 10),
+			gaugeValue("ProcessRSS_bash", 20),
+			{Name: "Events", Type: entity.TypeCounter, Delta: 2},
+		},
+		{
+			gaugeValue("ProcessRSS_nginx", 15),
+			{Name: "Events", Type: entity.TypeCounter, Delta: 3},
+		},
+		{},
+	}}
+	monitor := New(1, collector)
+
+	monitor.collectFromCollectors(context.Background())
+	assert.Len(t, monitor.Data, 3)
+
+	monitor.collectFromCollectors(context.Background())
+	assert.Equal(t, float64(15), monitor.Data["ProcessRSS_nginx"].Value)
+	assert.NotContains(t, monitor.Data, "ProcessRSS_bash")
+	assert.Equal(t, int64(5), monitor.Data["Events"].Delta)
+
+	collector.err = assert.AnError
+	monitor.collectFromCollectors(context.Background())
+	assert.Contains(t, monitor.Data, "ProcessRSS_nginx", "gauges must not be pruned after failed collect")
+	assert.Equal(t, int64(5), monitor.Data["Events"].Delta, "counters must not be pruned")
+}
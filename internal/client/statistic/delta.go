@@ -0,0 +1,72 @@
+package statistic
+
+import (
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+// SetDeltaReport enable reporting of only changed metrics: gauges with value different from
+// last delivered one and counters with not zero delta. All gauges are sent every fullResyncInterval.
+func (monitor *Monitor) SetDeltaReport(fullResyncInterval time.Duration) {
+	monitor.Mutex.Lock()
+	defer monitor.Mutex.Unlock()
+	monitor.deltaReport = true
+	monitor.fullResyncInterval = fullResyncInterval
+	monitor.acked = make(map[string]float64)
+}
+
+// selectChanged filter report data in delta report mode.
+func (monitor *Monitor) selectChanged(data []MonitorValue, now time.Time) []MonitorValue {
+	monitor.Mutex.Lock()
+	defer monitor.Mutex.Unlock()
+	fullResync := now.Sub(monitor.lastFullResync) >= monitor.fullResyncInterval
+	if fullResync {
+		monitor.resyncStartedAt = now
+		monitor.resyncPending = make(map[string]struct{})
+	}
+
+	changed := make([]MonitorValue, 0, len(data))
+	for _, value := range data {
+		if value.Type == entity.TypeCounter && value.Delta == 0 {
+			continue
+		}
+		if value.Type == entity.TypeGauge && !fullResync {
+			if acked, ok := monitor.acked[value.ID()]; ok && acked == value.Value {
+				continue
+			}
+		}
+		if fullResync && value.Type == entity.TypeGauge {
+			monitor.resyncPending[value.ID()] = struct{}{}
+		}
+		changed = append(changed, value)
+	}
+	if fullResync {
+		monitor.finishResync()
+	}
+	return changed
+}
+
+// acknowledge remember values of delivered gauges.
+func (monitor *Monitor) acknowledge(data []MonitorValue) {
+	monitor.Mutex.Lock()
+	defer monitor.Mutex.Unlock()
+	for _, value := range data {
+		if value.Type == entity.TypeGauge {
+			id := value.ID()
+			monitor.acked[id] = value.Value
+			delete(monitor.resyncPending, id)
+		}
+	}
+	monitor.finishResync()
+}
+
+// finishResync move time of last full resync, when all gauges of full report are delivered.
+// Otherwise, full resync is repeated by next report.
+func (monitor *Monitor) finishResync() {
+	if monitor.resyncPending == nil || len(monitor.resyncPending) > 0 {
+		return
+	}
+	monitor.lastFullResync = monitor.resyncStartedAt
+	monitor.resyncPending = nil
+}
@@ -0,0 +1,99 @@
+package statistic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/client/sender"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitor_selectChanged(t *testing.T) {
+	monitor := New(1)
+	monitor.SetDeltaReport(time.Minute)
+	for i := 0; i < 4*ChunkForRequestSize; i++ {
+		monitor.setGaugeValue(fmt.Sprintf("Gauge%d", i), float64(i))
+	}
+	monitor.Data["PollCount"] = MonitorValue{Name: "PollCount", Type: entity.TypeCounter, Delta: 1}
+	monitor.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter}
+	monitor.SetSender(sender.ReportSenderFunc(func(context.Context, string) error {
+		return nil
+	}))
+	start := time.Now()
+
+	data := monitor.selectChanged(monitor.prepareReport(), start)
+	assert.Len(t, data, 4*ChunkForRequestSize+1, "zero delta counter is skipped")
+	assert.Len(t, chunkMonitorValueSlice(data, ChunkForRequestSize), 5)
+	monitor.reportStat(context.Background(), data)
+
+	monitor.setGaugeValue("Gauge0", 100)
+	monitor.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter, Delta: 2}
+	data = monitor.selectChanged(monitor.prepareReport(), start.Add(time.Second))
+	assert.ElementsMatch(t, []MonitorValue{
+		gaugeValue("Gauge0", 100),
+		{Name: "Events", Type: entity.TypeCounter, Delta: 2},
+	}, data)
+	assert.Len(t, chunkMonitorValueSlice(data, ChunkForRequestSize), 1)
+	monitor.reportStat(context.Background(), data)
+
+	data = monitor.selectChanged(monitor.prepareReport(), start.Add(time.Minute))
+	assert.Len(t, data, 4*ChunkForRequestSize, "all gauges are sent on full resync")
+}
+
+func TestMonitor_reportStatDeltaFailed(t *testing.T) {
+	require.NoError(t, logger.Init())
+	monitor := New(1)
+	monitor.SetDeltaReport(time.Minute)
+	monitor.setGaugeValue("Alloc", 1)
+	start := time.Now()
+
+	data := monitor.selectChanged(monitor.prepareReport(), start)
+	require.Len(t, data, 1)
+	monitor.SetSender(sender.ReportSenderFunc(func(context.Context, string) error {
+		return errors.New("connection refused")
+	}))
+	monitor.reportStat(context.Background(), data)
+
+	data = monitor.selectChanged(monitor.prepareReport(), start.Add(time.Second))
+	assert.Equal(t, []MonitorValue{gaugeValue("Alloc", 1)}, data, "not delivered gauge is sent again")
+}
+
+func TestMonitor_reportStatFullResyncFailed(t *testing.T) {
+	require.NoError(t, logger.Init())
+	monitor := New(1)
+	monitor.SetDeltaReport(time.Minute)
+	monitor.setGaugeValue("Alloc", 1)
+	monitor.setGaugeValue("Sys", 2)
+	start := time.Now()
+
+	data := monitor.selectChanged(monitor.prepareReport(), start)
+	require.Len(t, data, 2)
+	monitor.SetSender(sender.ReportSenderFunc(func(context.Context, string) error {
+		return nil
+	}))
+	monitor.reportStat(context.Background(), data)
+
+	data = monitor.selectChanged(monitor.prepareReport(), start.Add(time.Minute))
+	require.Len(t, data, 2, "full resync")
+	monitor.SetSender(sender.ReportSenderFunc(func(context.Context, string) error {
+		return errors.New("connection refused")
+	}))
+	monitor.reportStat(context.Background(), data[:1])
+	monitor.SetSender(sender.ReportSenderFunc(func(context.Context, string) error {
+		return nil
+	}))
+	monitor.reportStat(context.Background(), data[1:])
+
+	data = monitor.selectChanged(monitor.prepareReport(), start.Add(time.Minute+time.Second))
+	assert.Len(t, data, 2, "failed full resync is repeated by next report")
+	monitor.reportStat(context.Background(), data)
+
+	data = monitor.selectChanged(monitor.prepareReport(), start.Add(time.Minute+2*time.Second))
+	assert.Empty(t, data, "delivered full resync moves time of last one")
+}
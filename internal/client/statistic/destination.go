@@ -0,0 +1,308 @@
+package statistic
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ilya372317/must-have-metrics/internal/client/sender"
+	"github.com/ilya372317/must-have-metrics/internal/client/spool"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/promtext"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+// LabelDestination label of self-metrics, which are reported for every destination.
+const LabelDestination = "destination"
+
+// destinationQueueSize count of reports, which wait for delivery to one of several destinations.
+// Reports over limit are not sent to that destination, their counters are sent with next report.
+const destinationQueueSize = 64
+
+// Destination server, which Monitor reports metrics to. Every destination has own sender
+// with retry state and own spool, so slow or unavailable destination does not affect others.
+type Destination struct {
+	Sender sender.ReportSender
+	Spool  *spool.Spool
+	state  *destinationState
+	Name   string
+}
+
+// destinationState delivery state of one of several destinations.
+type destinationState struct {
+	// tasks queue of reports, which is served by own workers of destination while ReportStat is running.
+	tasks chan func()
+	// owed counters, which were not delivered to destination, by series ID.
+	owed map[string]dto.Metrics
+	mu   sync.Mutex
+}
+
+// SetDestinations set servers, which every report is broadcast to.
+//
+// When there are several destinations, every destination gets own copy of counter deltas:
+// deltas are taken from collected metrics as soon as report is queued, and deltas not delivered
+// to destination are kept by destination and added to its next report. Gauges are considered delivered,
+// when at least one destination sent or spooled them. Queues of destinations are served by own workers,
+// so slow destination does not delay reports to others.
+func (monitor *Monitor) SetDestinations(destinations ...Destination) {
+	for i := range destinations {
+		if destinations[i].state == nil {
+			destinations[i].state = &destinationState{owed: make(map[string]dto.Metrics)}
+		}
+	}
+	monitor.destinations = destinations
+}
+
+// SetSpool set queue for metrics failed to send. Queued metrics are sent by ReportStat before new ones.
+func (monitor *Monitor) SetSpool(s *spool.Spool) {
+	monitor.defaultDestination().Spool = s
+}
+
+// SetSender set transport used for report metrics to server.
+func (monitor *Monitor) SetSender(reportSender sender.ReportSender) {
+	monitor.defaultDestination().Sender = reportSender
+}
+
+func (monitor *Monitor) defaultDestination() *Destination {
+	if len(monitor.destinations) == 0 {
+		monitor.destinations = []Destination{{}}
+	}
+	return &monitor.destinations[0]
+}
+
+// drainSpools send metrics queued in spools of destinations until context is done.
+func (monitor *Monitor) drainSpools(ctx context.Context, wg *sync.WaitGroup) {
+	for i := range monitor.destinations {
+		destination := &monitor.destinations[i]
+		if destination.Spool == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			destination.Spool.Drain(ctx, func(ctx context.Context, metricsList []dto.Metrics) error {
+				return monitor.send(ctx, destination, createBody(metricsList))
+			})
+		}()
+	}
+}
+
+// startDestinationWorkers start own workers of every destination, if reports are broadcast to several destinations.
+func (monitor *Monitor) startDestinationWorkers() {
+	if len(monitor.destinations) < 2 {
+		return
+	}
+	for i := range monitor.destinations {
+		state := monitor.destinations[i].state
+		tasks := make(chan func(), destinationQueueSize)
+		state.mu.Lock()
+		state.tasks = tasks
+		state.mu.Unlock()
+		for k := 0; k < cap(monitor.ReportTaskCh); k++ {
+			monitor.startWorker(tasks, k)
+		}
+	}
+}
+
+// stopDestinationWorkers wait until queued reports are delivered and stop workers of destinations.
+// Reports broadcast after that are delivered directly by caller.
+func (monitor *Monitor) stopDestinationWorkers() {
+	monitor.deliveries.Wait()
+	for i := range monitor.destinations {
+		state := monitor.destinations[i].state
+		if state == nil {
+			continue
+		}
+		state.mu.Lock()
+		if state.tasks != nil {
+			close(state.tasks)
+			state.tasks = nil
+		}
+		state.mu.Unlock()
+	}
+}
+
+// broadcast deliver metrics to all destinations. Counter deltas of metrics are owned by destinations,
+// so they should be released as delivered by caller. Given acknowledge is called once,
+// when first destination accepted metrics. While destination workers are running, metrics are queued
+// to destinations and broadcast does not wait for delivery, otherwise it delivers metrics concurrently and waits.
+func (monitor *Monitor) broadcast(ctx context.Context, metricsList []dto.Metrics, acknowledge func()) {
+	once := &sync.Once{}
+	wg := &sync.WaitGroup{}
+	for i := range monitor.destinations {
+		destination := &monitor.destinations[i]
+		monitor.deliveries.Add(1)
+		task := func() {
+			defer monitor.deliveries.Done()
+			if monitor.deliverOwned(ctx, destination, metricsList, true) {
+				once.Do(acknowledge)
+			}
+		}
+		if monitor.enqueue(destination, task, metricsList) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			task()
+		}()
+	}
+	wg.Wait()
+}
+
+// enqueue put delivery task to queue of destination. Returns false, if destination workers are not running.
+// When queue is full, report is not sent to destination and its counters are kept for next report.
+func (monitor *Monitor) enqueue(destination *Destination, task func(), metricsList []dto.Metrics) bool {
+	state := destination.state
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.tasks == nil {
+		return false
+	}
+	select {
+	case state.tasks <- task:
+	default:
+		logger.Log.Warnf("report queue%s is full, %d metrics are postponed", destination.logSuffix(), len(metricsList))
+		state.keepCounters(metricsList)
+		monitor.deliveries.Done()
+	}
+	return true
+}
+
+// deliverOwned deliver metrics along with counters owed to destination. Owed counters failed to deliver
+// are kept by destination, as well as counters of given metrics, if they are owned by destination.
+func (monitor *Monitor) deliverOwned(
+	ctx context.Context,
+	destination *Destination,
+	metricsList []dto.Metrics,
+	owned bool,
+) bool {
+	state := destination.state
+	if state == nil {
+		return monitor.deliver(ctx, destination, metricsList)
+	}
+	state.mu.Lock()
+	owed := state.owed
+	state.owed = make(map[string]dto.Metrics)
+	state.mu.Unlock()
+
+	if monitor.deliver(ctx, destination, mergeCounters(metricsList, owed)) {
+		return true
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.keepCounters(mergeCounters(nil, owed))
+	if owned {
+		state.keepCounters(metricsList)
+	}
+	return false
+}
+
+// keepCounters add counters of metrics to owed ones. Should be called under state lock.
+func (s *destinationState) keepCounters(metricsList []dto.Metrics) {
+	for _, metrics := range metricsList {
+		if metrics.MType != entity.TypeCounter || metrics.Delta == nil {
+			continue
+		}
+		id := promtext.SeriesID(metrics.ID, metrics.Labels)
+		if owed, ok := s.owed[id]; ok {
+			delta := *owed.Delta + *metrics.Delta
+			metrics.Delta = &delta
+		}
+		s.owed[id] = metrics
+	}
+}
+
+// deliver send metrics to destination or queue them in destination spool.
+func (monitor *Monitor) deliver(ctx context.Context, destination *Destination, metricsList []dto.Metrics) bool {
+	// Metrics are queued while spool is not empty, so spooled gauges never overwrite newer values on server.
+	if destination.Spool != nil && destination.Spool.Len() > 0 {
+		return pushToSpool(destination, metricsList)
+	}
+
+	if err := monitor.send(ctx, destination, createBody(metricsList)); err != nil {
+		logger.Log.Errorf("failed report metrics%s: %v", destination.logSuffix(), err)
+		if destination.Spool != nil {
+			return pushToSpool(destination, metricsList)
+		}
+		return false
+	}
+	return true
+}
+
+func pushToSpool(destination *Destination, metricsList []dto.Metrics) bool {
+	if err := destination.Spool.Push(metricsList); err != nil {
+		logger.Log.Errorf("failed spool %d metrics%s: %v", len(metricsList), destination.logSuffix(), err)
+		return false
+	}
+	return true
+}
+
+// queueDepth return count of reports waiting in queue of destination.
+func (d *Destination) queueDepth() int {
+	if d.state == nil {
+		return 0
+	}
+	d.state.mu.Lock()
+	defer d.state.mu.Unlock()
+	return len(d.state.tasks)
+}
+
+func (d *Destination) logSuffix() string {
+	if d.Name == "" {
+		return ""
+	}
+	return " to " + d.Name
+}
+
+// inheritOwed take over counters owed to destinations of previous monitor, which have the same names.
+func (monitor *Monitor) inheritOwed(previous *Monitor) {
+	for i := range previous.destinations {
+		previousState := previous.destinations[i].state
+		if previousState == nil {
+			continue
+		}
+		for j := range monitor.destinations {
+			state := monitor.destinations[j].state
+			if state == nil || monitor.destinations[j].Name != previous.destinations[i].Name {
+				continue
+			}
+			previousState.mu.Lock()
+			state.mu.Lock()
+			state.keepCounters(mergeCounters(nil, previousState.owed))
+			state.mu.Unlock()
+			previousState.mu.Unlock()
+		}
+	}
+}
+
+// mergeCounters return metrics with owed counters: deltas of the same series are summed up,
+// other owed counters are appended. Given metrics are not changed.
+func mergeCounters(metricsList []dto.Metrics, owed map[string]dto.Metrics) []dto.Metrics {
+	if len(owed) == 0 {
+		return metricsList
+	}
+	merged := make([]dto.Metrics, 0, len(metricsList)+len(owed))
+	added := make(map[string]struct{}, len(owed))
+	for _, metrics := range metricsList {
+		id := promtext.SeriesID(metrics.ID, metrics.Labels)
+		if debt, ok := owed[id]; ok && metrics.MType == entity.TypeCounter && metrics.Delta != nil {
+			delta := *metrics.Delta + *debt.Delta
+			metrics.Delta = &delta
+			added[id] = struct{}{}
+		}
+		merged = append(merged, metrics)
+	}
+	ids := make([]string, 0, len(owed))
+	for id := range owed {
+		if _, ok := added[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		merged = append(merged, owed[id])
+	}
+	return merged
+}
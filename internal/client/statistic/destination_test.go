@@ -0,0 +1,171 @@
+package statistic
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/client/sender"
+	"github.com/ilya372317/must-have-metrics/internal/client/spool"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSender struct {
+	err    error
+	bodies []string
+	mu     sync.Mutex
+}
+
+func (s *recordingSender) Send(_ context.Context, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.bodies = append(s.bodies, body)
+	return nil
+}
+
+func TestMonitor_reportStatBroadcast(t *testing.T) {
+	require.NoError(t, logger.Init())
+	const body = `[{"delta":2,"id":"Events","type":"counter"}]`
+	tests := []struct {
+		name         string
+		oldErr       error
+		newErr       error
+		withSpool    bool
+		wantOld      []string
+		wantNew      []string
+		wantSpoolLen int
+	}{
+		{
+			name:    "all destinations available case",
+			wantOld: []string{body},
+			wantNew: []string{body},
+		},
+		{
+			name:    "one destination is down case",
+			oldErr:  errors.New("connection refused"),
+			wantNew: []string{body},
+		},
+		{
+			name:         "down destination with spool case",
+			oldErr:       errors.New("connection refused"),
+			withSpool:    true,
+			wantNew:      []string{body},
+			wantSpoolLen: 1,
+		},
+		{
+			name:   "all destinations are down case",
+			oldErr: errors.New("connection refused"),
+			newErr: errors.New("connection refused"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldServer := &recordingSender{err: tt.oldErr}
+			newServer := &recordingSender{err: tt.newErr}
+			oldDestination := Destination{Name: "old", Sender: oldServer}
+			if tt.withSpool {
+				oldSpool, err := spool.New(t.TempDir(), 0, ChunkForRequestSize)
+				require.NoError(t, err)
+				oldDestination.Spool = oldSpool
+			}
+			monitor := New(1)
+			monitor.SetDestinations(oldDestination, Destination{Name: "new", Sender: newServer})
+			monitor.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter, Delta: 2}
+
+			monitor.reportStat(context.Background(), monitor.prepareReport())
+
+			assert.Equal(t, tt.wantOld, oldServer.bodies)
+			assert.Equal(t, tt.wantNew, newServer.bodies)
+			assert.Equal(t, int64(0), monitor.Data["Events"].Delta, "counters are owned by destinations")
+			if tt.withSpool {
+				assert.Equal(t, tt.wantSpoolLen, oldDestination.Spool.Len())
+			}
+		})
+	}
+}
+
+func TestMonitor_reportStatBroadcastOwedCounters(t *testing.T) {
+	require.NoError(t, logger.Init())
+	oldServer := &recordingSender{err: errors.New("connection refused")}
+	newServer := &recordingSender{}
+	monitor := New(1)
+	monitor.SetDestinations(Destination{Name: "old", Sender: oldServer}, Destination{Name: "new", Sender: newServer})
+
+	monitor.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter, Delta: 2}
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+	oldServer.err = nil
+	monitor.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter, Delta: 3}
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+
+	assert.Equal(t, []string{`[{"delta":5,"id":"Events","type":"counter"}]`}, oldServer.bodies,
+		"counters failed to deliver are sent with next report of destination")
+	assert.Equal(t, []string{
+		`[{"delta":2,"id":"Events","type":"counter"}]`,
+		`[{"delta":3,"id":"Events","type":"counter"}]`,
+	}, newServer.bodies)
+}
+
+type blockingSender struct {
+	release chan struct{}
+}
+
+func (s *blockingSender) Send(ctx context.Context, _ string) error {
+	select {
+	case <-s.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestMonitor_broadcastSlowDestination(t *testing.T) {
+	require.NoError(t, logger.Init())
+	slowServer := &blockingSender{release: make(chan struct{})}
+	fastServer := &recordingSender{}
+	monitor := New(1)
+	monitor.SetDestinations(Destination{Name: "slow", Sender: slowServer}, Destination{Name: "fast", Sender: fastServer})
+	monitor.startDestinationWorkers()
+
+	for i := 0; i < 3; i++ {
+		monitor.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter, Delta: 1}
+		monitor.reportStat(context.Background(), monitor.prepareReport())
+	}
+	assert.Eventually(t, func() bool {
+		fastServer.mu.Lock()
+		defer fastServer.mu.Unlock()
+		return len(fastServer.bodies) == 3
+	}, time.Second, 10*time.Millisecond, "slow destination does not delay others")
+	assert.Equal(t, 2, monitor.Status().Destinations[0].QueueDepth)
+
+	close(slowServer.release)
+	monitor.stopDestinationWorkers()
+	assert.Equal(t, 0, monitor.Status().QueueDepth)
+}
+
+func TestMonitor_StatusDestinations(t *testing.T) {
+	monitor := New(1)
+	monitor.SetDestinations(
+		Destination{Name: "old", Sender: &circuitSender{state: sender.CircuitOpen}},
+		Destination{Name: "new", Sender: &circuitSender{state: sender.CircuitHalfOpen}},
+	)
+	status := monitor.Status()
+	assert.Equal(t, sender.CircuitHalfOpen, status.CircuitState, "state of first available destination")
+	assert.Equal(t, []DestinationStatus{
+		{Name: "old", CircuitState: sender.CircuitOpen},
+		{Name: "new", CircuitState: sender.CircuitHalfOpen},
+	}, status.Destinations)
+
+	monitor.collectStat()
+	assert.Equal(t, float64(sender.CircuitOpen),
+		monitor.Data[`agent_circuit_breaker_state{destination="old"}`].Value)
+	assert.Equal(t, float64(sender.CircuitHalfOpen),
+		monitor.Data[`agent_circuit_breaker_state{destination="new"}`].Value)
+}
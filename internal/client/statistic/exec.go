@@ -0,0 +1,268 @@
+package statistic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+// Formats of exec commands output.
+const (
+	ExecFormatLines = "lines"
+	ExecFormatJSON  = "json"
+)
+
+const (
+	defaultExecTimeout        = 10 * time.Second
+	defaultExecMaxConcurrency = 2
+	defaultExecMaxOutputSize  = 64 * 1024
+	execWaitDelay             = time.Second
+	execLineFieldsCount       = 3
+)
+
+var errOutputLimitExceeded = errors.New("output size limit exceeded")
+
+// ExecCollector runs configured commands by interval and parses their stdout as metrics.
+// Stderr of commands is written to log.
+//
+// Last gauges of every command are reported until command returns new ones,
+// counters are summed up between polls.
+type ExecCollector struct {
+	values          *sourceValues
+	semaphore       chan struct{}
+	commands        []config.ExecCommandConfig
+	defaultInterval time.Duration
+	maxOutputSize   int
+}
+
+// NewExecCollector constructor for ExecCollector.
+// Commands without configured interval are run every defaultInterval.
+func NewExecCollector(cnfg config.ExecMetricsConfig, defaultInterval time.Duration) (*ExecCollector, error) {
+	for _, command := range cnfg.Commands {
+		if command.Command == "" {
+			return nil, fmt.Errorf("empty command in exec collector %q", command.Name)
+		}
+		switch command.Format {
+		case "", ExecFormatLines, ExecFormatJSON:
+		default:
+			return nil, fmt.Errorf("invalid output format %q of exec collector %q", command.Format, command.Name)
+		}
+	}
+
+	maxConcurrency := int(cnfg.MaxConcurrency)
+	if maxConcurrency == 0 {
+		maxConcurrency = defaultExecMaxConcurrency
+	}
+	maxOutputSize := int(cnfg.MaxOutputSize)
+	if maxOutputSize == 0 {
+		maxOutputSize = defaultExecMaxOutputSize
+	}
+
+	return &ExecCollector{
+		values:          newSourceValues(),
+		semaphore:       make(chan struct{}, maxConcurrency),
+		commands:        cnfg.Commands,
+		defaultInterval: defaultInterval,
+		maxOutputSize:   maxOutputSize,
+	}, nil
+}
+
+// Run start running of commands by their intervals until context is done.
+func (c *ExecCollector) Run(ctx context.Context) {
+	wg := &sync.WaitGroup{}
+	for i := range c.commands {
+		wg.Add(1)
+		go func(commandID int) {
+			defer wg.Done()
+			c.runByInterval(ctx, commandID)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Collect return metrics parsed from commands output.
+func (c *ExecCollector) Collect(context.Context) ([]MonitorValue, error) {
+	return c.values.drain(), nil
+}
+
+func (c *ExecCollector) runByInterval(ctx context.Context, commandID int) {
+	interval := c.defaultInterval
+	if c.commands[commandID].Interval > 0 {
+		interval = time.Duration(c.commands[commandID].Interval) * time.Second
+	}
+	runEvery(ctx, interval, func() {
+		c.execute(ctx, commandID)
+	})
+}
+
+func (c *ExecCollector) execute(ctx context.Context, commandID int) {
+	select {
+	case c.semaphore <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	defer func() {
+		<-c.semaphore
+	}()
+
+	command := c.commands[commandID]
+	values, err := c.runCommand(ctx, command)
+	if err != nil {
+		logger.Log.Errorf("failed collect metrics from command %q: %v", command.Name, err)
+		return
+	}
+	c.values.store(commandID, addLabels(values, command.Labels))
+}
+
+func (c *ExecCollector) runCommand(ctx context.Context, command config.ExecCommandConfig) ([]MonitorValue, error) {
+	timeout := defaultExecTimeout
+	if command.Timeout > 0 {
+		timeout = time.Duration(command.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{limit: c.maxOutputSize}
+	stderr := &limitedBuffer{limit: c.maxOutputSize}
+	cmd := exec.CommandContext(ctx, command.Command, command.Args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = execWaitDelay
+
+	err := cmd.Run()
+	logStderr(command.Name, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed run command: %w", err)
+	}
+	if stdout.overflow {
+		return nil, fmt.Errorf("failed read command stdout: %w", errOutputLimitExceeded)
+	}
+
+	if command.Format == ExecFormatJSON {
+		return parseJSONOutput(stdout.Bytes())
+	}
+	return parseLinesOutput(stdout.Bytes())
+}
+
+// parseLinesOutput parse output in "name type value" lines. Empty lines and lines started with # are skipped.
+func parseLinesOutput(output []byte) ([]MonitorValue, error) {
+	values := make([]MonitorValue, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != execLineFieldsCount {
+			return nil, fmt.Errorf("invalid line %d: expected name, type and value", lineNumber)
+		}
+		value, err := parseMonitorValue(fields[0], fields[1], fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid line %d: %w", lineNumber, err)
+		}
+		values = append(values, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed scan output: %w", err)
+	}
+
+	return values, nil
+}
+
+// parseJSONOutput parse output in the same format as /updates request body.
+func parseJSONOutput(output []byte) ([]MonitorValue, error) {
+	metricsList := make([]dto.Metrics, 0)
+	if err := json.Unmarshal(output, &metricsList); err != nil {
+		return nil, fmt.Errorf("invalid json output: %w", err)
+	}
+
+	values := make([]MonitorValue, 0, len(metricsList))
+	for _, metrics := range metricsList {
+		value, err := monitorValueFromMetrics(metrics)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+func parseMonitorValue(name, typ, rawValue string) (MonitorValue, error) {
+	switch typ {
+	case entity.TypeGauge:
+		value, err := strconv.ParseFloat(rawValue, 64)
+		if err != nil {
+			return MonitorValue{}, fmt.Errorf("invalid gauge value of %s: %w", name, err)
+		}
+		return monitorValueFromMetrics(dto.Metrics{ID: name, MType: typ, Value: &value})
+	case entity.TypeCounter:
+		delta, err := strconv.ParseInt(rawValue, 10, 64)
+		if err != nil {
+			return MonitorValue{}, fmt.Errorf("invalid counter value of %s: %w", name, err)
+		}
+		return monitorValueFromMetrics(dto.Metrics{ID: name, MType: typ, Delta: &delta})
+	default:
+		return MonitorValue{}, fmt.Errorf("invalid type %q of %s", typ, name)
+	}
+}
+
+// monitorValueFromMetrics convert validated metrics DTO to MonitorValue.
+func monitorValueFromMetrics(metrics dto.Metrics) (MonitorValue, error) {
+	if ok, err := metrics.Validate(); !ok {
+		return MonitorValue{}, fmt.Errorf("invalid metrics %q: %w", metrics.ID, err)
+	}
+	if metrics.MType == entity.TypeCounter {
+		return MonitorValue{Name: metrics.ID, Type: entity.TypeCounter, Delta: *metrics.Delta, Labels: metrics.Labels}, nil
+	}
+
+	return MonitorValue{Name: metrics.ID, Type: entity.TypeGauge, Value: *metrics.Value, Labels: metrics.Labels}, nil
+}
+
+func logStderr(commandName string, stderr *limitedBuffer) {
+	scanner := bufio.NewScanner(bytes.NewReader(stderr.Bytes()))
+	for scanner.Scan() {
+		logger.Log.Warnf("command %q stderr: %s", commandName, scanner.Text())
+	}
+	if stderr.overflow {
+		logger.Log.Warnf("command %q stderr: %v", commandName, errOutputLimitExceeded)
+	}
+}
+
+// limitedBuffer buffer which discards data written after limit is reached.
+type limitedBuffer struct {
+	buffer   bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.buffer.Len()+len(p) > b.limit {
+		b.overflow = true
+		return len(p), nil
+	}
+	n, err := b.buffer.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("failed write to buffer: %w", err)
+	}
+	return n, nil
+}
+
+// Bytes return written data.
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buffer.Bytes()
+}
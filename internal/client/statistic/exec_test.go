@@ -0,0 +1,208 @@
+package statistic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLinesOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    []MonitorValue
+		wantErr bool
+	}{
+		{
+			name:   "success case",
+			output: "# queue stats\nQueueDepth gauge 42\n\nQueueProcessed counter 7\n",
+			want: []MonitorValue{
+				{Name: "QueueDepth", Type: entity.TypeGauge, Value: 42},
+				{Name: "QueueProcessed", Type: entity.TypeCounter, Delta: 7},
+			},
+		},
+		{
+			name:   "fractional and negative gauges case",
+			output: "CPUFraction gauge 0.125\nTemperature gauge -3.5\n",
+			want: []MonitorValue{
+				{Name: "CPUFraction", Type: entity.TypeGauge, Value: 0.125},
+				{Name: "Temperature", Type: entity.TypeGauge, Value: -3.5},
+			},
+		},
+		{
+			name:   "empty output case",
+			output: "",
+			want:   []MonitorValue{},
+		},
+		{
+			name:    "invalid fields count case",
+			output:  "QueueDepth 42\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid type case",
+			output:  "QueueDepth histogram 42\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid counter value case",
+			output:  "QueueProcessed counter 1.5\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLinesOutput([]byte(tt.output))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseJSONOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    []MonitorValue
+		wantErr bool
+	}{
+		{
+			name:   "success case",
+			output: `[{"id":"QueueDepth","type":"gauge","value":42},{"id":"QueueProcessed","type":"counter","delta":7}]`,
+			want: []MonitorValue{
+				{Name: "QueueDepth", Type: entity.TypeGauge, Value: 42},
+				{Name: "QueueProcessed", Type: entity.TypeCounter, Delta: 7},
+			},
+		},
+		{
+			name:    "invalid json case",
+			output:  `{"id":"QueueDepth"`,
+			wantErr: true,
+		},
+		{
+			name:    "gauge without value case",
+			output:  `[{"id":"QueueDepth","type":"gauge","delta":42}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJSONOutput([]byte(tt.output))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExecCollector_runCommand(t *testing.T) {
+	require.NoError(t, logger.Init())
+	tests := []struct {
+		name          string
+		command       config.ExecCommandConfig
+		maxOutputSize uint
+		want          []MonitorValue
+		wantErr       bool
+	}{
+		{
+			name: "lines format case",
+			command: config.ExecCommandConfig{
+				Name:    "queue",
+				Command: "sh",
+				Args:    []string{"-c", "echo 'QueueDepth gauge 3'; echo 'warning' >&2"},
+			},
+			want: []MonitorValue{{Name: "QueueDepth", Type: entity.TypeGauge, Value: 3}},
+		},
+		{
+			name: "json format case",
+			command: config.ExecCommandConfig{
+				Name:    "queue",
+				Command: "echo",
+				Args:    []string{`[{"id":"QueueProcessed","type":"counter","delta":5}]`},
+				Format:  ExecFormatJSON,
+			},
+			want: []MonitorValue{{Name: "QueueProcessed", Type: entity.TypeCounter, Delta: 5}},
+		},
+		{
+			name: "failed command case",
+			command: config.ExecCommandConfig{
+				Name:    "queue",
+				Command: "sh",
+				Args:    []string{"-c", "exit 1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "output limit case",
+			command: config.ExecCommandConfig{
+				Name:    "queue",
+				Command: "echo",
+				Args:    []string{"QueueDepth gauge 1234567890"},
+			},
+			maxOutputSize: 10,
+			wantErr:       true,
+		},
+		{
+			name: "timeout case",
+			command: config.ExecCommandConfig{
+				Name:    "queue",
+				Command: "sleep",
+				Args:    []string{"5"},
+				Timeout: 1,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, err := NewExecCollector(config.ExecMetricsConfig{
+				Commands:      []config.ExecCommandConfig{tt.command},
+				MaxOutputSize: tt.maxOutputSize,
+			}, time.Second)
+			require.NoError(t, err)
+			got, err := collector.runCommand(context.Background(), tt.command)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExecCollector_Collect(t *testing.T) {
+	require.NoError(t, logger.Init())
+	collector, err := NewExecCollector(config.ExecMetricsConfig{
+		Commands: []config.ExecCommandConfig{
+			{Name: "queue", Command: "echo", Args: []string{"QueueDepth gauge 3\nQueueProcessed counter 2"}},
+		},
+	}, time.Second)
+	require.NoError(t, err)
+
+	collector.execute(context.Background(), 0)
+	collector.execute(context.Background(), 0)
+	values, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []MonitorValue{
+		{Name: "QueueDepth", Type: entity.TypeGauge, Value: 3},
+		{Name: "QueueProcessed", Type: entity.TypeCounter, Delta: 4},
+	}, values)
+
+	values, err = collector.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []MonitorValue{{Name: "QueueDepth", Type: entity.TypeGauge, Value: 3}}, values)
+}
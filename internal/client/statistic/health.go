@@ -0,0 +1,91 @@
+package statistic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilya372317/must-have-metrics/internal/client/sender"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+)
+
+// HealthServer serves local endpoints with agent health and self-metrics.
+type HealthServer struct {
+	listener net.Listener
+	monitor  *Monitor
+}
+
+// NewHealthServer constructor for HealthServer. Address accepts the same values as push endpoint.
+func NewHealthServer(address string) (*HealthServer, error) {
+	listener, err := listenLocal(address)
+	if err != nil {
+		return nil, err
+	}
+	return &HealthServer{listener: listener}, nil
+}
+
+// Close stop listening, if server was not run.
+func (s *HealthServer) Close() error {
+	if err := s.listener.Close(); err != nil {
+		return fmt.Errorf("failed close health endpoint: %w", err)
+	}
+	return nil
+}
+
+// Run serve health of given monitor until context is done.
+func (s *HealthServer) Run(ctx context.Context, monitor *Monitor) {
+	s.monitor = monitor
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: localReadHeaderTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), localShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(timeoutCtx); err != nil {
+			logger.Log.Errorf("failed shutdown health endpoint: %v", err)
+		}
+	}()
+	logger.Log.Infof("health endpoint is listening on %s", s.listener.Addr())
+	if err := srv.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Log.Errorf("health endpoint stopped: %v", err)
+	}
+}
+
+// Handler return router of health endpoints.
+//
+// GET /healthz responds 503, while circuit breaker of sender is open.
+// GET /status responds agent self-metrics in json format.
+func (s *HealthServer) Handler() http.Handler {
+	router := chi.NewRouter()
+	router.Get("/healthz", s.handleHealth)
+	router.Get("/status", s.handleStatus)
+	return router
+}
+
+func (s *HealthServer) handleHealth(writer http.ResponseWriter, _ *http.Request) {
+	if s.monitor.Status().CircuitState == sender.CircuitOpen {
+		http.Error(writer, "server is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if _, err := writer.Write([]byte("ok")); err != nil {
+		logger.Log.Warn(err)
+	}
+}
+
+func (s *HealthServer) handleStatus(writer http.ResponseWriter, _ *http.Request) {
+	response, err := json.Marshal(s.monitor.Status())
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("content-type", "application/json")
+	if _, err = writer.Write(response); err != nil {
+		logger.Log.Warn(err)
+	}
+}
@@ -0,0 +1,78 @@
+package statistic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ilya372317/must-have-metrics/internal/client/sender"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitor_collectSelfStats(t *testing.T) {
+	require.NoError(t, logger.Init())
+	monitor := New(1)
+	serverDown := true
+	monitor.SetSender(sender.ReportSenderFunc(func(context.Context, string) error {
+		if serverDown {
+			return errors.New("server is down")
+		}
+		return nil
+	}))
+
+	monitor.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter, Delta: 1}
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+	serverDown = false
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+	monitor.collectStat()
+
+	for name, want := range map[string]int64{
+		SelfSendsAttempted: 2,
+		SelfSendsSucceeded: 1,
+		SelfSendsFailed:    1,
+		SelfBytesSent:      int64(len(`[{"delta":1,"id":"Events","type":"counter"}]`)),
+		SelfWorkerPanics:   0,
+	} {
+		assert.Equal(t, want, monitor.Data[name].Delta, name)
+	}
+	assert.Equal(t, entity.TypeGauge, monitor.Data[SelfQueueDepth].Type)
+
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+	monitor.collectStat()
+	assert.Equal(t, int64(1), monitor.Data[SelfSendsAttempted].Delta, "only new sends are added after report")
+	assert.Equal(t, int64(3), monitor.Status().SendsAttempted)
+}
+
+func TestHealthServer_Handler(t *testing.T) {
+	monitor := New(1)
+	breakerSender := &circuitSender{state: sender.CircuitClosed}
+	monitor.SetSender(breakerSender)
+	server := httptest.NewServer((&HealthServer{monitor: monitor}).Handler())
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/healthz")
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	breakerSender.state = sender.CircuitOpen
+	response, err = http.Get(server.URL + "/healthz")
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+
+	response, err = http.Get(server.URL + "/status")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	status := Status{}
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&status))
+	assert.Equal(t, sender.CircuitOpen, status.CircuitState)
+	assert.Equal(t, monitor.stats.startedAt.Unix(), status.StartedAt.Unix())
+}
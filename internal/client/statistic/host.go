@@ -0,0 +1,256 @@
+package statistic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// Groups of host metrics, which can be disabled in config.
+const (
+	HostGroupMemory  = "memory"
+	HostGroupCPU     = "cpu"
+	HostGroupLoad    = "load"
+	HostGroupDisk    = "disk"
+	HostGroupNetwork = "network"
+	HostGroupSwap    = "swap"
+)
+
+const (
+	instanceSeparator = "_"
+	rootMountInstance = "root"
+)
+
+var notAllowedInstanceChars = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// HostCollector collects operating system metrics: memory, per core cpu utilization,
+// load average, disks usage and io, network interfaces counters and swap.
+//
+// Cumulative disk io and network interfaces counters are reported as counters with delta since previous collect.
+type HostCollector struct {
+	previous map[string]uint64
+	config   config.HostMetricsConfig
+}
+
+// NewHostCollector constructor for HostCollector.
+func NewHostCollector(cnfg config.HostMetricsConfig) *HostCollector {
+	return &HostCollector{
+		config:   cnfg,
+		previous: make(map[string]uint64),
+	}
+}
+
+// Collect read host metrics from operating system.
+func (c *HostCollector) Collect(ctx context.Context) ([]MonitorValue, error) {
+	groups := []struct {
+		collect func(ctx context.Context) ([]MonitorValue, error)
+		name    string
+	}{
+		{name: HostGroupMemory, collect: c.collectMemory},
+		{name: HostGroupCPU, collect: c.collectCPU},
+		{name: HostGroupLoad, collect: c.collectLoad},
+		{name: HostGroupDisk, collect: c.collectDisk},
+		{name: HostGroupNetwork, collect: c.collectNetwork},
+		{name: HostGroupSwap, collect: c.collectSwap},
+	}
+
+	values := make([]MonitorValue, 0)
+	var errs []error
+	for _, group := range groups {
+		if !c.config.IsGroupEnabled(group.name) {
+			continue
+		}
+		groupValues, err := group.collect(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed collect %s host metrics: %w", group.name, err))
+		}
+		values = append(values, groupValues...)
+	}
+
+	return values, errors.Join(errs...)
+}
+
+func (c *HostCollector) collectMemory(ctx context.Context) ([]MonitorValue, error) {
+	m, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed read virtual memory stats: %w", err)
+	}
+
+	return []MonitorValue{
+		gaugeValue(c.name("TotalMemory", ""), float64(m.Total)),
+		gaugeValue(c.name("FreeMemory", ""), float64(m.Free)),
+	}, nil
+}
+
+func (c *HostCollector) collectCPU(ctx context.Context) ([]MonitorValue, error) {
+	cpuPercentages, err := cpu.PercentWithContext(ctx, 0, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed read cpu stats: %w", err)
+	}
+
+	values := make([]MonitorValue, 0, len(cpuPercentages))
+	for i, percentage := range cpuPercentages {
+		name := c.name("CPUutilization", "") + strconv.Itoa(i+1)
+		values = append(values, gaugeValue(name, percentage))
+	}
+
+	return values, nil
+}
+
+func (c *HostCollector) collectLoad(ctx context.Context) ([]MonitorValue, error) {
+	avg, err := load.AvgWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed read load average: %w", err)
+	}
+
+	return []MonitorValue{
+		gaugeValue(c.name("LoadAverage1", ""), avg.Load1),
+		gaugeValue(c.name("LoadAverage5", ""), avg.Load5),
+		gaugeValue(c.name("LoadAverage15", ""), avg.Load15),
+	}, nil
+}
+
+func (c *HostCollector) collectDisk(ctx context.Context) ([]MonitorValue, error) {
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed read disk partitions: %w", err)
+	}
+	values := make([]MonitorValue, 0)
+	var errs []error
+	ioCounters, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed read disk io counters: %w", err))
+	}
+	for _, partition := range partitions {
+		if !isAllowed(partition.Mountpoint, c.config.Mounts) {
+			continue
+		}
+		instance := mountInstance(partition.Mountpoint)
+		usage, err := disk.UsageWithContext(ctx, partition.Mountpoint)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed read usage of %s: %w", partition.Mountpoint, err))
+			continue
+		}
+		values = append(values,
+			gaugeValue(c.name("DiskTotal", instance), float64(usage.Total)),
+			gaugeValue(c.name("DiskFree", instance), float64(usage.Free)),
+			gaugeValue(c.name("DiskUsed", instance), float64(usage.Used)),
+			gaugeValue(c.name("DiskUsedPercent", instance), usage.UsedPercent),
+		)
+
+		io, ok := ioCounters[filepath.Base(partition.Device)]
+		if !ok {
+			continue
+		}
+		values = append(values,
+			c.counterValue(c.name("DiskReadBytes", instance), io.ReadBytes),
+			c.counterValue(c.name("DiskWriteBytes", instance), io.WriteBytes),
+			c.counterValue(c.name("DiskReadCount", instance), io.ReadCount),
+			c.counterValue(c.name("DiskWriteCount", instance), io.WriteCount),
+		)
+	}
+
+	return values, errors.Join(errs...)
+}
+
+func (c *HostCollector) collectNetwork(ctx context.Context) ([]MonitorValue, error) {
+	interfaces, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed read network io counters: %w", err)
+	}
+
+	values := make([]MonitorValue, 0)
+	for _, stat := range interfaces {
+		if !isAllowed(stat.Name, c.config.Interfaces) {
+			continue
+		}
+		instance := sanitizeInstance(stat.Name)
+		values = append(values,
+			c.counterValue(c.name("NetBytesSent", instance), stat.BytesSent),
+			c.counterValue(c.name("NetBytesRecv", instance), stat.BytesRecv),
+			c.counterValue(c.name("NetPacketsSent", instance), stat.PacketsSent),
+			c.counterValue(c.name("NetPacketsRecv", instance), stat.PacketsRecv),
+			c.counterValue(c.name("NetErrIn", instance), stat.Errin),
+			c.counterValue(c.name("NetErrOut", instance), stat.Errout),
+		)
+	}
+
+	return values, nil
+}
+
+func (c *HostCollector) collectSwap(ctx context.Context) ([]MonitorValue, error) {
+	swap, err := mem.SwapMemoryWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed read swap stats: %w", err)
+	}
+
+	return []MonitorValue{
+		gaugeValue(c.name("SwapTotal", ""), float64(swap.Total)),
+		gaugeValue(c.name("SwapFree", ""), float64(swap.Free)),
+		gaugeValue(c.name("SwapUsed", ""), float64(swap.Used)),
+	}, nil
+}
+
+// name build metric name from base name and instance with respect to configured prefix and overrides.
+func (c *HostCollector) name(base, instance string) string {
+	if override, ok := c.config.Names[base]; ok {
+		base = override
+	}
+	name := c.config.Prefix + base
+	if instance != "" {
+		name += instanceSeparator + instance
+	}
+	return name
+}
+
+// counterValue convert cumulative operating system counter to delta since previous collect.
+// First observation is used as baseline and reported with zero delta.
+func (c *HostCollector) counterValue(name string, current uint64) MonitorValue {
+	previous, ok := c.previous[name]
+	c.previous[name] = current
+	var delta int64
+	if ok && current >= previous {
+		delta = int64(current - previous)
+	}
+
+	return MonitorValue{
+		Name:  name,
+		Type:  entity.TypeCounter,
+		Delta: delta,
+	}
+}
+
+func isAllowed(value string, allowList []string) bool {
+	if len(allowList) == 0 {
+		return true
+	}
+	for _, allowed := range allowList {
+		if allowed == value {
+			return true
+		}
+	}
+	return false
+}
+
+func mountInstance(mountpoint string) string {
+	if mountpoint == "/" {
+		return rootMountInstance
+	}
+	return sanitizeInstance(mountpoint)
+}
+
+func sanitizeInstance(instance string) string {
+	return strings.Trim(notAllowedInstanceChars.ReplaceAllString(instance, instanceSeparator), instanceSeparator)
+}
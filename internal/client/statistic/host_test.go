@@ -0,0 +1,121 @@
+package statistic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostCollector_Collect(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      config.HostMetricsConfig
+		wantKeys    []string
+		notWantKeys []string
+	}{
+		{
+			name:     "default config case",
+			config:   config.HostMetricsConfig{},
+			wantKeys: []string{"TotalMemory", "FreeMemory", "CPUutilization1", "LoadAverage1", "SwapTotal"},
+		},
+		{
+			name: "disabled groups case",
+			config: config.HostMetricsConfig{
+				Disable: []string{HostGroupCPU, HostGroupLoad, HostGroupDisk, HostGroupNetwork, HostGroupSwap},
+			},
+			wantKeys:    []string{"TotalMemory", "FreeMemory"},
+			notWantKeys: []string{"CPUutilization1", "LoadAverage1", "SwapTotal"},
+		},
+		{
+			name: "prefix and override case",
+			config: config.HostMetricsConfig{
+				Prefix:  "host_",
+				Names:   map[string]string{"TotalMemory": "MemoryTotal", "CPUutilization": "CPU"},
+				Disable: []string{HostGroupDisk, HostGroupNetwork},
+			},
+			wantKeys:    []string{"host_MemoryTotal", "host_FreeMemory", "host_CPU1", "host_LoadAverage15"},
+			notWantKeys: []string{"TotalMemory", "CPUutilization1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := NewHostCollector(tt.config)
+			values, err := collector.Collect(context.Background())
+			require.NoError(t, err)
+
+			names := make(map[string]struct{}, len(values))
+			for _, value := range values {
+				names[value.Name] = struct{}{}
+			}
+			for _, key := range tt.wantKeys {
+				assert.Contains(t, names, key)
+			}
+			for _, key := range tt.notWantKeys {
+				assert.NotContains(t, names, key)
+			}
+		})
+	}
+}
+
+func TestHostCollector_name(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   config.HostMetricsConfig
+		base     string
+		instance string
+		want     string
+	}{
+		{
+			name:     "without instance",
+			base:     "SwapFree",
+			instance: "",
+			want:     "SwapFree",
+		},
+		{
+			name:     "with instance",
+			base:     "DiskFree",
+			instance: mountInstance("/var/lib/docker"),
+			want:     "DiskFree_var_lib_docker",
+		},
+		{
+			name:     "root mount",
+			base:     "DiskFree",
+			instance: mountInstance("/"),
+			want:     "DiskFree_root",
+		},
+		{
+			name:     "interface with not allowed chars",
+			base:     "NetBytesSent",
+			instance: sanitizeInstance("veth-1a.2"),
+			want:     "NetBytesSent_veth_1a_2",
+		},
+		{
+			name: "prefix and override",
+			config: config.HostMetricsConfig{
+				Prefix: "node.",
+				Names:  map[string]string{"NetBytesSent": "tx_bytes"},
+			},
+			base:     "NetBytesSent",
+			instance: "eth0",
+			want:     "node.tx_bytes_eth0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := NewHostCollector(tt.config)
+			assert.Equal(t, tt.want, collector.name(tt.base, tt.instance))
+		})
+	}
+}
+
+func TestHostCollector_counterValue(t *testing.T) {
+	collector := NewHostCollector(config.HostMetricsConfig{})
+
+	assert.Equal(t, int64(0), collector.counterValue("NetBytesSent_eth0", 100).Delta)
+	assert.Equal(t, int64(50), collector.counterValue("NetBytesSent_eth0", 150).Delta)
+	assert.Equal(t, int64(0), collector.counterValue("NetBytesSent_eth0", 10).Delta)
+	assert.Equal(t, int64(5), collector.counterValue("NetBytesSent_eth0", 15).Delta)
+}
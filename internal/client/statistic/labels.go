@@ -0,0 +1,169 @@
+package statistic
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/promtext"
+)
+
+// Modes of sending labels to server.
+const (
+	LabelsModeLabels = "labels"
+	LabelsModeID     = "id"
+)
+
+// Names of labels filled from agent config.
+const (
+	LabelHost        = "host"
+	LabelEnvironment = "environment"
+	LabelRegion      = "region"
+)
+
+// Labeler attaches static agent labels to sent metrics.
+// Labels of metric itself take precedence over agent labels with the same name.
+type Labeler struct {
+	labels     map[string]string
+	idTemplate *template.Template
+	mode       string
+}
+
+// idTemplateData data available in ID template.
+type idTemplateData struct {
+	Labels map[string]string
+	Name   string
+}
+
+// NewLabeler constructor for Labeler.
+func NewLabeler(cnfg config.LabelsConfig) (*Labeler, error) {
+	labeler := &Labeler{
+		labels: make(map[string]string, len(cnfg.Custom)+3),
+		mode:   cnfg.Mode,
+	}
+	switch cnfg.Mode {
+	case "":
+		labeler.mode = LabelsModeLabels
+	case LabelsModeLabels, LabelsModeID:
+	default:
+		return nil, fmt.Errorf("unknown labels mode %q", cnfg.Mode)
+	}
+	if cnfg.IDTemplate != "" {
+		idTemplate, err := template.New("id").Option("missingkey=zero").Parse(cnfg.IDTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id template: %w", err)
+		}
+		labeler.idTemplate = idTemplate
+	}
+
+	for key, value := range cnfg.Custom {
+		labeler.labels[key] = value
+	}
+	hostname := cnfg.Hostname
+	if hostname == "" && !cnfg.DisableHostname {
+		detected, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed detect hostname: %w", err)
+		}
+		hostname = detected
+	}
+	for key, value := range map[string]string{
+		LabelHost:        hostname,
+		LabelEnvironment: cnfg.Environment,
+		LabelRegion:      cnfg.Region,
+	} {
+		if value != "" {
+			labeler.labels[key] = value
+		}
+	}
+
+	return labeler, nil
+}
+
+// SetLabeler set labels attached to every sent metric.
+func (monitor *Monitor) SetLabeler(labeler *Labeler) {
+	monitor.labeler = labeler
+}
+
+// apply fill labels or ID of metrics depending on labels mode.
+func (l *Labeler) apply(metrics *dto.Metrics, value MonitorValue) error {
+	labels := value.Labels
+	if l != nil {
+		labels = mergeLabels(l.labels, value.Labels)
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	if l == nil || l.mode == LabelsModeLabels {
+		metrics.Labels = labels
+		return nil
+	}
+	if l.idTemplate == nil {
+		metrics.ID = promtext.SeriesID(value.Name, labels)
+		return nil
+	}
+
+	id := strings.Builder{}
+	if err := l.idTemplate.Execute(&id, idTemplateData{Name: value.Name, Labels: labels}); err != nil {
+		return fmt.Errorf("failed execute id template for %s: %w", value.Name, err)
+	}
+	metrics.ID = id.String()
+	return nil
+}
+
+// mergeLabels return new map with base labels overridden by given ones.
+func mergeLabels(base, labels map[string]string) map[string]string {
+	if len(base) == 0 {
+		return labels
+	}
+	merged := make(map[string]string, len(base)+len(labels))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range labels {
+		merged[key] = value
+	}
+	return merged
+}
+
+// withLabels add given labels to all values returned by collector.
+func withLabels(collector Collector, labels map[string]string) Collector {
+	if len(labels) == 0 {
+		return collector
+	}
+	if backgroundCollector, ok := collector.(BackgroundCollector); ok {
+		return &labeledBackgroundCollector{BackgroundCollector: backgroundCollector, labels: labels}
+	}
+	return &labeledCollector{Collector: collector, labels: labels}
+}
+
+type labeledCollector struct {
+	Collector
+	labels map[string]string
+}
+
+func (c *labeledCollector) Collect(ctx context.Context) ([]MonitorValue, error) {
+	values, err := c.Collector.Collect(ctx)
+	return addLabels(values, c.labels), err
+}
+
+type labeledBackgroundCollector struct {
+	BackgroundCollector
+	labels map[string]string
+}
+
+func (c *labeledBackgroundCollector) Collect(ctx context.Context) ([]MonitorValue, error) {
+	values, err := c.BackgroundCollector.Collect(ctx)
+	return addLabels(values, c.labels), err
+}
+
+func addLabels(values []MonitorValue, labels map[string]string) []MonitorValue {
+	for i := range values {
+		values[i].Labels = mergeLabels(labels, values[i].Labels)
+	}
+	return values
+}
@@ -0,0 +1,144 @@
+package statistic
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ilya372317/must-have-metrics/internal/client/sender"
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/router"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/ilya372317/must-have-metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabeler_apply(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	tests := []struct {
+		name    string
+		cnfg    config.LabelsConfig
+		value   MonitorValue
+		want    dto.Metrics
+		wantErr bool
+	}{
+		{
+			name:  "detected hostname case",
+			cnfg:  config.LabelsConfig{},
+			value: gaugeValue("Alloc", 1),
+			want:  dto.Metrics{ID: "Alloc", Labels: map[string]string{LabelHost: hostname}},
+		},
+		{
+			name: "labels mode case",
+			cnfg: config.LabelsConfig{
+				Hostname:    "web-1",
+				Environment: "prod",
+				Region:      "eu",
+				Custom:      map[string]string{"team": "core", "region": "us"},
+			},
+			value: MonitorValue{Name: "Alloc", Labels: map[string]string{"team": "infra"}},
+			want: dto.Metrics{ID: "Alloc", Labels: map[string]string{
+				LabelHost: "web-1", LabelEnvironment: "prod", LabelRegion: "eu", "team": "infra",
+			}},
+		},
+		{
+			name:  "id mode case",
+			cnfg:  config.LabelsConfig{Hostname: "web-1", Mode: LabelsModeID},
+			value: gaugeValue("Alloc", 1),
+			want:  dto.Metrics{ID: `Alloc{host="web-1"}`},
+		},
+		{
+			name: "id template case",
+			cnfg: config.LabelsConfig{
+				Hostname:   "web-1",
+				Region:     "eu",
+				Mode:       LabelsModeID,
+				IDTemplate: "{{.Labels.region}}.{{.Labels.host}}.{{.Name}}",
+			},
+			value: gaugeValue("Alloc", 1),
+			want:  dto.Metrics{ID: "eu.web-1.Alloc"},
+		},
+		{
+			name:  "without labels case",
+			cnfg:  config.LabelsConfig{DisableHostname: true, Mode: LabelsModeID},
+			value: gaugeValue("Alloc", 1),
+			want:  dto.Metrics{ID: "Alloc"},
+		},
+		{
+			name:    "invalid mode case",
+			cnfg:    config.LabelsConfig{Mode: "tags"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid template case",
+			cnfg:    config.LabelsConfig{Mode: LabelsModeID, IDTemplate: "{{.Name"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labeler, err := NewLabeler(tt.cnfg)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			got := dto.Metrics{ID: tt.value.Name}
+			require.NoError(t, labeler.apply(&got, tt.value))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWithLabels(t *testing.T) {
+	collector := withLabels(&stubCollector{rounds: [][]MonitorValue{{
+		gaugeValue("CPU", 1),
+		{Name: "Requests", Type: entity.TypeCounter, Delta: 1, Labels: map[string]string{"code": "200"}},
+	}}}, map[string]string{"collector": "host"})
+
+	values, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"collector": "host"}, values[0].Labels)
+	assert.Equal(t, map[string]string{"collector": "host", "code": "200"}, values[1].Labels)
+
+	pushCollector := &PushCollector{}
+	_, ok := withLabels(pushCollector, map[string]string{"collector": "push"}).(BackgroundCollector)
+	assert.True(t, ok, "background collector is still run by monitor")
+}
+
+func TestMonitor_reportStatLabels(t *testing.T) {
+	require.NoError(t, logger.Init())
+	repository := storage.NewInMemoryStorage()
+	server := httptest.NewServer(router.AlertRouter(repository, &config.ServerConfig{}))
+	defer server.Close()
+	agentConfig := &config.AgentConfig{Host: strings.TrimPrefix(server.URL, "http://")}
+
+	labeler, err := NewLabeler(config.LabelsConfig{Hostname: "web-1"})
+	require.NoError(t, err)
+	monitor := New(1)
+	monitor.SetLabeler(labeler)
+	monitor.mergeValues([]MonitorValue{
+		{Name: "Requests", Type: entity.TypeCounter, Delta: 2, Labels: map[string]string{"code": "200"}},
+		{Name: "Requests", Type: entity.TypeCounter, Delta: 3, Labels: map[string]string{"code": "500"}},
+	})
+	reportSender, err := sender.NewHTTPSender(agentConfig)
+	require.NoError(t, err)
+	monitor.SetSender(reportSender)
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+
+	for id, want := range map[string]int64{
+		`Requests{code="200",host="web-1"}`: 2,
+		`Requests{code="500",host="web-1"}`: 3,
+	} {
+		alert, err := repository.Get(context.Background(), id)
+		require.NoError(t, err)
+		require.NotNil(t, alert.IntValue)
+		assert.Equal(t, want, *alert.IntValue)
+	}
+}
@@ -0,0 +1,428 @@
+package statistic
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+const (
+	logReadBufferSize  = 32 * 1024
+	maxLogLineSize     = 64 * 1024
+	logFingerprintSize = 1024
+	logStateDirPerm    = 0o750
+	logStateFilePerm   = 0o640
+)
+
+// LogCollector tails log files and extracts metrics from their lines by regular expressions.
+//
+// Files are polled by interval. Rotated file is read to the end before switching to new one,
+// truncated file is read from start. Offsets are saved with fingerprint of file beginning,
+// so after restart reading is continued only if it is the same file.
+// Offsets are saved right after lines are read, so metrics are delivered at most once.
+type LogCollector struct {
+	values    *sourceValues
+	state     map[string]logFileState
+	statePath string
+	files     []*logFile
+	interval  time.Duration
+	started   bool
+}
+
+// logFileState saved position in log file.
+type logFileState struct {
+	Fingerprint     string `json:"fingerprint"`
+	Offset          int64  `json:"offset"`
+	FingerprintSize int64  `json:"fingerprint_size"`
+}
+
+type logRule struct {
+	regexp     *regexp.Regexp
+	labels     map[string]string
+	name       string
+	metricType string
+	valueGroup int
+}
+
+type logFile struct {
+	file      *os.File
+	info      os.FileInfo
+	labels    map[string]string
+	gauges    map[string]MonitorValue
+	counters  map[string]MonitorValue
+	path      string
+	pending   []byte
+	rules     []logRule
+	offset    int64
+	fromStart bool
+}
+
+// NewLogCollector constructor for LogCollector. Files are polled every defaultInterval,
+// if interval is not configured.
+func NewLogCollector(cnfg config.LogMetricsConfig, defaultInterval time.Duration) (*LogCollector, error) {
+	interval := defaultInterval
+	if cnfg.Interval > 0 {
+		interval = time.Duration(cnfg.Interval) * time.Second
+	}
+	c := &LogCollector{
+		values:    newSourceValues(),
+		statePath: cnfg.StatePath,
+		interval:  interval,
+	}
+	paths := make(map[string]struct{}, len(cnfg.Files))
+	for _, fileConfig := range cnfg.Files {
+		if fileConfig.Path == "" {
+			return nil, errors.New("empty path of log file")
+		}
+		if _, ok := paths[fileConfig.Path]; ok {
+			return nil, fmt.Errorf("duplicate log file %s", fileConfig.Path)
+		}
+		paths[fileConfig.Path] = struct{}{}
+		rules := make([]logRule, 0, len(fileConfig.Rules))
+		for _, ruleConfig := range fileConfig.Rules {
+			rule, err := newLogRule(ruleConfig)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rule %q of log file %s: %w", ruleConfig.Name, fileConfig.Path, err)
+			}
+			rules = append(rules, rule)
+		}
+		c.files = append(c.files, &logFile{
+			path:      fileConfig.Path,
+			labels:    fileConfig.Labels,
+			rules:     rules,
+			fromStart: fileConfig.FromStart,
+			gauges:    make(map[string]MonitorValue),
+			counters:  make(map[string]MonitorValue),
+		})
+	}
+
+	state, err := loadLogState(c.statePath)
+	if err != nil {
+		logger.Log.Warnf("failed load log files offsets, files are read from start position: %v", err)
+	}
+	c.state = state
+	return c, nil
+}
+
+func newLogRule(cnfg config.LogRuleConfig) (logRule, error) {
+	if cnfg.Name == "" {
+		return logRule{}, errors.New("empty metric name")
+	}
+	compiled, err := regexp.Compile(cnfg.Pattern)
+	if err != nil {
+		return logRule{}, fmt.Errorf("invalid pattern: %w", err)
+	}
+	rule := logRule{
+		regexp:     compiled,
+		labels:     cnfg.Labels,
+		name:       cnfg.Name,
+		metricType: cnfg.Type,
+		valueGroup: -1,
+	}
+	switch cnfg.Type {
+	case entity.TypeCounter:
+	case entity.TypeGauge:
+		if cnfg.Value == "" {
+			return logRule{}, errors.New("gauge rule requires value group")
+		}
+	default:
+		return logRule{}, fmt.Errorf("invalid metric type %q", cnfg.Type)
+	}
+	if cnfg.Value != "" {
+		rule.valueGroup = compiled.SubexpIndex(cnfg.Value)
+		if number, err := strconv.Atoi(cnfg.Value); err == nil {
+			rule.valueGroup = number
+		}
+		if rule.valueGroup <= 0 || rule.valueGroup > compiled.NumSubexp() {
+			return logRule{}, fmt.Errorf("unknown value group %q", cnfg.Value)
+		}
+	}
+	return rule, nil
+}
+
+// Run poll log files by interval until context is done. Lines written before stop are read by last poll.
+func (c *LogCollector) Run(ctx context.Context) {
+	runEvery(ctx, c.interval, c.poll)
+	c.poll()
+	for _, file := range c.files {
+		file.close()
+	}
+}
+
+// Collect return metrics extracted from log lines.
+func (c *LogCollector) Collect(context.Context) ([]MonitorValue, error) {
+	return c.values.drain(), nil
+}
+
+func (c *LogCollector) poll() {
+	for i, file := range c.files {
+		if err := c.tail(file); err != nil {
+			logger.Log.Warnf("failed read log file %s: %v", file.path, err)
+		}
+		values := make([]MonitorValue, 0, len(file.gauges)+len(file.counters))
+		for _, gauge := range file.gauges {
+			values = append(values, gauge)
+		}
+		for _, counter := range file.counters {
+			values = append(values, counter)
+		}
+		file.counters = make(map[string]MonitorValue)
+		c.values.store(i, values)
+	}
+	c.started = true
+	if err := c.saveState(); err != nil {
+		logger.Log.Warnf("failed save log files offsets: %v", err)
+	}
+}
+
+// tail read new lines of file. File is reopened, if it was rotated.
+func (c *LogCollector) tail(file *logFile) error {
+	info, err := os.Stat(file.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// File is rotated, but new one is not created yet.
+		if file.file != nil {
+			return file.readLines()
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed stat log file: %w", err)
+	}
+
+	if file.file != nil && !os.SameFile(file.info, info) {
+		if err = file.readLines(); err != nil {
+			logger.Log.Warnf("failed read rest of rotated log file %s: %v", file.path, err)
+		}
+		file.close()
+		if err = file.open(); err != nil {
+			return err
+		}
+	} else if file.file == nil {
+		if err = file.open(); err != nil {
+			return err
+		}
+		if err = file.seek(c.startOffset(file, info)); err != nil {
+			return err
+		}
+	}
+	if info, err = file.file.Stat(); err != nil {
+		return fmt.Errorf("failed stat log file: %w", err)
+	}
+	file.info = info
+	if info.Size() < file.offset {
+		logger.Log.Infof("log file %s is truncated, read it from start", file.path)
+		if err = file.seek(0); err != nil {
+			return err
+		}
+	}
+	return file.readLines()
+}
+
+// startOffset return position for first opening of file. Saved offset is used only for the same file.
+// File existed on agent start is read from the end, file created later is read from start.
+func (c *LogCollector) startOffset(file *logFile, info os.FileInfo) int64 {
+	if state, ok := c.state[file.path]; ok {
+		delete(c.state, file.path)
+		fingerprint, size, err := logFingerprint(file.file, state.FingerprintSize)
+		if err == nil && size == state.FingerprintSize && fingerprint == state.Fingerprint && info.Size() >= state.Offset {
+			return state.Offset
+		}
+		return 0
+	}
+	if file.fromStart || c.started {
+		return 0
+	}
+	return info.Size()
+}
+
+func (file *logFile) open() error {
+	opened, err := os.Open(file.path)
+	if err != nil {
+		return fmt.Errorf("failed open log file: %w", err)
+	}
+	info, err := opened.Stat()
+	if err != nil {
+		_ = opened.Close()
+		return fmt.Errorf("failed stat log file: %w", err)
+	}
+	file.file = opened
+	file.info = info
+	file.offset = 0
+	file.pending = nil
+	return nil
+}
+
+func (file *logFile) seek(offset int64) error {
+	if _, err := file.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed seek log file: %w", err)
+	}
+	file.offset = offset
+	file.pending = nil
+	return nil
+}
+
+func (file *logFile) close() {
+	if file.file == nil {
+		return
+	}
+	if err := file.file.Close(); err != nil {
+		logger.Log.Warnf("failed close log file %s: %v", file.path, err)
+	}
+	file.file = nil
+}
+
+// readLines read file until end and apply rules to complete lines.
+// Incomplete last line is kept until the rest of it is written.
+func (file *logFile) readLines() error {
+	buffer := make([]byte, logReadBufferSize)
+	for {
+		n, err := file.file.Read(buffer)
+		if n > 0 {
+			file.offset += int64(n)
+			file.pending = append(file.pending, buffer[:n]...)
+			for {
+				end := bytes.IndexByte(file.pending, '\n')
+				if end < 0 {
+					break
+				}
+				file.applyRules(file.pending[:end])
+				file.pending = file.pending[end+1:]
+			}
+			if len(file.pending) > maxLogLineSize {
+				file.applyRules(file.pending)
+				file.pending = nil
+			}
+			file.pending = bytes.Clone(file.pending)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed read log file: %w", err)
+		}
+	}
+}
+
+func (file *logFile) applyRules(line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	for _, rule := range file.rules {
+		match := rule.regexp.FindSubmatchIndex(line)
+		if match == nil {
+			continue
+		}
+		value := MonitorValue{Name: rule.name, Type: rule.metricType, Labels: file.labels}
+		if len(rule.labels) > 0 {
+			labels := make(map[string]string, len(rule.labels))
+			for key, template := range rule.labels {
+				labels[key] = string(rule.regexp.Expand(nil, []byte(template), line, match))
+			}
+			value.Labels = mergeLabels(file.labels, labels)
+		}
+		number := float64(1)
+		if rule.valueGroup > 0 {
+			start, end := match[2*rule.valueGroup], match[2*rule.valueGroup+1]
+			if start < 0 {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(string(line[start:end]), 64)
+			if err != nil {
+				continue
+			}
+			number = parsed
+		}
+
+		id := value.ID()
+		if rule.metricType == entity.TypeGauge {
+			value.Value = number
+			file.gauges[id] = value
+			continue
+		}
+		value.Delta = int64(number)
+		file.counters[id] = addDelta(file.counters[id], value)
+	}
+}
+
+// position return offset of last complete line, it is saved as state of file.
+func (file *logFile) position() int64 {
+	return file.offset - int64(len(file.pending))
+}
+
+func (c *LogCollector) saveState() error {
+	if c.statePath == "" {
+		return nil
+	}
+	state := make(map[string]logFileState, len(c.files))
+	// Offsets of files not opened yet are kept as they were loaded.
+	for path, fileState := range c.state {
+		state[path] = fileState
+	}
+	for _, file := range c.files {
+		if file.file == nil {
+			continue
+		}
+		fingerprint, size, err := logFingerprint(file.file, logFingerprintSize)
+		if err != nil {
+			continue
+		}
+		state[file.path] = logFileState{Offset: file.position(), Fingerprint: fingerprint, FingerprintSize: size}
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed serialize offsets: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(c.statePath), logStateDirPerm); err != nil {
+		return fmt.Errorf("failed create state dir: %w", err)
+	}
+	tempPath := c.statePath + ".tmp"
+	if err = os.WriteFile(tempPath, data, logStateFilePerm); err != nil {
+		return fmt.Errorf("failed write state: %w", err)
+	}
+	if err = os.Rename(tempPath, c.statePath); err != nil {
+		return fmt.Errorf("failed replace state: %w", err)
+	}
+	return nil
+}
+
+func loadLogState(path string) (map[string]logFileState, error) {
+	state := make(map[string]logFileState)
+	if path == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed read state: %w", err)
+	}
+	if err = json.Unmarshal(data, &state); err != nil {
+		return make(map[string]logFileState), fmt.Errorf("invalid state content: %w", err)
+	}
+	return state, nil
+}
+
+// logFingerprint return hash of first size bytes of file and count of hashed bytes.
+func logFingerprint(file io.ReaderAt, size int64) (string, int64, error) {
+	hash := sha256.New()
+	n, err := io.Copy(hash, io.NewSectionReader(file, 0, size))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed read log file: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), n, nil
+}
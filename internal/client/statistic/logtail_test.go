@@ -0,0 +1,181 @@
+package statistic
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLogRules = []config.LogRuleConfig{
+	{Name: "ErrorLines", Pattern: `\bERROR\b`, Type: entity.TypeCounter},
+	{
+		Name:    "Requests",
+		Pattern: `status=(?P<status>\d+)`,
+		Type:    entity.TypeCounter,
+		Labels:  map[string]string{"status": "$status"},
+	},
+	{Name: "QueueSize", Pattern: `queue=(\d+)`, Type: entity.TypeGauge, Value: "1"},
+}
+
+func newTestLogCollector(t *testing.T, path, statePath string, fromStart bool) *LogCollector {
+	t.Helper()
+	collector, err := NewLogCollector(config.LogMetricsConfig{
+		StatePath: statePath,
+		Files:     []config.LogFileConfig{{Path: path, Rules: testLogRules, FromStart: fromStart}},
+	}, time.Second)
+	require.NoError(t, err)
+	return collector
+}
+
+func pollLogs(t *testing.T, collector *LogCollector) map[string]MonitorValue {
+	t.Helper()
+	collector.poll()
+	values, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	got := make(map[string]MonitorValue, len(values))
+	for _, value := range values {
+		got[value.ID()] = value
+	}
+	return got
+}
+
+func appendLog(t *testing.T, path, content string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = file.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
+
+func TestLogCollector_poll(t *testing.T) {
+	require.NoError(t, logger.Init())
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "ERROR old line\n")
+	collector := newTestLogCollector(t, path, "", false)
+
+	// Existing content is skipped.
+	assert.Empty(t, pollLogs(t, collector))
+
+	appendLog(t, path, "ERROR failed\nINFO status=200 queue=3\nINFO status=500\nERROR status=500 queue=")
+	got := pollLogs(t, collector)
+	assert.Equal(t, int64(1), got["ErrorLines"].Delta)
+	assert.Equal(t, int64(1), got[`Requests{status="200"}`].Delta)
+	assert.Equal(t, int64(1), got[`Requests{status="500"}`].Delta)
+	assert.Equal(t, float64(3), got["QueueSize"].Value)
+
+	// Incomplete line is applied, when it is finished.
+	appendLog(t, path, "7\n")
+	got = pollLogs(t, collector)
+	assert.Equal(t, int64(1), got["ErrorLines"].Delta)
+	assert.Equal(t, int64(1), got[`Requests{status="500"}`].Delta)
+	assert.Equal(t, float64(7), got["QueueSize"].Value)
+	assert.Equal(t, entity.TypeGauge, got["QueueSize"].Type)
+}
+
+func TestLogCollector_pollRotation(t *testing.T) {
+	require.NoError(t, logger.Init())
+	path := filepath.Join(t.TempDir(), "app.log")
+	collector := newTestLogCollector(t, path, "", false)
+
+	// File created after start is read from start.
+	assert.Empty(t, pollLogs(t, collector))
+	appendLog(t, path, "ERROR first\n")
+	assert.Equal(t, int64(1), pollLogs(t, collector)["ErrorLines"].Delta)
+
+	appendLog(t, path, "ERROR before rotation\n")
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendLog(t, path, "ERROR after rotation\nERROR after rotation\n")
+	assert.Equal(t, int64(3), pollLogs(t, collector)["ErrorLines"].Delta)
+
+	require.NoError(t, os.Truncate(path, 0))
+	appendLog(t, path, "ERROR after truncate\n")
+	assert.Equal(t, int64(1), pollLogs(t, collector)["ErrorLines"].Delta)
+}
+
+func TestLogCollector_pollRestoreOffset(t *testing.T) {
+	require.NoError(t, logger.Init())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	statePath := filepath.Join(dir, "state", "offsets.json")
+	appendLog(t, path, "ERROR first\n")
+
+	collector := newTestLogCollector(t, path, statePath, true)
+	assert.Equal(t, int64(1), pollLogs(t, collector)["ErrorLines"].Delta)
+	collector.Run(canceledContext())
+
+	appendLog(t, path, "ERROR while agent is stopped\n")
+	collector = newTestLogCollector(t, path, statePath, true)
+	assert.Equal(t, int64(1), pollLogs(t, collector)["ErrorLines"].Delta)
+
+	// Replaced file is read from start.
+	require.NoError(t, os.Remove(path))
+	appendLog(t, path, "INFO new file\nERROR new file\nERROR new file\n")
+	collector = newTestLogCollector(t, path, statePath, false)
+	assert.Equal(t, int64(2), pollLogs(t, collector)["ErrorLines"].Delta)
+}
+
+func canceledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func TestNewLogCollector(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    config.LogRuleConfig
+		wantErr bool
+	}{
+		{name: "counter case", rule: config.LogRuleConfig{Name: "Errors", Pattern: "ERROR", Type: entity.TypeCounter}},
+		{
+			name: "named value case",
+			rule: config.LogRuleConfig{Name: "Size", Pattern: `size=(?P<size>\d+)`, Type: entity.TypeGauge, Value: "size"},
+		},
+		{
+			name:    "empty name case",
+			rule:    config.LogRuleConfig{Pattern: "ERROR", Type: entity.TypeCounter},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern case",
+			rule:    config.LogRuleConfig{Name: "Errors", Pattern: "(", Type: entity.TypeCounter},
+			wantErr: true,
+		},
+		{
+			name:    "invalid type case",
+			rule:    config.LogRuleConfig{Name: "Errors", Pattern: "ERROR", Type: "histogram"},
+			wantErr: true,
+		},
+		{
+			name:    "gauge without value case",
+			rule:    config.LogRuleConfig{Name: "Size", Pattern: `(\d+)`, Type: entity.TypeGauge},
+			wantErr: true,
+		},
+		{
+			name:    "unknown group case",
+			rule:    config.LogRuleConfig{Name: "Size", Pattern: `(\d+)`, Type: entity.TypeGauge, Value: "2"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLogCollector(config.LogMetricsConfig{
+				Files: []config.LogFileConfig{{Path: "/var/log/app.log", Rules: []config.LogRuleConfig{tt.rule}}},
+			}, time.Second)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -3,57 +3,86 @@ package statistic
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"runtime"
 	"sync"
 	"time"
 
-	"github.com/ilya372317/must-have-metrics/internal/client/sender"
-	"github.com/ilya372317/must-have-metrics/internal/config"
 	"github.com/ilya372317/must-have-metrics/internal/dto"
 	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/promtext"
 	"github.com/ilya372317/must-have-metrics/internal/server/entity"
 	"github.com/ilya372317/must-have-metrics/internal/utils"
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/mem"
 )
 
 const counterName = "PollCount"
 const randomValueName = "RandomValue"
+const circuitStateName = "agent_circuit_breaker_state"
 const minRandomValue = 1
 const maxRandomValue = 50
-const chunkForRequestSize = 50
+
+// ChunkForRequestSize max count of metrics sent to server by one request.
+const ChunkForRequestSize = 50
+
+// circuitStater sender with circuit breaker. State is reported as agent self-metric.
+type circuitStater interface {
+	CircuitState() int
+}
 
 // Monitor entity for collect metrics and send it to server.
 type Monitor struct {
 	Data         map[string]MonitorValue
 	ReportTaskCh chan func()
+	aggregator   *Aggregator
+	labeler      *Labeler
+	pipeline     *Pipeline
+	// cancelReports cancel reports started by ReportStat, which are in flight.
+	cancelReports context.CancelFunc
+	// acknowledged gauges values by series ID, used in delta report mode.
+	acked              map[string]float64
+	lastFullResync     time.Time
+	resyncStartedAt    time.Time
+	resyncPending      map[string]struct{} // gauges of full report, which are not delivered yet
+	reserved           map[string]int64    // counter deltas, which are being reported at the moment
+	collectors         []Collector
+	collected          []map[string]struct{}
+	destinations       []Destination
+	stats              selfStats
+	deliveries         sync.WaitGroup // deliveries of reports broadcast to several destinations
+	fullResyncInterval time.Duration
+	deltaReport        bool
+	skipMemStats       bool
+	reportsCancelled   bool
 	sync.Mutex
 }
 
-// New constructor for Monitor.
-func New(poolSize uint) *Monitor {
+// New constructor for Monitor. Given collectors will be polled along with runtime metrics.
+func New(poolSize uint, collectors ...Collector) *Monitor {
 	m := &Monitor{
 		Data:         make(map[string]MonitorValue),
 		ReportTaskCh: make(chan func(), poolSize),
+		collectors:   collectors,
+		collected:    make([]map[string]struct{}, len(collectors)),
+		reserved:     make(map[string]int64),
 	}
+	m.stats.startedAt = time.Now()
 	m.startWorkerPool(poolSize)
 	return m
 }
 
-func (monitor *Monitor) startWorker(workerID int) {
+func (monitor *Monitor) startWorker(tasks <-chan func(), workerID int) {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
 				logger.Log.Errorf("Worker %d recovered from panic: %v", workerID, r)
+				monitor.stats.workerPanics.Add(1)
 				time.Sleep(time.Second)
-				monitor.startWorker(workerID)
+				monitor.startWorker(tasks, workerID)
 			}
 		}()
 		for {
-			reportTask, more := <-monitor.ReportTaskCh
+			reportTask, more := <-tasks
 			if !more {
-				if len(monitor.ReportTaskCh) > 0 {
+				if len(tasks) > 0 {
 					logger.Log.Error("Some task was not completed before monitor shutdown.")
 				}
 				logger.Log.Infof("Worker %d is stopping because the channel is closed.", workerID)
@@ -66,117 +95,119 @@ func (monitor *Monitor) startWorker(workerID int) {
 
 func (monitor *Monitor) startWorkerPool(poolSize uint) {
 	for k := 0; k < int(poolSize); k++ {
-		monitor.startWorker(k)
+		monitor.startWorker(monitor.ReportTaskCh, k)
 	}
 }
 
 // MonitorValue representation of collected metric.
+//
+// Type defines which field holds the value: Value for gauges and Delta for counters.
+// New metric types should get own typed field instead of reusing existing ones.
+// Metrics with the same name and different labels are different series.
 type MonitorValue struct {
-	Name  string
-	Type  string
-	Value uint64
-	Delta int
+	Labels map[string]string
+	Name   string
+	Type   string
+	Value  float64
+	Delta  int64
+}
+
+// ID return unique identifier of series in form name{label="value",...}.
+func (v MonitorValue) ID() string {
+	return promtext.SeriesID(v.Name, v.Labels)
 }
 
 // CollectStat method for collect metrics from operating system.
 func (monitor *Monitor) CollectStat(ctx context.Context, wg *sync.WaitGroup, pollInterval time.Duration) {
+	backgroundWg := monitor.startBackgroundCollectors(ctx)
 	ticker := time.NewTicker(pollInterval)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
+			start := time.Now()
 			monitor.collectStat()
-			monitor.collectMemStat()
+			monitor.collectFromCollectors(ctx)
+			monitor.stats.collectDuration.Store(int64(time.Since(start)))
 		case <-ctx.Done():
+			backgroundWg.Wait()
+			// Metrics gathered by stopped background collectors are kept in Monitor data,
+			// so they are not lost, if data is inherited by new Monitor.
+			monitor.collectFromCollectors(context.WithoutCancel(ctx))
 			wg.Done()
 			return
 		}
 	}
 }
 
-func (monitor *Monitor) collectMemStat() {
+func (monitor *Monitor) collectStat() {
 	monitor.Mutex.Lock()
-	m, err := mem.VirtualMemory()
-	if err != nil {
-		logger.Log.Warnf("failed read virtual memory stats: %v", err)
-		return
-	}
-
-	monitor.setGaugeValue("TotalMemory", m.Total)
-	monitor.setGaugeValue("FreeMemory", m.Free)
-
-	cpuPercentages, err := cpu.Percent(0, true)
-	if err != nil {
-		logger.Log.Warnf("failed read cpu stats: %v", err)
-		return
+	monitor.updatePollCount()
+	if !monitor.skipMemStats {
+		monitor.collectMemStats()
 	}
-	monitor.setGaugeValue("CPUutilization1", uint64(cpuPercentages[0]))
+	monitor.setGaugeValue(randomValueName, float64(utils.GetRandomValue(minRandomValue, maxRandomValue)))
+	monitor.collectSelfStats()
 	monitor.Mutex.Unlock()
 }
 
-func (monitor *Monitor) collectStat() {
-	monitor.Mutex.Lock()
+func (monitor *Monitor) collectMemStats() {
 	rtm := runtime.MemStats{}
 	runtime.ReadMemStats(&rtm)
-	monitor.updatePollCount()
-	monitor.setGaugeValue("Alloc", rtm.Alloc)
-	monitor.setGaugeValue("BuckHashSys", rtm.BuckHashSys)
-	monitor.setGaugeValue("GCSys", rtm.GCSys)
-	monitor.setGaugeValue("HeapAlloc", rtm.HeapAlloc)
-	monitor.setGaugeValue("HeapIdle", rtm.HeapIdle)
-	monitor.setGaugeValue("HeapInuse", rtm.HeapInuse)
-	monitor.setGaugeValue("HeapObjects", rtm.HeapObjects)
-	monitor.setGaugeValue("HeapReleased", rtm.HeapReleased)
-	monitor.setGaugeValue("HeapSys", rtm.HeapSys)
-	monitor.setGaugeValue("LastGC", rtm.LastGC)
-	monitor.setGaugeValue("Lookups", rtm.Lookups)
-	monitor.setGaugeValue("MCacheInuse", rtm.MCacheInuse)
-	monitor.setGaugeValue("MCacheSys", rtm.MCacheSys)
-	monitor.setGaugeValue("MSpanInuse", rtm.MSpanInuse)
-	monitor.setGaugeValue("MSpanSys", rtm.MSpanSys)
-	monitor.setGaugeValue("Mallocs", rtm.Mallocs)
-	monitor.setGaugeValue("NextGC", rtm.NextGC)
-	monitor.setGaugeValue("OtherSys", rtm.OtherSys)
-	monitor.setGaugeValue("PauseTotalNs", rtm.PauseTotalNs)
-	monitor.setGaugeValue("StackInuse", rtm.StackInuse)
-	monitor.setGaugeValue("StackSys", rtm.StackSys)
-	monitor.setGaugeValue("Sys", rtm.Sys)
-	monitor.setGaugeValue("TotalAlloc", rtm.TotalAlloc)
-	monitor.setGaugeValue("Frees", rtm.Frees)
-	monitor.setGaugeValue("NumGC", uint64(rtm.NumGC))
-	monitor.setGaugeValue("NumForcedGC", uint64(rtm.NumForcedGC))
-	monitor.setGaugeValue("GCCPUFraction", uint64(rtm.GCCPUFraction))
-	monitor.setGaugeValue(randomValueName, uint64(utils.GetRandomValue(minRandomValue, maxRandomValue)))
-	monitor.Mutex.Unlock()
+	monitor.setGaugeValue("Alloc", float64(rtm.Alloc))
+	monitor.setGaugeValue("BuckHashSys", float64(rtm.BuckHashSys))
+	monitor.setGaugeValue("GCSys", float64(rtm.GCSys))
+	monitor.setGaugeValue("HeapAlloc", float64(rtm.HeapAlloc))
+	monitor.setGaugeValue("HeapIdle", float64(rtm.HeapIdle))
+	monitor.setGaugeValue("HeapInuse", float64(rtm.HeapInuse))
+	monitor.setGaugeValue("HeapObjects", float64(rtm.HeapObjects))
+	monitor.setGaugeValue("HeapReleased", float64(rtm.HeapReleased))
+	monitor.setGaugeValue("HeapSys", float64(rtm.HeapSys))
+	monitor.setGaugeValue("LastGC", float64(rtm.LastGC))
+	monitor.setGaugeValue("Lookups", float64(rtm.Lookups))
+	monitor.setGaugeValue("MCacheInuse", float64(rtm.MCacheInuse))
+	monitor.setGaugeValue("MCacheSys", float64(rtm.MCacheSys))
+	monitor.setGaugeValue("MSpanInuse", float64(rtm.MSpanInuse))
+	monitor.setGaugeValue("MSpanSys", float64(rtm.MSpanSys))
+	monitor.setGaugeValue("Mallocs", float64(rtm.Mallocs))
+	monitor.setGaugeValue("NextGC", float64(rtm.NextGC))
+	monitor.setGaugeValue("OtherSys", float64(rtm.OtherSys))
+	monitor.setGaugeValue("PauseTotalNs", float64(rtm.PauseTotalNs))
+	monitor.setGaugeValue("StackInuse", float64(rtm.StackInuse))
+	monitor.setGaugeValue("StackSys", float64(rtm.StackSys))
+	monitor.setGaugeValue("Sys", float64(rtm.Sys))
+	monitor.setGaugeValue("TotalAlloc", float64(rtm.TotalAlloc))
+	monitor.setGaugeValue("Frees", float64(rtm.Frees))
+	monitor.setGaugeValue("NumGC", float64(rtm.NumGC))
+	monitor.setGaugeValue("NumForcedGC", float64(rtm.NumForcedGC))
+	monitor.setGaugeValue("GCCPUFraction", float64(rtm.GCCPUFraction))
 }
 
-func (monitor *Monitor) ReportStat(ctx context.Context, wg *sync.WaitGroup,
-	agentConfig *config.AgentConfig, reportInterval time.Duration,
-	reportSender sender.ReportSender) {
+func (monitor *Monitor) ReportStat(ctx context.Context, wg *sync.WaitGroup, reportInterval time.Duration) {
 	ticker := time.NewTicker(reportInterval)
 	defer ticker.Stop()
 	taskWg := &sync.WaitGroup{}
+	monitor.drainSpools(ctx, taskWg)
+	monitor.startDestinationWorkers()
+	// Reports already taken from collected metrics are finished on shutdown, until they are cancelled by CancelReports.
+	reportCtx, cancelReports := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelReports()
+	monitor.setCancelReports(cancelReports)
 	for {
 		select {
 		case <-ticker.C:
-			dataForSend := make([]MonitorValue, 0, len(monitor.Data))
-			monitor.Mutex.Lock()
-			for _, value := range monitor.Data {
-				dataForSend = append(dataForSend, value)
-			}
-			monitor.Mutex.Unlock()
-
-			dataChunks := chunkMonitorValueSlice(dataForSend, chunkForRequestSize)
+			dataChunks := chunkMonitorValueSlice(monitor.nextReport(), ChunkForRequestSize)
 
 			for _, chunk := range dataChunks {
 				taskWg.Add(1)
 				monitor.ReportTaskCh <- func() {
 					defer taskWg.Done()
-					monitor.reportStat(agentConfig, reportSender, chunk)
+					monitor.reportStat(reportCtx, chunk)
 				}
 			}
 		case <-ctx.Done():
 			taskWg.Wait()
+			monitor.stopDestinationWorkers()
 			close(monitor.ReportTaskCh)
 			wg.Done()
 			return
@@ -184,28 +215,135 @@ func (monitor *Monitor) ReportStat(ctx context.Context, wg *sync.WaitGroup,
 	}
 }
 
-func (monitor *Monitor) reportStat(agentConfig *config.AgentConfig,
-	reportSender sender.ReportSender,
-	data []MonitorValue,
-) {
-	requestURL := createURLForReportStat(agentConfig.Host)
-	body := createBody(data)
-	reportSender(agentConfig, requestURL, body)
-	monitor.resetPollCount()
+// CancelReports cancel reports started by ReportStat, which are still in flight, including their retries.
+// Metrics of cancelled reports are not lost: they stay in collected data or are owed to destinations,
+// so they are reported by Flush.
+func (monitor *Monitor) CancelReports() {
+	monitor.Lock()
+	defer monitor.Unlock()
+	monitor.reportsCancelled = true
+	if monitor.cancelReports != nil {
+		monitor.cancelReports()
+	}
+}
+
+func (monitor *Monitor) setCancelReports(cancel context.CancelFunc) {
+	monitor.Lock()
+	defer monitor.Unlock()
+	monitor.cancelReports = cancel
+	if monitor.reportsCancelled {
+		cancel()
+	}
+}
+
+// Flush collect metrics once more and report them without waiting for next tick.
+// It is called on shutdown after CollectStat and ReportStat are stopped, so metrics collected since last report
+// are not lost. Metrics not delivered until context is done are queued in spools of destinations.
+func (monitor *Monitor) Flush(ctx context.Context) {
+	monitor.collectStat()
+	monitor.collectFromCollectors(ctx)
+	for _, chunk := range chunkMonitorValueSlice(monitor.nextReport(), ChunkForRequestSize) {
+		monitor.reportStat(ctx, chunk)
+	}
+}
+
+// nextReport take metrics for report. In delta report mode only changed metrics are taken.
+func (monitor *Monitor) nextReport() []MonitorValue {
+	data := monitor.prepareReport()
+	if monitor.deltaReport {
+		data = monitor.selectChanged(data, time.Now())
+	}
+	return data
 }
 
-func (monitor *Monitor) setGaugeValue(name string, value uint64) {
+func (monitor *Monitor) reportStat(ctx context.Context, data []MonitorValue) {
+	reported, checked := monitor.pipeline.apply(data)
+	metricsList := createMetricsList(reported, monitor.labeler)
+	acknowledge := func() {
+		monitor.pipeline.acknowledge(checked)
+		if monitor.deltaReport {
+			monitor.acknowledge(data)
+		}
+	}
+	if len(metricsList) == 0 {
+		monitor.releaseCounters(data, true)
+		acknowledge()
+		return
+	}
+	if len(monitor.destinations) > 1 {
+		// Counter deltas are owned by destinations since now, undelivered ones are kept by destinations.
+		monitor.releaseCounters(data, true)
+		monitor.broadcast(ctx, metricsList, acknowledge)
+		return
+	}
+	delivered := len(monitor.destinations) == 1 &&
+		monitor.deliverOwned(ctx, &monitor.destinations[0], metricsList, false)
+	monitor.releaseCounters(data, delivered)
+	if delivered {
+		acknowledge()
+	}
+}
+
+// prepareReport take snapshot of collected metrics. Counter deltas of snapshot are reserved
+// until report is finished, so concurrent reports never send the same increments twice.
+func (monitor *Monitor) prepareReport() []MonitorValue {
+	monitor.Mutex.Lock()
+	defer monitor.Mutex.Unlock()
+	if monitor.aggregator != nil {
+		monitor.aggregator.flush(monitor.Data)
+	}
+	data := make([]MonitorValue, 0, len(monitor.Data))
+	for id, value := range monitor.Data {
+		if value.Type == entity.TypeCounter {
+			value.Delta -= monitor.reserved[id]
+			if value.Delta != 0 {
+				monitor.reserved[id] += value.Delta
+			}
+		}
+		data = append(data, value)
+	}
+	return data
+}
+
+// releaseCounters release deltas reserved by prepareReport.
+// Delivered deltas are subtracted from collected counters, otherwise they will be reported next time.
+func (monitor *Monitor) releaseCounters(data []MonitorValue, delivered bool) {
+	monitor.Mutex.Lock()
+	defer monitor.Mutex.Unlock()
+	for _, value := range data {
+		if value.Type != entity.TypeCounter {
+			continue
+		}
+		id := value.ID()
+		monitor.reserved[id] -= value.Delta
+		if monitor.reserved[id] == 0 {
+			delete(monitor.reserved, id)
+		}
+		if !delivered {
+			continue
+		}
+		if current, ok := monitor.Data[id]; ok {
+			current.Delta -= value.Delta
+			monitor.Data[id] = current
+		}
+	}
+}
+
+func (monitor *Monitor) setGaugeValue(name string, value float64) {
 	monitor.Data[name] = MonitorValue{
 		Name:  name,
 		Value: value,
 		Type:  entity.TypeGauge,
 	}
+	if monitor.aggregator != nil {
+		monitor.aggregator.observe(monitor.Data[name])
+	}
 }
 
 func (monitor *Monitor) updatePollCount() {
 	_, ok := monitor.Data[counterName]
 	if !ok {
-		firstValue := 1
+		var firstValue int64 = 1
 		monitor.Data[counterName] = MonitorValue{Name: counterName, Type: entity.TypeCounter, Delta: firstValue}
 		return
 	}
@@ -217,39 +355,35 @@ func (monitor *Monitor) updatePollCount() {
 		Delta: newValue,
 	}
 }
-func (monitor *Monitor) resetPollCount() {
-	nullValue := 0
-	monitor.Data[counterName] = MonitorValue{
-		Name:  counterName,
-		Type:  entity.TypeCounter,
-		Delta: nullValue,
-	}
-}
 
-func createBody(data []MonitorValue) string {
+// createMetricsList convert values to metrics DTO. Labels are attached by labeler, when it is given.
+func createMetricsList(data []MonitorValue, labeler *Labeler) []dto.Metrics {
 	metricsList := make([]dto.Metrics, 0, len(data))
 	for _, monitorValue := range data {
 		m := dto.Metrics{
 			ID:    monitorValue.Name,
 			MType: monitorValue.Type,
 		}
+		if err := labeler.apply(&m, monitorValue); err != nil {
+			logger.Log.Warnf("failed label metrics: %v", err)
+		}
 		if monitorValue.Type == entity.TypeCounter {
-			int64Value := int64(monitorValue.Delta)
-			m.Delta = &int64Value
+			delta := monitorValue.Delta
+			m.Delta = &delta
 		}
 		if monitorValue.Type == entity.TypeGauge {
-			float64Value := float64(monitorValue.Value)
-			m.Value = &float64Value
+			value := monitorValue.Value
+			m.Value = &value
 		}
 		metricsList = append(metricsList, m)
 	}
 
-	body, _ := json.Marshal(&metricsList)
-	return string(body)
+	return metricsList
 }
 
-func createURLForReportStat(host string) string {
-	return fmt.Sprintf("http://" + host + "/updates")
+func createBody(metricsList []dto.Metrics) string {
+	body, _ := json.Marshal(&metricsList)
+	return string(body)
 }
 
 func chunkMonitorValueSlice(slice []MonitorValue, chunkSize int) [][]MonitorValue {
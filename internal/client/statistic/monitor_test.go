@@ -1,7 +1,21 @@
 package statistic
 
 import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/client/sender"
+	"github.com/ilya372317/must-have-metrics/internal/client/spool"
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/router"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/ilya372317/must-have-metrics/internal/storage"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -40,7 +54,7 @@ func TestMonitor_collectStat(t *testing.T) {
 
 			pollCount, pollCountExist := monitor.Data["PollCount"]
 			require.True(t, pollCountExist)
-			assert.Equal(t, 1, pollCount.Delta)
+			assert.Equal(t, int64(1), pollCount.Delta)
 			randomValue, randomValueExist := monitor.Data["RandomValue"]
 			require.True(t, randomValueExist)
 			if randomValue.Value <= 0 {
@@ -63,3 +77,195 @@ func BenchmarkMonitor_collectStat1(b *testing.B) {
 		m.collectStat()
 	}
 }
+
+func TestMonitor_reportStatSpool(t *testing.T) {
+	require.NoError(t, logger.Init())
+	metricsSpool, err := spool.New(t.TempDir(), 0, ChunkForRequestSize)
+	require.NoError(t, err)
+	monitor := New(1)
+	monitor.SetSpool(metricsSpool)
+
+	serverDown := true
+	sentBodies := make([]string, 0)
+	reportSender := sender.ReportSenderFunc(func(_ context.Context, body string) error {
+		if serverDown {
+			return errors.New("server is down")
+		}
+		sentBodies = append(sentBodies, body)
+		return nil
+	})
+	monitor.SetSender(reportSender)
+
+	monitor.Data["PollCount"] = MonitorValue{Name: "PollCount", Type: entity.TypeCounter, Delta: 2}
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+	assert.Equal(t, 1, metricsSpool.Len())
+
+	serverDown = false
+	monitor.updatePollCount()
+	monitor.updatePollCount()
+	monitor.updatePollCount()
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+	assert.Empty(t, sentBodies, "metrics are queued while spool is not empty")
+	assert.Equal(t, int64(0), monitor.Data["PollCount"].Delta)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		metricsSpool.Drain(ctx, func(ctx context.Context, metricsList []dto.Metrics) error {
+			return reportSender.Send(ctx, createBody(metricsList))
+		})
+		close(done)
+	}()
+	require.Eventually(t, func() bool {
+		return metricsSpool.Len() == 0
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+	assert.Equal(t, []string{`[{"delta":5,"id":"PollCount","type":"counter"}]`}, sentBodies)
+}
+
+func TestMonitor_reportStatCounters(t *testing.T) {
+	require.NoError(t, logger.Init())
+	monitor := New(1)
+	monitor.Data["PollCount"] = MonitorValue{Name: "PollCount", Type: entity.TypeCounter, Delta: 3}
+	monitor.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter, Delta: 5}
+
+	monitor.SetSender(sender.ReportSenderFunc(func(context.Context, string) error {
+		return errors.New("server is down")
+	}))
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+	assert.Equal(t, int64(3), monitor.Data["PollCount"].Delta, "failed report keeps deltas")
+	assert.Equal(t, int64(5), monitor.Data["Events"].Delta, "failed report keeps deltas")
+
+	sentBodies := make([]string, 0)
+	data := monitor.prepareReport()
+	monitor.updatePollCount()
+	concurrentReport := monitor.prepareReport()
+	monitor.SetSender(sender.ReportSenderFunc(func(_ context.Context, body string) error {
+		sentBodies = append(sentBodies, body)
+		return nil
+	}))
+	monitor.reportStat(context.Background(), data)
+	assert.Equal(t, int64(1), monitor.Data["PollCount"].Delta, "increment made during report is kept")
+	assert.Equal(t, int64(0), monitor.Data["Events"].Delta)
+	assert.ElementsMatch(t, []MonitorValue{
+		{Name: "PollCount", Type: entity.TypeCounter, Delta: 1},
+		{Name: "Events", Type: entity.TypeCounter},
+	}, concurrentReport, "concurrent report does not send reserved deltas")
+	assert.Len(t, sentBodies, 1)
+}
+
+func TestMonitor_reportStatPrecision(t *testing.T) {
+	require.NoError(t, logger.Init())
+	repository := storage.NewInMemoryStorage()
+	server := httptest.NewServer(router.AlertRouter(repository, &config.ServerConfig{SecretKey: "secret"}))
+	defer server.Close()
+	agentConfig := &config.AgentConfig{
+		Host:      strings.TrimPrefix(server.URL, "http://"),
+		SecretKey: "secret",
+	}
+
+	monitor := New(1)
+	monitor.Data["GCCPUFraction"] = gaugeValue("GCCPUFraction", 0.0123456789)
+	monitor.Data["Temperature"] = gaugeValue("Temperature", -12.5)
+	monitor.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter, Delta: 1 << 40}
+	reportSender, err := sender.NewHTTPSender(agentConfig)
+	require.NoError(t, err)
+	monitor.SetSender(reportSender)
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+
+	tests := []struct {
+		name       string
+		wantFloat  float64
+		wantInt    int64
+		isFloatVal bool
+	}{
+		{name: "GCCPUFraction", wantFloat: 0.0123456789, isFloatVal: true},
+		{name: "Temperature", wantFloat: -12.5, isFloatVal: true},
+		{name: "Events", wantInt: 1 << 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alert, err := repository.Get(context.Background(), tt.name)
+			require.NoError(t, err)
+			if tt.isFloatVal {
+				require.NotNil(t, alert.FloatValue)
+				assert.Equal(t, tt.wantFloat, *alert.FloatValue)
+				return
+			}
+			require.NotNil(t, alert.IntValue)
+			assert.Equal(t, tt.wantInt, *alert.IntValue)
+		})
+	}
+	assert.Equal(t, int64(0), monitor.Data["Events"].Delta)
+}
+
+type circuitSender struct {
+	sender.ReportSenderFunc
+	state int
+}
+
+func (s *circuitSender) CircuitState() int {
+	return s.state
+}
+
+func TestMonitor_collectStatCircuitState(t *testing.T) {
+	monitor := New(1)
+	monitor.collectStat()
+	_, ok := monitor.Data[circuitStateName]
+	assert.False(t, ok, "sender without circuit breaker has no state")
+
+	monitor.SetSender(&circuitSender{state: sender.CircuitOpen})
+	monitor.collectStat()
+	assert.Equal(t, gaugeValue(circuitStateName, sender.CircuitOpen), monitor.Data[circuitStateName])
+}
+
+func TestMonitor_Flush(t *testing.T) {
+	require.NoError(t, logger.Init())
+	tests := []struct {
+		name       string
+		serverDown bool
+		wantSpool  int
+	}{
+		{name: "delivered case"},
+		{name: "deadline exceeded case", serverDown: true, wantSpool: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spoolDir := t.TempDir()
+			metricsSpool, err := spool.New(spoolDir, 0, ChunkForRequestSize)
+			require.NoError(t, err)
+			monitor := New(1)
+			monitor.SetSpool(metricsSpool)
+			sentBodies := make([]string, 0)
+			monitor.SetSender(sender.ReportSenderFunc(func(ctx context.Context, body string) error {
+				if tt.serverDown {
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				sentBodies = append(sentBodies, body)
+				return nil
+			}))
+			monitor.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter, Delta: 5}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			monitor.Flush(ctx)
+
+			assert.Equal(t, int64(0), monitor.Data["Events"].Delta, "deltas are sent or spooled")
+			_, collected := monitor.Data["Alloc"]
+			assert.True(t, collected, "metrics are collected once more before final report")
+			if tt.serverDown {
+				assert.Empty(t, sentBodies)
+			} else {
+				require.Len(t, sentBodies, 1)
+				assert.Contains(t, sentBodies[0], `{"delta":5,"id":"Events","type":"counter"}`)
+				assert.Contains(t, sentBodies[0], `{"delta":1,"id":"PollCount","type":"counter"}`)
+			}
+
+			restartedSpool, err := spool.New(spoolDir, 0, ChunkForRequestSize)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSpool, restartedSpool.Len(), "unsent metrics are kept for next start")
+		})
+	}
+}
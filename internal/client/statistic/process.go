@@ -0,0 +1,199 @@
+package statistic
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// Orders of process groups in process collector.
+const (
+	ProcessTopByCPU = "cpu"
+	ProcessTopByRSS = "rss"
+)
+
+const defaultProcessLimit = 10
+
+// ProcessCollector collects cpu percentage, rss, open file descriptors and threads count of processes.
+//
+// Processes are grouped by name, so process restarts do not produce new metrics.
+// Number of reported groups is limited, groups which are not reported anymore are removed from Monitor.
+type ProcessCollector struct {
+	processes map[int32]cachedProcess
+	topBy     string
+	patterns  []*regexp.Regexp
+	limit     int
+}
+
+// cachedProcess process object kept between collects with its create time,
+// so process object is not reused for new process with the same pid.
+type cachedProcess struct {
+	process    *process.Process
+	createTime int64
+}
+
+type processGroup struct {
+	name       string
+	cpuPercent float64
+	rss        uint64
+	openFiles  uint64
+	threads    uint64
+	count      uint64
+}
+
+// NewProcessCollector constructor for ProcessCollector.
+func NewProcessCollector(cnfg config.ProcessMetricsConfig) (*ProcessCollector, error) {
+	patterns := make([]*regexp.Regexp, 0, len(cnfg.Patterns))
+	for _, pattern := range cnfg.Patterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid process name pattern %q: %w", pattern, err)
+		}
+		patterns = append(patterns, compiled)
+	}
+
+	topBy := cnfg.TopBy
+	switch topBy {
+	case "":
+		topBy = ProcessTopByCPU
+	case ProcessTopByCPU, ProcessTopByRSS:
+	default:
+		return nil, fmt.Errorf("invalid process top_by value %q, expected %s or %s",
+			topBy, ProcessTopByCPU, ProcessTopByRSS)
+	}
+
+	limit := int(cnfg.Limit)
+	if limit == 0 {
+		limit = defaultProcessLimit
+	}
+
+	return &ProcessCollector{
+		processes: make(map[int32]cachedProcess),
+		patterns:  patterns,
+		topBy:     topBy,
+		limit:     limit,
+	}, nil
+}
+
+// Collect read stats of running processes.
+func (c *ProcessCollector) Collect(ctx context.Context) ([]MonitorValue, error) {
+	processes, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed get processes list: %w", err)
+	}
+
+	alive := make(map[int32]cachedProcess, len(processes))
+	groups := make(map[string]*processGroup)
+	for _, p := range processes {
+		createTime, err := p.CreateTimeWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		// Cached process object keeps cpu times and name of previous collect, it is required for cpu percentage.
+		// Pid may be reused by new process, so cached object is used only for the same create time.
+		if cached, ok := c.processes[p.Pid]; ok && cached.createTime == createTime {
+			p = cached.process
+		}
+		name, err := p.NameWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		if !c.isMatched(name) {
+			continue
+		}
+		alive[p.Pid] = cachedProcess{process: p, createTime: createTime}
+
+		group, ok := groups[name]
+		if !ok {
+			group = &processGroup{name: name}
+			groups[name] = group
+		}
+		c.fillGroup(ctx, p, group)
+	}
+	c.processes = alive
+
+	values := make([]MonitorValue, 0)
+	for _, group := range c.selectGroups(groups) {
+		instance := escapeProcessName(group.name)
+		values = append(values,
+			gaugeValue("ProcessCPUPercent"+instanceSeparator+instance, group.cpuPercent),
+			gaugeValue("ProcessRSS"+instanceSeparator+instance, float64(group.rss)),
+			gaugeValue("ProcessOpenFiles"+instanceSeparator+instance, float64(group.openFiles)),
+			gaugeValue("ProcessThreads"+instanceSeparator+instance, float64(group.threads)),
+			gaugeValue("ProcessCount"+instanceSeparator+instance, float64(group.count)),
+		)
+	}
+
+	return values, nil
+}
+
+// fillGroup add process stats to group. Processes may exit or be not accessible, such stats are skipped.
+func (c *ProcessCollector) fillGroup(ctx context.Context, p *process.Process, group *processGroup) {
+	group.count++
+	if cpuPercent, err := p.PercentWithContext(ctx, 0); err == nil {
+		group.cpuPercent += cpuPercent
+	}
+	if memoryInfo, err := p.MemoryInfoWithContext(ctx); err == nil {
+		group.rss += memoryInfo.RSS
+	}
+	if fds, err := p.NumFDsWithContext(ctx); err == nil {
+		group.openFiles += uint64(fds)
+	}
+	if threads, err := p.NumThreadsWithContext(ctx); err == nil {
+		group.threads += uint64(threads)
+	}
+}
+
+func (c *ProcessCollector) isMatched(name string) bool {
+	if len(c.patterns) == 0 {
+		return true
+	}
+	for _, pattern := range c.patterns {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectGroups return groups with biggest resources consumption, but no more than configured limit.
+func (c *ProcessCollector) selectGroups(groups map[string]*processGroup) []*processGroup {
+	selected := make([]*processGroup, 0, len(groups))
+	for _, group := range groups {
+		selected = append(selected, group)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if c.topBy == ProcessTopByRSS && selected[i].rss != selected[j].rss {
+			return selected[i].rss > selected[j].rss
+		}
+		if c.topBy == ProcessTopByCPU && selected[i].cpuPercent != selected[j].cpuPercent {
+			return selected[i].cpuPercent > selected[j].cpuPercent
+		}
+		return selected[i].name < selected[j].name
+	})
+	if len(selected) > c.limit {
+		selected = selected[:c.limit]
+	}
+
+	return selected
+}
+
+// escapeProcessName convert process name to part of metric name. Unlike sanitizeInstance, different names
+// always give different results: every char except letters and digits is replaced by _ and its hex code.
+func escapeProcessName(name string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(name); i++ {
+		char := name[i]
+		if 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || '0' <= char && char <= '9' {
+			escaped.WriteByte(char)
+			continue
+		}
+		fmt.Fprintf(&escaped, "%s%02x", instanceSeparator, char)
+	}
+	return escaped.String()
+}
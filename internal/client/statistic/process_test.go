@@ -0,0 +1,145 @@
+package statistic
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProcessCollector(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  config.ProcessMetricsConfig
+		wantErr bool
+	}{
+		{
+			name:    "default config case",
+			config:  config.ProcessMetricsConfig{},
+			wantErr: false,
+		},
+		{
+			name:    "invalid pattern case",
+			config:  config.ProcessMetricsConfig{Patterns: []string{"[invalid"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid top by case",
+			config:  config.ProcessMetricsConfig{TopBy: "disk"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewProcessCollector(tt.config)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestProcessCollector_Collect(t *testing.T) {
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	processName := filepath.Base(executable)
+	instance := escapeProcessName(processName)
+
+	t.Run("pattern case", func(t *testing.T) {
+		collector, err := NewProcessCollector(config.ProcessMetricsConfig{
+			Patterns: []string{"^" + regexp.QuoteMeta(processName) + "$"},
+		})
+		require.NoError(t, err)
+		values, err := collector.Collect(context.Background())
+		require.NoError(t, err)
+
+		got := make(map[string]MonitorValue, len(values))
+		for _, value := range values {
+			got[value.Name] = value
+		}
+		require.Len(t, got, 5)
+		assert.Equal(t, float64(1), got["ProcessCount_"+instance].Value)
+		assert.Positive(t, got["ProcessRSS_"+instance].Value)
+		assert.Positive(t, got["ProcessThreads_"+instance].Value)
+		assert.Contains(t, got, "ProcessCPUPercent_"+instance)
+		assert.Contains(t, got, "ProcessOpenFiles_"+instance)
+	})
+
+	t.Run("top by rss case", func(t *testing.T) {
+		collector, err := NewProcessCollector(config.ProcessMetricsConfig{TopBy: ProcessTopByRSS, Limit: 1})
+		require.NoError(t, err)
+		values, err := collector.Collect(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, values, 5)
+	})
+}
+
+func TestProcessCollector_selectGroups(t *testing.T) {
+	groups := map[string]*processGroup{
+		"nginx":    {name: "nginx", cpuPercent: 5, rss: 300},
+		"postgres": {name: "postgres", cpuPercent: 20, rss: 100},
+		"agent":    {name: "agent", cpuPercent: 1, rss: 200},
+		"bash":     {name: "bash", cpuPercent: 1, rss: 10},
+	}
+	tests := []struct {
+		name  string
+		topBy string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "top by cpu",
+			topBy: ProcessTopByCPU,
+			limit: 3,
+			want:  []string{"postgres", "nginx", "agent"},
+		},
+		{
+			name:  "top by rss",
+			topBy: ProcessTopByRSS,
+			limit: 2,
+			want:  []string{"nginx", "agent"},
+		},
+		{
+			name:  "limit bigger than groups count",
+			topBy: ProcessTopByRSS,
+			limit: 10,
+			want:  []string{"nginx", "agent", "postgres", "bash"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := &ProcessCollector{topBy: tt.topBy, limit: tt.limit}
+			selected := collector.selectGroups(groups)
+			names := make([]string, 0, len(selected))
+			for _, group := range selected {
+				names = append(names, group.name)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func Test_escapeProcessName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "nginx", want: "nginx"},
+		{name: "a-b", want: "a_2db"},
+		{name: "a.b", want: "a_2eb"},
+		{name: "a_2db", want: "a_5f2db"},
+		{name: "kworker/0:1", want: "kworker_2f0_3a1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, escapeProcessName(tt.name))
+		})
+	}
+}
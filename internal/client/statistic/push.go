@@ -0,0 +1,226 @@
+package statistic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/ilya372317/must-have-metrics/internal/server/middleware"
+)
+
+const (
+	unixSocketPrefix       = "unix:"
+	defaultPushMaxSeries   = 1000
+	maxPushBodySize        = 1024 * 1024
+	localShutdownTimeout   = 5 * time.Second
+	localReadHeaderTimeout = 5 * time.Second
+)
+
+// PushCollector serves local endpoint, which accepts metrics from applications in /updates format.
+//
+// Counters are summed up and gauges keep last value until metrics are polled by Monitor.
+// Pushed gauges are reported on every poll until new value is pushed or gauge is expired by ttl.
+// Only not expired gauges and not polled counters are counted in series limit.
+type PushCollector struct {
+	listener  net.Listener
+	gauges    map[string]pushedGauge
+	counters  map[string]MonitorValue
+	maxSeries int
+	gaugeTTL  time.Duration
+	mu        sync.Mutex
+}
+
+type pushedGauge struct {
+	pushedAt time.Time
+	value    MonitorValue
+}
+
+// NewPushCollector constructor for PushCollector. Starts listening given address immediately,
+// so busy address or not loopback host is reported on agent start.
+// Gauges expire after defaultGaugeTTL, if ttl is not configured.
+func NewPushCollector(cnfg config.PushMetricsConfig, defaultGaugeTTL time.Duration) (*PushCollector, error) {
+	listener, err := listenLocal(cnfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	maxSeries := int(cnfg.MaxSeries)
+	if maxSeries == 0 {
+		maxSeries = defaultPushMaxSeries
+	}
+	gaugeTTL := time.Duration(cnfg.GaugeTTL) * time.Second
+	if gaugeTTL == 0 {
+		gaugeTTL = defaultGaugeTTL
+	}
+
+	return &PushCollector{
+		listener:  listener,
+		gauges:    make(map[string]pushedGauge),
+		counters:  make(map[string]MonitorValue),
+		maxSeries: maxSeries,
+		gaugeTTL:  gaugeTTL,
+	}, nil
+}
+
+// Run serve pushed metrics until context is done.
+func (c *PushCollector) Run(ctx context.Context) {
+	srv := &http.Server{
+		Handler:           c.Handler(),
+		ReadHeaderTimeout: localReadHeaderTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), localShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(timeoutCtx); err != nil {
+			logger.Log.Errorf("failed shutdown push endpoint: %v", err)
+		}
+	}()
+	logger.Log.Infof("push endpoint is listening on %s", c.listener.Addr())
+	if err := srv.Serve(c.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Log.Errorf("push endpoint stopped: %v", err)
+	}
+}
+
+// Collect return pushed metrics.
+func (c *PushCollector) Collect(context.Context) ([]MonitorValue, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expireGauges(time.Now())
+	values := make([]MonitorValue, 0, len(c.gauges)+len(c.counters))
+	for _, gauge := range c.gauges {
+		values = append(values, gauge.value)
+	}
+	for _, counter := range c.counters {
+		values = append(values, counter)
+	}
+	c.counters = make(map[string]MonitorValue)
+
+	return values, nil
+}
+
+// Handler return router of push endpoint.
+func (c *PushCollector) Handler() http.Handler {
+	router := chi.NewRouter()
+	router.Use(middleware.Compressed())
+	router.Route("/updates", func(r chi.Router) {
+		r.Post("/", c.handleUpdates)
+	})
+	return router
+}
+
+func (c *PushCollector) handleUpdates(writer http.ResponseWriter, request *http.Request) {
+	request.Body = http.MaxBytesReader(writer, request.Body, maxPushBodySize)
+	metricsList, err := dto.NewMetricsListDTOFromRequest(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("failed create metricsList dto: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	values := make([]MonitorValue, 0, len(metricsList))
+	for _, metrics := range metricsList {
+		value, err := monitorValueFromMetrics(metrics)
+		if err != nil {
+			http.Error(writer, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		values = append(values, value)
+	}
+
+	if err = c.store(values); err != nil {
+		http.Error(writer, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+// store save all values or nothing, if values contain new series over limit.
+func (c *PushCollector) store(values []MonitorValue) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.expireGauges(now)
+
+	newSeries := make(map[string]struct{})
+	for _, value := range values {
+		id := value.ID()
+		if c.hasSeries(id) {
+			continue
+		}
+		newSeries[id] = struct{}{}
+		if len(c.gauges)+len(c.counters)+len(newSeries) > c.maxSeries {
+			return fmt.Errorf("series limit %d exceeded, metrics %q rejected", c.maxSeries, id)
+		}
+	}
+
+	for _, value := range values {
+		id := value.ID()
+		if value.Type == entity.TypeCounter {
+			delete(c.gauges, id)
+			c.counters[id] = addDelta(c.counters[id], value)
+			continue
+		}
+		delete(c.counters, id)
+		c.gauges[id] = pushedGauge{value: value, pushedAt: now}
+	}
+	return nil
+}
+
+// expireGauges remove gauges, which were not pushed during ttl.
+func (c *PushCollector) expireGauges(now time.Time) {
+	if c.gaugeTTL <= 0 {
+		return
+	}
+	for id, gauge := range c.gauges {
+		if now.Sub(gauge.pushedAt) > c.gaugeTTL {
+			delete(c.gauges, id)
+		}
+	}
+}
+
+func (c *PushCollector) hasSeries(id string) bool {
+	if _, ok := c.gauges[id]; ok {
+		return true
+	}
+	_, ok := c.counters[id]
+	return ok
+}
+
+// listenLocal listen Unix socket, if address has "unix:" prefix, or TCP address on loopback interface.
+func listenLocal(address string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, unixSocketPrefix); ok {
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if err = os.Remove(path); err != nil {
+				return nil, fmt.Errorf("failed remove stale socket %s: %w", path, err)
+			}
+		}
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("failed listen unix socket %s: %w", path, err)
+		}
+		return listener, nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", address, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("address %q is not on loopback interface", address)
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed listen %s: %w", address, err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,150 @@
+package statistic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushCollector_handleUpdates(t *testing.T) {
+	require.NoError(t, logger.Init())
+	tests := []struct {
+		name       string
+		bodies     []string
+		maxSeries  uint
+		wantStatus int
+		want       []MonitorValue
+	}{
+		{
+			name: "aggregate case",
+			bodies: []string{
+				`[{"id":"Jobs","type":"counter","delta":2},{"id":"Queue","type":"gauge","value":5}]`,
+				`[{"id":"Jobs","type":"counter","delta":3},{"id":"Queue","type":"gauge","value":7}]`,
+			},
+			wantStatus: http.StatusOK,
+			want: []MonitorValue{
+				{Name: "Jobs", Type: entity.TypeCounter, Delta: 5},
+				{Name: "Queue", Type: entity.TypeGauge, Value: 7},
+			},
+		},
+		{
+			name:       "invalid json case",
+			bodies:     []string{`[{"id":"Jobs"`},
+			wantStatus: http.StatusBadRequest,
+			want:       []MonitorValue{},
+		},
+		{
+			name:       "invalid metrics case",
+			bodies:     []string{`[{"id":"Jobs","type":"counter","value":1}]`},
+			wantStatus: http.StatusBadRequest,
+			want:       []MonitorValue{},
+		},
+		{
+			name: "series limit case",
+			bodies: []string{
+				`[{"id":"Jobs","type":"counter","delta":1}]`,
+				`[{"id":"Jobs","type":"counter","delta":1},{"id":"Queue","type":"gauge","value":1}]`,
+			},
+			maxSeries:  1,
+			wantStatus: http.StatusTooManyRequests,
+			want:       []MonitorValue{{Name: "Jobs", Type: entity.TypeCounter, Delta: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pushConfig := config.PushMetricsConfig{Address: "127.0.0.1:0", MaxSeries: tt.maxSeries}
+			collector, err := NewPushCollector(pushConfig, time.Minute)
+			require.NoError(t, err)
+			defer func() {
+				_ = collector.listener.Close()
+			}()
+
+			handler := collector.Handler()
+			status := 0
+			for _, body := range tt.bodies {
+				request := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, request)
+				status = recorder.Code
+			}
+			assert.Equal(t, tt.wantStatus, status)
+
+			got, err := collector.Collect(context.Background())
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+}
+
+func TestPushCollector_Collect(t *testing.T) {
+	collector := &PushCollector{
+		gauges:    make(map[string]pushedGauge),
+		counters:  make(map[string]MonitorValue),
+		maxSeries: defaultPushMaxSeries,
+	}
+	require.NoError(t, collector.store([]MonitorValue{
+		gaugeValue("Queue", 3),
+		{Name: "Jobs", Type: entity.TypeCounter, Delta: 4},
+	}))
+
+	values, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, values, 2)
+
+	values, err = collector.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []MonitorValue{gaugeValue("Queue", 3)}, values)
+}
+
+func TestPushCollector_expireGauges(t *testing.T) {
+	collector := &PushCollector{
+		gauges:    make(map[string]pushedGauge),
+		counters:  make(map[string]MonitorValue),
+		maxSeries: 1,
+		gaugeTTL:  time.Minute,
+	}
+	require.NoError(t, collector.store([]MonitorValue{gaugeValue("Queue", 3)}))
+	require.Error(t, collector.store([]MonitorValue{{Name: "Jobs", Type: entity.TypeCounter, Delta: 1}}))
+
+	collector.gauges["Queue"] = pushedGauge{value: gaugeValue("Queue", 3), pushedAt: time.Now().Add(-2 * time.Minute)}
+	require.NoError(t, collector.store([]MonitorValue{{Name: "Jobs", Type: entity.TypeCounter, Delta: 1}}))
+
+	values, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []MonitorValue{{Name: "Jobs", Type: entity.TypeCounter, Delta: 1}}, values)
+}
+
+func TestListenLocal(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		wantErr bool
+	}{
+		{name: "loopback ip case", address: "127.0.0.1:0"},
+		{name: "localhost case", address: "localhost:0"},
+		{name: "unix socket case", address: "unix:" + filepath.Join(t.TempDir(), "agent.sock")},
+		{name: "public address case", address: "0.0.0.0:0", wantErr: true},
+		{name: "invalid address case", address: "localhost", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := listenLocal(tt.address)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NoError(t, listener.Close())
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package statistic
+
+import "github.com/ilya372317/must-have-metrics/internal/server/entity"
+
+// Inherit take over state of stopped monitor, which is replaced after config reload:
+// not reported counter deltas, counters owed to destinations with the same names,
+// delivered gauges of delta report mode and self-metrics totals.
+// Gauges are not inherited, they are collected again by new collectors.
+// Previous monitor must not be used after call.
+func (monitor *Monitor) Inherit(previous *Monitor) {
+	previous.Mutex.Lock()
+	defer previous.Mutex.Unlock()
+	monitor.Mutex.Lock()
+	defer monitor.Mutex.Unlock()
+
+	previous.collectSelfStats()
+	for id, value := range previous.Data {
+		if value.Type == entity.TypeCounter && value.Delta != 0 {
+			monitor.Data[id] = value
+		}
+	}
+	if monitor.deltaReport && previous.deltaReport {
+		monitor.acked = previous.acked
+		monitor.lastFullResync = previous.lastFullResync
+	}
+	monitor.inheritStats(previous)
+	monitor.inheritOwed(previous)
+}
@@ -0,0 +1,77 @@
+package statistic
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitor_Inherit(t *testing.T) {
+	require.NoError(t, logger.Init())
+	pushCollector, err := NewPushCollector(config.PushMetricsConfig{Address: "127.0.0.1:0"}, time.Minute)
+	require.NoError(t, err)
+	start := time.Now()
+	previous := New(1, pushCollector)
+	previous.SetDeltaReport(time.Minute)
+	previous.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter, Delta: 3}
+	previous.Data["Reported"] = MonitorValue{Name: "Reported", Type: entity.TypeCounter}
+	previous.setGaugeValue("Alloc", 1)
+	previous.selectChanged(nil, start)
+	previous.acknowledge([]MonitorValue{gaugeValue("Alloc", 1)})
+	previous.stats.sendsAttempted.Add(2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go previous.CollectStat(ctx, wg, time.Hour)
+	require.NoError(t, pushCollector.store([]MonitorValue{{Name: "Pushed", Type: entity.TypeCounter, Delta: 4}}))
+	cancel()
+	wg.Wait()
+
+	monitor := New(1)
+	monitor.SetDeltaReport(time.Minute)
+	monitor.Inherit(previous)
+
+	assert.Equal(t, int64(3), monitor.Data["Events"].Delta)
+	assert.Equal(t, int64(4), monitor.Data["Pushed"].Delta, "metrics pushed before stop are kept")
+	assert.Equal(t, int64(2), monitor.Data[SelfSendsAttempted].Delta)
+	assert.NotContains(t, monitor.Data, "Reported")
+	assert.NotContains(t, monitor.Data, "Alloc", "gauges are collected again")
+	assert.Equal(t, int64(2), monitor.Status().SendsAttempted)
+	assert.Equal(t, previous.stats.startedAt, monitor.stats.startedAt)
+
+	assert.Empty(t, monitor.selectChanged([]MonitorValue{gaugeValue("Alloc", 1)}, start.Add(time.Second)),
+		"delivered gauges are inherited")
+}
+
+func TestMonitor_InheritOwedCounters(t *testing.T) {
+	require.NoError(t, logger.Init())
+	previous := New(1)
+	previous.SetDestinations(
+		Destination{Name: "old", Sender: &recordingSender{err: errors.New("connection refused")}},
+		Destination{Name: "new", Sender: &recordingSender{}},
+	)
+	previous.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter, Delta: 2}
+	previous.reportStat(context.Background(), previous.prepareReport())
+
+	oldServer := &recordingSender{}
+	monitor := New(1)
+	monitor.SetDestinations(
+		Destination{Name: "old", Sender: oldServer},
+		Destination{Name: "new", Sender: &recordingSender{}},
+	)
+	monitor.Inherit(previous)
+	monitor.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter, Delta: 1}
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+
+	require.Len(t, oldServer.bodies, 1)
+	assert.Contains(t, oldServer.bodies[0], `{"delta":3,"id":"Events","type":"counter"}`)
+}
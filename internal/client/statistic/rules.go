@@ -0,0 +1,182 @@
+package statistic
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+// Actions of pipeline rules.
+const (
+	RuleInclude       = "include"
+	RuleExclude       = "exclude"
+	RuleRename        = "rename"
+	RuleReplace       = "replace"
+	RuleSetType       = "set_type"
+	RuleDropUnchanged = "drop_unchanged"
+)
+
+// Pipeline rules applied to reported metrics. Rules do not change collected metrics,
+// so counters accounting works with original names and types.
+//
+// Gauges converted to counters are reported with increase since value of last delivered report,
+// first value is used as baseline and decreased value means counter was reset.
+type Pipeline struct {
+	reported  map[string]float64
+	converted map[string]float64
+	rules     []rule
+	mu        sync.Mutex
+}
+
+// pipelineChecked gauges of report, which pipeline remembers after report is delivered.
+type pipelineChecked struct {
+	unchanged map[string]float64 // gauges checked by drop_unchanged rules
+	converted map[string]float64 // gauges converted to counters by set_type rules
+}
+
+type rule struct {
+	match       *regexp.Regexp
+	action      string
+	replacement string
+	typ         string
+}
+
+// NewPipeline constructor for Pipeline. Returns error, if any rule is invalid.
+func NewPipeline(cnfg []config.RuleConfig) (*Pipeline, error) {
+	rules := make([]rule, 0, len(cnfg))
+	for i, ruleConfig := range cnfg {
+		match, err := regexp.Compile(ruleConfig.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match of rule %d: %w", i, err)
+		}
+		switch ruleConfig.Action {
+		case RuleInclude, RuleExclude, RuleRename, RuleReplace, RuleDropUnchanged:
+		case RuleSetType:
+			if ruleConfig.Type != entity.TypeGauge && ruleConfig.Type != entity.TypeCounter {
+				return nil, fmt.Errorf("invalid type %q of rule %d", ruleConfig.Type, i)
+			}
+		default:
+			return nil, fmt.Errorf("unknown action %q of rule %d", ruleConfig.Action, i)
+		}
+		if ruleConfig.Action == RuleRename && ruleConfig.Replacement == "" {
+			return nil, fmt.Errorf("empty replacement of rule %d", i)
+		}
+		rules = append(rules, rule{
+			match:       match,
+			action:      ruleConfig.Action,
+			replacement: ruleConfig.Replacement,
+			typ:         ruleConfig.Type,
+		})
+	}
+
+	return &Pipeline{
+		reported:  make(map[string]float64),
+		converted: make(map[string]float64),
+		rules:     rules,
+	}, nil
+}
+
+// SetPipeline set rules applied to metrics before report.
+func (monitor *Monitor) SetPipeline(pipeline *Pipeline) {
+	monitor.pipeline = pipeline
+}
+
+// apply return values transformed by rules. Given values are not changed.
+// Gauges checked by drop_unchanged rules and converted to counters are returned separately by series ID,
+// they should be passed to acknowledge after report is delivered.
+func (p *Pipeline) apply(values []MonitorValue) ([]MonitorValue, pipelineChecked) {
+	if p == nil || len(p.rules) == 0 {
+		return values, pipelineChecked{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := make([]MonitorValue, 0, len(values))
+	checked := pipelineChecked{
+		unchanged: make(map[string]float64),
+		converted: make(map[string]float64),
+	}
+	for _, value := range values {
+		if value, ok := p.applyRules(value, checked); ok {
+			result = append(result, value)
+		}
+	}
+	return result, checked
+}
+
+// acknowledge remember delivered values of gauges checked by drop_unchanged rules and converted to counters.
+func (p *Pipeline) acknowledge(checked pipelineChecked) {
+	if p == nil || len(checked.unchanged)+len(checked.converted) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, value := range checked.unchanged {
+		p.reported[id] = value
+	}
+	for id, value := range checked.converted {
+		p.converted[id] = value
+	}
+}
+
+func (p *Pipeline) applyRules(value MonitorValue, checked pipelineChecked) (MonitorValue, bool) {
+	for _, r := range p.rules {
+		matched := r.match.MatchString(value.Name)
+		switch r.action {
+		case RuleInclude:
+			if !matched {
+				return value, false
+			}
+		case RuleExclude:
+			if matched {
+				return value, false
+			}
+		case RuleRename:
+			if matched {
+				submatches := r.match.FindStringSubmatchIndex(value.Name)
+				value.Name = string(r.match.ExpandString(nil, r.replacement, value.Name, submatches))
+			}
+		case RuleReplace:
+			value.Name = r.match.ReplaceAllString(value.Name, r.replacement)
+		case RuleSetType:
+			if matched {
+				value = p.convertType(value, r.typ, checked)
+			}
+		case RuleDropUnchanged:
+			if matched && value.Type == entity.TypeGauge {
+				if p.isUnchanged(value) {
+					return value, false
+				}
+				checked.unchanged[value.ID()] = value.Value
+			}
+		}
+	}
+	return value, true
+}
+
+// isUnchanged check if gauge has the same value as in last delivered report.
+func (p *Pipeline) isUnchanged(value MonitorValue) bool {
+	previous, ok := p.reported[value.ID()]
+	return ok && previous == value.Value
+}
+
+// convertType change type of value. Gauge converted to counter gets delta since last delivered value.
+func (p *Pipeline) convertType(value MonitorValue, typ string, checked pipelineChecked) MonitorValue {
+	if value.Type == typ {
+		return value
+	}
+	if typ == entity.TypeCounter {
+		id := value.ID()
+		checked.converted[id] = value.Value
+		value.Delta = counterDelta(p.converted, id, value.Value)
+		value.Value = 0
+	} else {
+		value.Value = float64(value.Delta)
+		value.Delta = 0
+	}
+	value.Type = typ
+	return value
+}
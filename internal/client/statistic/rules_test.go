@@ -0,0 +1,196 @@
+package statistic
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ilya372317/must-have-metrics/internal/client/sender"
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPipeline(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []config.RuleConfig
+	}{
+		{name: "invalid regexp case", rules: []config.RuleConfig{{Action: RuleExclude, Match: "("}}},
+		{name: "unknown action case", rules: []config.RuleConfig{{Action: "keep", Match: ".*"}}},
+		{name: "invalid type case", rules: []config.RuleConfig{{Action: RuleSetType, Match: ".*", Type: "histogram"}}},
+		{name: "empty replacement case", rules: []config.RuleConfig{{Action: RuleRename, Match: ".*"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPipeline(tt.rules)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestPipeline_apply(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  []config.RuleConfig
+		values []MonitorValue
+		want   []MonitorValue
+	}{
+		{
+			name:   "include case",
+			rules:  []config.RuleConfig{{Action: RuleInclude, Match: "^Heap"}},
+			values: []MonitorValue{gaugeValue("HeapAlloc", 1), gaugeValue("Alloc", 2)},
+			want:   []MonitorValue{gaugeValue("HeapAlloc", 1)},
+		},
+		{
+			name:   "exclude case",
+			rules:  []config.RuleConfig{{Action: RuleExclude, Match: "^(MSpan|MCache)"}},
+			values: []MonitorValue{gaugeValue("MSpanSys", 1), gaugeValue("MCacheSys", 2), gaugeValue("Alloc", 3)},
+			want:   []MonitorValue{gaugeValue("Alloc", 3)},
+		},
+		{
+			name:   "rename case",
+			rules:  []config.RuleConfig{{Action: RuleRename, Match: "^CPUutilization(\\d+)$", Replacement: "cpu_${1}_percent"}},
+			values: []MonitorValue{gaugeValue("CPUutilization1", 5), gaugeValue("Alloc", 1)},
+			want:   []MonitorValue{gaugeValue("cpu_1_percent", 5), gaugeValue("Alloc", 1)},
+		},
+		{
+			name:   "replace case",
+			rules:  []config.RuleConfig{{Action: RuleReplace, Match: "Disk", Replacement: "disk_"}},
+			values: []MonitorValue{gaugeValue("DiskFree_root", 5)},
+			want:   []MonitorValue{gaugeValue("disk_Free_root", 5)},
+		},
+		{
+			name:   "replace with empty string case",
+			rules:  []config.RuleConfig{{Action: RuleReplace, Match: "_root$"}},
+			values: []MonitorValue{gaugeValue("DiskFree_root", 5)},
+			want:   []MonitorValue{gaugeValue("DiskFree", 5)},
+		},
+		{
+			name: "set type case",
+			rules: []config.RuleConfig{
+				{Action: RuleSetType, Match: "^NumGC$", Type: entity.TypeCounter},
+				{Action: RuleSetType, Match: "^PollCount$", Type: entity.TypeGauge},
+			},
+			values: []MonitorValue{gaugeValue("NumGC", 7), {Name: "PollCount", Type: entity.TypeCounter, Delta: 3}},
+			want: []MonitorValue{
+				{Name: "NumGC", Type: entity.TypeCounter},
+				gaugeValue("PollCount", 3),
+			},
+		},
+		{
+			name: "rules order case",
+			rules: []config.RuleConfig{
+				{Action: RuleRename, Match: "^Alloc$", Replacement: "HeapAllocated"},
+				{Action: RuleInclude, Match: "^Heap"},
+			},
+			values: []MonitorValue{gaugeValue("Alloc", 1), gaugeValue("Sys", 1)},
+			want:   []MonitorValue{gaugeValue("HeapAllocated", 1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline, err := NewPipeline(tt.rules)
+			require.NoError(t, err)
+			got, _ := pipeline.apply(tt.values)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPipeline_applyDropUnchanged(t *testing.T) {
+	pipeline, err := NewPipeline([]config.RuleConfig{{Action: RuleDropUnchanged, Match: "Memory$"}})
+	require.NoError(t, err)
+	pollCount := MonitorValue{Name: "PollCount", Type: entity.TypeCounter, Delta: 1}
+
+	got, checked := pipeline.apply([]MonitorValue{gaugeValue("TotalMemory", 100), gaugeValue("Alloc", 1), pollCount})
+	assert.Equal(t, []MonitorValue{gaugeValue("TotalMemory", 100), gaugeValue("Alloc", 1), pollCount}, got)
+	assert.Equal(t, map[string]float64{"TotalMemory": 100}, checked.unchanged)
+
+	got, _ = pipeline.apply([]MonitorValue{gaugeValue("TotalMemory", 100), pollCount})
+	assert.Equal(t, []MonitorValue{gaugeValue("TotalMemory", 100), pollCount}, got,
+		"value is not dropped until report is delivered")
+
+	pipeline.acknowledge(checked)
+	got, _ = pipeline.apply([]MonitorValue{gaugeValue("TotalMemory", 100), gaugeValue("Alloc", 1), pollCount})
+	assert.Equal(t, []MonitorValue{gaugeValue("Alloc", 1), pollCount}, got)
+
+	got, _ = pipeline.apply([]MonitorValue{gaugeValue("TotalMemory", 200)})
+	assert.Equal(t, []MonitorValue{gaugeValue("TotalMemory", 200)}, got)
+}
+
+func TestPipeline_applySetTypeCounter(t *testing.T) {
+	pipeline, err := NewPipeline([]config.RuleConfig{{Action: RuleSetType, Match: "^NumGC$", Type: entity.TypeCounter}})
+	require.NoError(t, err)
+	numGC := func(delta int64) []MonitorValue {
+		return []MonitorValue{{Name: "NumGC", Type: entity.TypeCounter, Delta: delta}}
+	}
+
+	got, checked := pipeline.apply([]MonitorValue{gaugeValue("NumGC", 7)})
+	assert.Equal(t, numGC(0), got, "first value is baseline")
+	pipeline.acknowledge(checked)
+
+	got, _ = pipeline.apply([]MonitorValue{gaugeValue("NumGC", 10)})
+	assert.Equal(t, numGC(3), got)
+
+	got, checked = pipeline.apply([]MonitorValue{gaugeValue("NumGC", 12)})
+	assert.Equal(t, numGC(5), got, "increase of not delivered report is reported again")
+	pipeline.acknowledge(checked)
+
+	got, _ = pipeline.apply([]MonitorValue{gaugeValue("NumGC", 12)})
+	assert.Equal(t, numGC(0), got)
+
+	got, _ = pipeline.apply([]MonitorValue{gaugeValue("NumGC", 2)})
+	assert.Equal(t, numGC(2), got, "decreased value means counter reset")
+}
+
+func TestMonitor_reportStatDropUnchanged(t *testing.T) {
+	require.NoError(t, logger.Init())
+	pipeline, err := NewPipeline([]config.RuleConfig{{Action: RuleDropUnchanged, Match: "^TotalMemory$"}})
+	require.NoError(t, err)
+	monitor := New(1)
+	monitor.SetPipeline(pipeline)
+	monitor.Data["TotalMemory"] = gaugeValue("TotalMemory", 100)
+
+	serverDown := true
+	sentBodies := make([]string, 0)
+	monitor.SetSender(sender.ReportSenderFunc(func(_ context.Context, body string) error {
+		if serverDown {
+			return errors.New("server is down")
+		}
+		sentBodies = append(sentBodies, body)
+		return nil
+	}))
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+	serverDown = false
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+
+	assert.Equal(t, []string{`[{"value":100,"id":"TotalMemory","type":"gauge"}]`}, sentBodies,
+		"failed report does not mark gauge as reported")
+}
+
+func TestMonitor_reportStatPipeline(t *testing.T) {
+	pipeline, err := NewPipeline([]config.RuleConfig{
+		{Action: RuleExclude, Match: "^PollCount$"},
+		{Action: RuleRename, Match: "^Events$", Replacement: "events_total"},
+	})
+	require.NoError(t, err)
+	monitor := New(1)
+	monitor.SetPipeline(pipeline)
+	monitor.Data["PollCount"] = MonitorValue{Name: "PollCount", Type: entity.TypeCounter, Delta: 2}
+	monitor.Data["Events"] = MonitorValue{Name: "Events", Type: entity.TypeCounter, Delta: 5}
+
+	sentBodies := make([]string, 0)
+	monitor.SetSender(sender.ReportSenderFunc(func(_ context.Context, body string) error {
+		sentBodies = append(sentBodies, body)
+		return nil
+	}))
+	monitor.reportStat(context.Background(), monitor.prepareReport())
+
+	assert.Equal(t, []string{`[{"delta":5,"id":"events_total","type":"counter"}]`}, sentBodies)
+	assert.Equal(t, int64(0), monitor.Data["Events"].Delta, "renamed counter is accounted by collected name")
+	assert.Equal(t, int64(0), monitor.Data["PollCount"].Delta, "excluded counter is dropped")
+}
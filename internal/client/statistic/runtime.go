@@ -0,0 +1,183 @@
+package statistic
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+const (
+	runtimeMetricPrefix = "go_"
+	labelQuantile       = "quantile"
+	labelLe             = "le"
+)
+
+var defaultRuntimeQuantiles = []float64{0.5, 0.9, 0.99}
+
+// RuntimeCollector collects every metric supported by runtime/metrics package.
+// Unlike runtime.ReadMemStats reading of these metrics does not stop the world.
+//
+// Names are mapped as "/gc/heap/allocs:bytes" to "go_gc_heap_allocs_bytes", cumulative scalar metrics
+// get "_total" suffix. Cumulative integer metrics are reported as counters, other scalar metrics as gauges.
+// Histograms are reported as quantile gauges with "quantile" label or as counters of cumulative buckets
+// with "le" label. In both modes count of observations is reported as counter with "_count" suffix.
+type RuntimeCollector struct {
+	previous    map[string]uint64
+	samples     []metrics.Sample
+	cumulative  []bool
+	quantiles   []float64
+	withBuckets bool
+}
+
+// SetMemStats enable or disable reading of runtime metrics by runtime.ReadMemStats.
+// It is disabled, when runtime metrics are collected by RuntimeCollector.
+func (monitor *Monitor) SetMemStats(enabled bool) {
+	monitor.skipMemStats = !enabled
+}
+
+// NewRuntimeCollector constructor for RuntimeCollector.
+func NewRuntimeCollector(cnfg config.RuntimeMetricsConfig) (*RuntimeCollector, error) {
+	c := &RuntimeCollector{
+		previous:  make(map[string]uint64),
+		quantiles: cnfg.Quantiles,
+	}
+	switch cnfg.Histograms {
+	case "", config.HistogramModeQuantiles:
+	case config.HistogramModeBuckets:
+		c.withBuckets = true
+	default:
+		return nil, fmt.Errorf("unknown histograms mode %q", cnfg.Histograms)
+	}
+	if len(c.quantiles) == 0 {
+		c.quantiles = defaultRuntimeQuantiles
+	}
+	for _, quantile := range c.quantiles {
+		if quantile < 0 || quantile > 1 {
+			return nil, fmt.Errorf("quantile %v is out of [0, 1] range", quantile)
+		}
+	}
+
+	for _, description := range metrics.All() {
+		if description.Kind == metrics.KindBad {
+			continue
+		}
+		c.samples = append(c.samples, metrics.Sample{Name: description.Name})
+		c.cumulative = append(c.cumulative, description.Cumulative)
+	}
+	return c, nil
+}
+
+// Collect read runtime metrics.
+func (c *RuntimeCollector) Collect(_ context.Context) ([]MonitorValue, error) {
+	metrics.Read(c.samples)
+	values := make([]MonitorValue, 0, len(c.samples))
+	for i, sample := range c.samples {
+		name := runtimeMetricName(sample.Name, c.cumulative[i])
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			if c.cumulative[i] {
+				values = append(values, c.counterValue(name, nil, sample.Value.Uint64()))
+			} else {
+				values = append(values, gaugeValue(name, float64(sample.Value.Uint64())))
+			}
+		case metrics.KindFloat64:
+			values = append(values, gaugeValue(name, sample.Value.Float64()))
+		case metrics.KindFloat64Histogram:
+			name = runtimeMetricName(sample.Name, false)
+			values = append(values, c.histogramValues(name, sample.Value.Float64Histogram())...)
+		default:
+			// Metric is not supported by current runtime.
+		}
+	}
+	return values, nil
+}
+
+// histogramValues convert runtime histogram to quantiles or buckets.
+func (c *RuntimeCollector) histogramValues(name string, histogram *metrics.Float64Histogram) []MonitorValue {
+	var total uint64
+	for _, count := range histogram.Counts {
+		total += count
+	}
+	values := []MonitorValue{c.counterValue(name+"_count", nil, total)}
+	if c.withBuckets {
+		var cumulative uint64
+		for i, count := range histogram.Counts {
+			cumulative += count
+			labels := map[string]string{labelLe: formatBound(histogram.Buckets[i+1])}
+			values = append(values, c.counterValue(name+"_bucket", labels, cumulative))
+		}
+		return values
+	}
+	if total == 0 {
+		return values
+	}
+	for _, quantile := range c.quantiles {
+		gauge := gaugeValue(name, histogramQuantile(histogram, total, quantile))
+		gauge.Labels = map[string]string{labelQuantile: strconv.FormatFloat(quantile, 'g', -1, 64)}
+		values = append(values, gauge)
+	}
+	return values
+}
+
+// counterValue convert cumulative runtime counter to delta since previous collect.
+// First observation is used as baseline and reported with zero delta.
+func (c *RuntimeCollector) counterValue(name string, labels map[string]string, current uint64) MonitorValue {
+	value := MonitorValue{Name: name, Labels: labels, Type: entity.TypeCounter}
+	id := value.ID()
+	previous, ok := c.previous[id]
+	c.previous[id] = current
+	if ok && current >= previous {
+		value.Delta = int64(current - previous)
+	}
+	return value
+}
+
+// runtimeMetricName map runtime/metrics name to metric name.
+func runtimeMetricName(name string, cumulative bool) string {
+	name = runtimeMetricPrefix + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.TrimPrefix(name, "/"))
+	if cumulative && !strings.HasSuffix(name, "_total") {
+		name += "_total"
+	}
+	return name
+}
+
+// histogramQuantile estimate quantile of histogram by linear interpolation inside bucket.
+// Infinite bucket bounds are replaced by opposite finite bound.
+func histogramQuantile(histogram *metrics.Float64Histogram, total uint64, quantile float64) float64 {
+	rank := quantile * float64(total)
+	var cumulative uint64
+	for i, count := range histogram.Counts {
+		if count == 0 || float64(cumulative+count) < rank {
+			cumulative += count
+			continue
+		}
+		lower, upper := histogram.Buckets[i], histogram.Buckets[i+1]
+		switch {
+		case math.IsInf(lower, -1):
+			return upper
+		case math.IsInf(upper, 1):
+			return lower
+		default:
+			return lower + (upper-lower)*(rank-float64(cumulative))/float64(count)
+		}
+	}
+	return histogram.Buckets[len(histogram.Buckets)-1]
+}
+
+func formatBound(bound float64) string {
+	if math.IsInf(bound, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(bound, 'g', -1, 64)
+}
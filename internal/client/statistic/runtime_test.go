@@ -0,0 +1,187 @@
+package statistic
+
+import (
+	"context"
+	"math"
+	"runtime/metrics"
+	"testing"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_runtimeMetricName(t *testing.T) {
+	tests := []struct {
+		name       string
+		metric     string
+		want       string
+		cumulative bool
+	}{
+		{name: "gauge case", metric: "/memory/classes/heap/objects:bytes", want: "go_memory_classes_heap_objects_bytes"},
+		{name: "counter case", metric: "/gc/heap/allocs:bytes", cumulative: true, want: "go_gc_heap_allocs_bytes_total"},
+		{
+			name:       "dashed unit case",
+			metric:     "/cpu/classes/gc/total:cpu-seconds",
+			cumulative: true,
+			want:       "go_cpu_classes_gc_total_cpu_seconds_total",
+		},
+		{
+			name:       "already total case",
+			metric:     "/sync/mutex/wait/total:seconds",
+			cumulative: true,
+			want:       "go_sync_mutex_wait_total_seconds_total",
+		},
+		{name: "wildcard unit case", metric: "/gc/gogc:percent", want: "go_gc_gogc_percent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, runtimeMetricName(tt.metric, tt.cumulative))
+		})
+	}
+}
+
+func Test_histogramQuantile(t *testing.T) {
+	histogram := &metrics.Float64Histogram{
+		Counts:  []uint64{0, 2, 6, 2},
+		Buckets: []float64{math.Inf(-1), 1, 2, 4, math.Inf(1)},
+	}
+	tests := []struct {
+		name     string
+		quantile float64
+		want     float64
+	}{
+		{name: "lowest case", quantile: 0, want: 1},
+		{name: "inside first bucket case", quantile: 0.1, want: 1.5},
+		{name: "median case", quantile: 0.5, want: 3},
+		{name: "infinite upper bound case", quantile: 0.99, want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, histogramQuantile(histogram, 10, tt.quantile), 1e-9)
+		})
+	}
+}
+
+func TestRuntimeCollector_histogramValues(t *testing.T) {
+	histogram := &metrics.Float64Histogram{
+		Counts:  []uint64{1, 3},
+		Buckets: []float64{0, 1, math.Inf(1)},
+	}
+	tests := []struct {
+		name string
+		cnfg config.RuntimeMetricsConfig
+		want map[string]MonitorValue
+	}{
+		{
+			name: "quantiles case",
+			cnfg: config.RuntimeMetricsConfig{Quantiles: []float64{0.25, 0.9}},
+			want: map[string]MonitorValue{
+				"latency_count": {Name: "latency_count", Type: entity.TypeCounter},
+				`latency{quantile="0.25"}`: {
+					Name: "latency", Type: entity.TypeGauge, Value: 1,
+					Labels: map[string]string{"quantile": "0.25"},
+				},
+				`latency{quantile="0.9"}`: {
+					Name: "latency", Type: entity.TypeGauge, Value: 1,
+					Labels: map[string]string{"quantile": "0.9"},
+				},
+			},
+		},
+		{
+			name: "buckets case",
+			cnfg: config.RuntimeMetricsConfig{Histograms: config.HistogramModeBuckets},
+			want: map[string]MonitorValue{
+				"latency_count": {Name: "latency_count", Type: entity.TypeCounter},
+				`latency_bucket{le="1"}`: {
+					Name: "latency_bucket", Type: entity.TypeCounter,
+					Labels: map[string]string{"le": "1"},
+				},
+				`latency_bucket{le="+Inf"}`: {
+					Name: "latency_bucket", Type: entity.TypeCounter,
+					Labels: map[string]string{"le": "+Inf"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, err := NewRuntimeCollector(tt.cnfg)
+			require.NoError(t, err)
+			values := collector.histogramValues("latency", histogram)
+			got := make(map[string]MonitorValue, len(values))
+			for _, value := range values {
+				got[value.ID()] = value
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRuntimeCollector_Collect(t *testing.T) {
+	collector, err := NewRuntimeCollector(config.RuntimeMetricsConfig{})
+	require.NoError(t, err)
+
+	_, err = collector.Collect(context.Background())
+	require.NoError(t, err)
+	buffer := make([][]byte, 0)
+	for i := 0; i < 100; i++ {
+		buffer = append(buffer, make([]byte, 1024))
+	}
+	values, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	assert.NotEmpty(t, buffer)
+
+	got := make(map[string]MonitorValue, len(values))
+	for _, value := range values {
+		got[value.ID()] = value
+	}
+	allocs, ok := got["go_gc_heap_allocs_bytes_total"]
+	require.True(t, ok)
+	assert.Equal(t, entity.TypeCounter, allocs.Type)
+	assert.Positive(t, allocs.Delta)
+
+	goroutines, ok := got["go_sched_goroutines_goroutines"]
+	require.True(t, ok)
+	assert.Equal(t, entity.TypeGauge, goroutines.Type)
+	assert.Positive(t, goroutines.Value)
+
+	_, ok = got["go_gc_heap_allocs_by_size_bytes_count"]
+	assert.True(t, ok)
+	_, ok = got[`go_gc_heap_allocs_by_size_bytes{quantile="0.5"}`]
+	assert.True(t, ok)
+}
+
+func TestNewRuntimeCollector(t *testing.T) {
+	tests := []struct {
+		name    string
+		cnfg    config.RuntimeMetricsConfig
+		wantErr bool
+	}{
+		{name: "default case"},
+		{name: "unknown histograms mode case", cnfg: config.RuntimeMetricsConfig{Histograms: "summary"}, wantErr: true},
+		{name: "invalid quantile case", cnfg: config.RuntimeMetricsConfig{Quantiles: []float64{1.5}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRuntimeCollector(tt.cnfg)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMonitor_collectStatWithoutMemStats(t *testing.T) {
+	monitor := New(1)
+	monitor.SetMemStats(false)
+	monitor.collectStat()
+
+	_, ok := monitor.Data["Alloc"]
+	assert.False(t, ok)
+	_, ok = monitor.Data[counterName]
+	assert.True(t, ok)
+}
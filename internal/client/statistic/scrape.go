@@ -0,0 +1,189 @@
+package statistic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/promtext"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+const (
+	defaultScrapeTimeout = 5 * time.Second
+	maxScrapeBodySize    = 10 * 1024 * 1024
+	scrapeAcceptHeader   = "text/plain;version=0.0.4"
+)
+
+// ScrapeCollector scrapes Prometheus endpoints and converts exposed metrics to agent metrics.
+//
+// Counters are converted to deltas between scrapes, gauges and untyped metrics are reported as gauges.
+// Counters with non-integer values, for example *_seconds_total, are reported by integer deltas too,
+// fractional remainder is kept in previous value and is reported, when it sums up to whole increment.
+// Histogram buckets and counts are reported as counters and sums as gauges,
+// summary quantiles and sums are reported as gauges and counts as counters.
+// Labels of samples are kept, labels of target are added to them.
+type ScrapeCollector struct {
+	client          *http.Client
+	values          *sourceValues
+	previous        map[int]map[string]float64
+	targets         []config.ScrapeTargetConfig
+	defaultInterval time.Duration
+	mu              sync.Mutex
+}
+
+// NewScrapeCollector constructor for ScrapeCollector.
+// Targets without configured interval are scraped every defaultInterval.
+func NewScrapeCollector(cnfg config.ScrapeMetricsConfig, defaultInterval time.Duration) (*ScrapeCollector, error) {
+	for _, target := range cnfg.Targets {
+		if _, err := url.ParseRequestURI(target.URL); err != nil {
+			return nil, fmt.Errorf("invalid scrape target url %q: %w", target.URL, err)
+		}
+	}
+
+	return &ScrapeCollector{
+		client:          &http.Client{},
+		values:          newSourceValues(),
+		previous:        make(map[int]map[string]float64),
+		targets:         cnfg.Targets,
+		defaultInterval: defaultInterval,
+	}, nil
+}
+
+// Run start scraping of targets by their intervals until context is done.
+func (c *ScrapeCollector) Run(ctx context.Context) {
+	wg := &sync.WaitGroup{}
+	for i := range c.targets {
+		wg.Add(1)
+		go func(targetID int) {
+			defer wg.Done()
+			interval := c.defaultInterval
+			if c.targets[targetID].Interval > 0 {
+				interval = time.Duration(c.targets[targetID].Interval) * time.Second
+			}
+			runEvery(ctx, interval, func() {
+				c.scrapeTarget(ctx, targetID)
+			})
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Collect return metrics gathered from targets.
+func (c *ScrapeCollector) Collect(context.Context) ([]MonitorValue, error) {
+	return c.values.drain(), nil
+}
+
+func (c *ScrapeCollector) scrapeTarget(ctx context.Context, targetID int) {
+	target := c.targets[targetID]
+	families, err := c.scrape(ctx, target)
+	if err != nil {
+		logger.Log.Errorf("failed scrape %s: %v", target.URL, err)
+		return
+	}
+	c.values.store(targetID, c.convert(targetID, target, families))
+}
+
+func (c *ScrapeCollector) scrape(ctx context.Context, target config.ScrapeTargetConfig) ([]promtext.Family, error) {
+	timeout := defaultScrapeTimeout
+	if target.Timeout > 0 {
+		timeout = time.Duration(target.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed create scrape request: %w", err)
+	}
+	request.Header.Set("Accept", scrapeAcceptHeader)
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed make scrape request: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected scrape response status: %d", response.StatusCode)
+	}
+
+	families, err := promtext.Parse(io.LimitReader(response.Body, maxScrapeBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("invalid exposition: %w", err)
+	}
+	return families, nil
+}
+
+func (c *ScrapeCollector) convert(
+	targetID int,
+	target config.ScrapeTargetConfig,
+	families []promtext.Family,
+) []MonitorValue {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous := c.previous[targetID]
+	current := make(map[string]float64)
+
+	values := make([]MonitorValue, 0)
+	for _, family := range families {
+		for _, sample := range family.Samples {
+			if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+				continue
+			}
+			value := MonitorValue{
+				Name:   target.Prefix + sample.Name,
+				Type:   entity.TypeGauge,
+				Value:  sample.Value,
+				Labels: mergeLabels(target.Labels, sample.Labels),
+			}
+			if isCounterSample(family.Type, sample.Name) {
+				id := value.ID()
+				current[id] = sample.Value
+				value.Type = entity.TypeCounter
+				value.Value = 0
+				value.Delta = counterDelta(previous, id, sample.Value)
+			}
+			values = append(values, value)
+		}
+	}
+	c.previous[targetID] = current
+
+	return values
+}
+
+func isCounterSample(familyType, sampleName string) bool {
+	switch familyType {
+	case promtext.TypeCounter:
+		return true
+	case promtext.TypeHistogram:
+		return !strings.HasSuffix(sampleName, promtext.SuffixSum)
+	case promtext.TypeSummary:
+		return strings.HasSuffix(sampleName, promtext.SuffixCount)
+	default:
+		return false
+	}
+}
+
+// counterDelta calculate increment of cumulative counter since previous observation.
+// First observation is used as baseline, decreased value means counter was reset.
+// Delta is difference of whole parts, so fractional increments are not lost between scrapes.
+func counterDelta(previous map[string]float64, id string, current float64) int64 {
+	previousValue, ok := previous[id]
+	switch {
+	case !ok:
+		return 0
+	case current < previousValue:
+		return int64(current)
+	default:
+		return int64(math.Floor(current) - math.Floor(previousValue))
+	}
+}
@@ -0,0 +1,144 @@
+package statistic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/promtext"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const firstExposition = `# TYPE http_requests_total counter
+http_requests_total{code="200"} 10
+# TYPE queue_depth gauge
+queue_depth 4
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="+Inf"} 2
+latency_seconds_sum 0.25
+latency_seconds_count 2
+`
+
+const secondExposition = `# TYPE http_requests_total counter
+http_requests_total{code="200"} 15
+# TYPE queue_depth gauge
+queue_depth 7
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 4
+latency_seconds_bucket{le="+Inf"} 6
+latency_seconds_sum 1.5
+latency_seconds_count 6
+`
+
+func TestScrapeCollector(t *testing.T) {
+	require.NoError(t, logger.Init())
+	expositions := []string{firstExposition, secondExposition}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = writer.Write([]byte(expositions[0]))
+		expositions = expositions[1:]
+	}))
+	defer server.Close()
+
+	collector, err := NewScrapeCollector(config.ScrapeMetricsConfig{
+		Targets: []config.ScrapeTargetConfig{{URL: server.URL, Prefix: "app_"}},
+	}, time.Second)
+	require.NoError(t, err)
+
+	collector.scrapeTarget(context.Background(), 0)
+	values, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	got := make(map[string]MonitorValue, len(values))
+	for _, value := range values {
+		got[value.ID()] = value
+	}
+	assert.Equal(t, MonitorValue{Name: "app_queue_depth", Type: entity.TypeGauge, Value: 4}, got["app_queue_depth"])
+	assert.Equal(t, int64(0), got[`app_http_requests_total{code="200"}`].Delta)
+
+	collector.scrapeTarget(context.Background(), 0)
+	values, err = collector.Collect(context.Background())
+	require.NoError(t, err)
+	got = make(map[string]MonitorValue, len(values))
+	for _, value := range values {
+		got[value.ID()] = value
+	}
+	assert.Equal(t, MonitorValue{Name: "app_queue_depth", Type: entity.TypeGauge, Value: 7}, got["app_queue_depth"])
+	assert.Equal(t,
+		MonitorValue{Name: "app_latency_seconds_sum", Type: entity.TypeGauge, Value: 1.5}, got["app_latency_seconds_sum"])
+	assert.Equal(t, int64(5), got[`app_http_requests_total{code="200"}`].Delta)
+	assert.Equal(t, entity.TypeCounter, got[`app_latency_seconds_bucket{le="0.1"}`].Type)
+	assert.Equal(t, int64(3), got[`app_latency_seconds_bucket{le="0.1"}`].Delta)
+	assert.Equal(t, int64(4), got["app_latency_seconds_count"].Delta)
+}
+
+func TestScrapeCollector_scrapeFailed(t *testing.T) {
+	require.NoError(t, logger.Init())
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	collector, err := NewScrapeCollector(config.ScrapeMetricsConfig{
+		Targets: []config.ScrapeTargetConfig{{URL: server.URL}},
+	}, time.Second)
+	require.NoError(t, err)
+	_, err = collector.scrape(context.Background(), collector.targets[0])
+	require.Error(t, err)
+}
+
+func TestCounterDelta(t *testing.T) {
+	previous := map[string]float64{"requests": 10.7}
+	tests := []struct {
+		name    string
+		id      string
+		current float64
+		want    int64
+	}{
+		{name: "first observation", id: "new", current: 100, want: 0},
+		{name: "increment", id: "requests", current: 12.2, want: 2},
+		{name: "counter reset", id: "requests", current: 3, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, counterDelta(previous, tt.id, tt.current))
+		})
+	}
+}
+
+func TestNewScrapeCollector_invalidURL(t *testing.T) {
+	_, err := NewScrapeCollector(config.ScrapeMetricsConfig{
+		Targets: []config.ScrapeTargetConfig{{URL: "not a url"}},
+	}, time.Second)
+	require.Error(t, err)
+}
+
+func TestScrapeCollector_convertFractionalCounter(t *testing.T) {
+	collector, err := NewScrapeCollector(config.ScrapeMetricsConfig{}, time.Second)
+	require.NoError(t, err)
+	target := config.ScrapeTargetConfig{}
+	scrapes := []struct {
+		value float64
+		want  MonitorValue
+	}{
+		{value: 1, want: MonitorValue{Name: "cpu_seconds_total", Type: entity.TypeCounter}},
+		{value: 1.25, want: MonitorValue{Name: "cpu_seconds_total", Type: entity.TypeCounter}},
+		{value: 1.75, want: MonitorValue{Name: "cpu_seconds_total", Type: entity.TypeCounter}},
+		{value: 2.5, want: MonitorValue{Name: "cpu_seconds_total", Type: entity.TypeCounter, Delta: 1}},
+		{value: 4, want: MonitorValue{Name: "cpu_seconds_total", Type: entity.TypeCounter, Delta: 2}},
+	}
+	for _, scrape := range scrapes {
+		values := collector.convert(0, target, []promtext.Family{{
+			Name:    "cpu_seconds_total",
+			Type:    promtext.TypeCounter,
+			Samples: []promtext.Sample{{Name: "cpu_seconds_total", Value: scrape.value}},
+		}})
+		require.Len(t, values, 1)
+		assert.Equal(t, scrape.want, values[0], "counter keeps type and fractional remainder")
+	}
+}
@@ -0,0 +1,167 @@
+package statistic
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/client/sender"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+// Names of agent self-metrics.
+const (
+	SelfSendsAttempted  = "agent_sends_attempted_total"
+	SelfSendsSucceeded  = "agent_sends_succeeded_total"
+	SelfSendsFailed     = "agent_sends_failed_total"
+	SelfBytesSent       = "agent_bytes_sent_total"
+	SelfWorkerPanics    = "agent_worker_panics_total"
+	SelfQueueDepth      = "agent_queue_depth"
+	SelfSpoolSegments   = "agent_spool_segments"
+	SelfCollectDuration = "agent_collect_duration_seconds"
+)
+
+// selfStats counters of agent own work. Counters are updated without Monitor lock.
+type selfStats struct {
+	startedAt time.Time
+	// collected totals of counters already added to Monitor data.
+	collected       map[string]int64
+	sendsAttempted  atomic.Int64
+	sendsSucceeded  atomic.Int64
+	sendsFailed     atomic.Int64
+	bytesSent       atomic.Int64
+	workerPanics    atomic.Int64
+	lastSendAt      atomic.Int64
+	collectDuration atomic.Int64
+}
+
+// Status snapshot of agent self-metrics.
+type Status struct {
+	StartedAt              time.Time           `json:"started_at"`
+	LastSendAt             time.Time           `json:"last_send_at"`
+	Destinations           []DestinationStatus `json:"destinations"`
+	SendsAttempted         int64               `json:"sends_attempted"`
+	SendsSucceeded         int64               `json:"sends_succeeded"`
+	SendsFailed            int64               `json:"sends_failed"`
+	BytesSent              int64               `json:"bytes_sent"`
+	WorkerPanics           int64               `json:"worker_panics"`
+	CollectDurationSeconds float64             `json:"collect_duration_seconds"`
+	QueueDepth             int                 `json:"queue_depth"`
+	SpoolSegments          int                 `json:"spool_segments"`
+	// CircuitState state of circuit breaker of first destination, which circuit is not open.
+	CircuitState int `json:"circuit_state"`
+}
+
+// DestinationStatus state of destination.
+type DestinationStatus struct {
+	Name          string `json:"name"`
+	QueueDepth    int    `json:"queue_depth"`
+	SpoolSegments int    `json:"spool_segments"`
+	CircuitState  int    `json:"circuit_state"`
+}
+
+// Status return current agent self-metrics.
+func (monitor *Monitor) Status() Status {
+	status := Status{
+		StartedAt:              monitor.stats.startedAt,
+		SendsAttempted:         monitor.stats.sendsAttempted.Load(),
+		SendsSucceeded:         monitor.stats.sendsSucceeded.Load(),
+		SendsFailed:            monitor.stats.sendsFailed.Load(),
+		BytesSent:              monitor.stats.bytesSent.Load(),
+		WorkerPanics:           monitor.stats.workerPanics.Load(),
+		CollectDurationSeconds: time.Duration(monitor.stats.collectDuration.Load()).Seconds(),
+		QueueDepth:             len(monitor.ReportTaskCh),
+	}
+	if lastSendAt := monitor.stats.lastSendAt.Load(); lastSendAt != 0 {
+		status.LastSendAt = time.Unix(0, lastSendAt)
+	}
+	status.Destinations = make([]DestinationStatus, 0, len(monitor.destinations))
+	available := false
+	for _, destination := range monitor.destinations {
+		destinationStatus := DestinationStatus{Name: destination.Name, QueueDepth: destination.queueDepth()}
+		status.QueueDepth += destinationStatus.QueueDepth
+		if destination.Spool != nil {
+			destinationStatus.SpoolSegments = destination.Spool.Len()
+			status.SpoolSegments += destinationStatus.SpoolSegments
+		}
+		if breaker, ok := destination.Sender.(circuitStater); ok {
+			destinationStatus.CircuitState = breaker.CircuitState()
+		}
+		if !available {
+			status.CircuitState = destinationStatus.CircuitState
+			available = destinationStatus.CircuitState != sender.CircuitOpen
+		}
+		status.Destinations = append(status.Destinations, destinationStatus)
+	}
+	return status
+}
+
+// send report body to destination and account result in self-metrics.
+func (monitor *Monitor) send(ctx context.Context, destination *Destination, body string) error {
+	monitor.stats.sendsAttempted.Add(1)
+	if err := destination.Sender.Send(ctx, body); err != nil {
+		monitor.stats.sendsFailed.Add(1)
+		return err
+	}
+	monitor.stats.sendsSucceeded.Add(1)
+	monitor.stats.bytesSent.Add(int64(len(body)))
+	monitor.stats.lastSendAt.Store(time.Now().UnixNano())
+	return nil
+}
+
+// collectSelfStats add agent self-metrics to collected data. Should be called under Monitor lock.
+func (monitor *Monitor) collectSelfStats() {
+	status := monitor.Status()
+	if monitor.stats.collected == nil {
+		monitor.stats.collected = make(map[string]int64)
+	}
+	for name, total := range map[string]int64{
+		SelfSendsAttempted: status.SendsAttempted,
+		SelfSendsSucceeded: status.SendsSucceeded,
+		SelfSendsFailed:    status.SendsFailed,
+		SelfBytesSent:      status.BytesSent,
+		SelfWorkerPanics:   status.WorkerPanics,
+	} {
+		counter := monitor.Data[name]
+		counter.Name = name
+		counter.Type = entity.TypeCounter
+		counter.Delta += total - monitor.stats.collected[name]
+		monitor.Data[name] = counter
+		monitor.stats.collected[name] = total
+	}
+	monitor.setGaugeValue(SelfQueueDepth, float64(status.QueueDepth))
+	monitor.setGaugeValue(SelfCollectDuration, status.CollectDurationSeconds)
+	for i, destination := range monitor.destinations {
+		var labels map[string]string
+		if destination.Name != "" {
+			labels = map[string]string{LabelDestination: destination.Name}
+		}
+		if destination.Spool != nil {
+			monitor.setLabeledGaugeValue(SelfSpoolSegments, labels, float64(status.Destinations[i].SpoolSegments))
+		}
+		if _, ok := destination.Sender.(circuitStater); ok {
+			monitor.setLabeledGaugeValue(circuitStateName, labels, float64(status.Destinations[i].CircuitState))
+		}
+	}
+}
+
+func (monitor *Monitor) setLabeledGaugeValue(name string, labels map[string]string, value float64) {
+	gauge := MonitorValue{Name: name, Labels: labels, Type: entity.TypeGauge, Value: value}
+	monitor.Data[gauge.ID()] = gauge
+	if monitor.aggregator != nil {
+		monitor.aggregator.observe(gauge)
+	}
+}
+
+// inheritStats continue self-metrics totals of previous monitor.
+func (monitor *Monitor) inheritStats(previous *Monitor) {
+	monitor.stats.startedAt = previous.stats.startedAt
+	monitor.stats.sendsAttempted.Store(previous.stats.sendsAttempted.Load())
+	monitor.stats.sendsSucceeded.Store(previous.stats.sendsSucceeded.Load())
+	monitor.stats.sendsFailed.Store(previous.stats.sendsFailed.Load())
+	monitor.stats.bytesSent.Store(previous.stats.bytesSent.Load())
+	monitor.stats.workerPanics.Store(previous.stats.workerPanics.Load())
+	monitor.stats.lastSendAt.Store(previous.stats.lastSendAt.Load())
+	monitor.stats.collectDuration.Store(previous.stats.collectDuration.Load())
+	monitor.stats.collected = previous.stats.collected
+}
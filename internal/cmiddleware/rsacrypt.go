@@ -21,47 +21,74 @@ const (
 // WithRSACrypt Encrypt request body by public key.
 // Public key retrieve from file in publicKeyPath argument
 func WithRSACrypt(publicKeyPath string) resty.RequestMiddleware {
+	return func(client *resty.Client, request *resty.Request) error {
+		publicKey, err := LoadPublicKey(publicKeyPath)
+		if err != nil {
+			return err
+		}
+		return WithRSAPublicKey(publicKey)(client, request)
+	}
+}
+
+// WithRSAPublicKey Encrypt request body by given public key.
+func WithRSAPublicKey(publicKey *rsa.PublicKey) resty.RequestMiddleware {
 	return func(client *resty.Client, request *resty.Request) error {
 		body, ok := request.Body.([]byte)
 		if !ok {
 			return fmt.Errorf("request body expected to be byte slice")
 		}
-		publicKeyData, err := getPublicKeyData(publicKeyPath)
-		if err != nil {
-			return fmt.Errorf("failed get public key data: %w", err)
-		}
 
-		block, _ := pem.Decode(publicKeyData)
-		publickKey, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		cipherBody, err := Encrypt(publicKey, body)
 		if err != nil {
-			return fmt.Errorf("failed parse given public key: %w", err)
+			return err
 		}
+		request.SetBody(cipherBody)
+		return nil
+	}
+}
 
-		cipherBlockSize := (publickKey.Size()) - (sha256.Size * hashLengthTimes) - extraBytesForCipher
-		cipherData := make([]byte, 0)
-		message := body
-
-		for len(message) > 0 {
-			var chunk []byte
-			if len(message) > cipherBlockSize {
-				chunk = message[:cipherBlockSize]
-				message = message[cipherBlockSize:]
-			} else {
-				chunk = message
-				message = nil
-			}
+// Encrypt cipher data by public key in blocks and return it in base64 encoding.
+func Encrypt(publicKey *rsa.PublicKey, data []byte) (string, error) {
+	cipherBlockSize := (publicKey.Size()) - (sha256.Size * hashLengthTimes) - extraBytesForCipher
+	cipherData := make([]byte, 0)
+	message := data
 
-			encryptData, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publickKey, chunk, []byte(""))
-			if err != nil {
-				return fmt.Errorf("failed chipher request body: %w", err)
-			}
+	for len(message) > 0 {
+		var chunk []byte
+		if len(message) > cipherBlockSize {
+			chunk = message[:cipherBlockSize]
+			message = message[cipherBlockSize:]
+		} else {
+			chunk = message
+			message = nil
+		}
 
-			cipherData = append(cipherData, encryptData...)
+		encryptData, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, chunk, []byte(""))
+		if err != nil {
+			return "", fmt.Errorf("failed chipher request body: %w", err)
 		}
 
-		request.SetBody(base64.StdEncoding.EncodeToString(cipherData))
-		return nil
+		cipherData = append(cipherData, encryptData...)
+	}
+
+	return base64.StdEncoding.EncodeToString(cipherData), nil
+}
+
+// LoadPublicKey read and parse public key from file in PEM format.
+func LoadPublicKey(publicKeyPath string) (*rsa.PublicKey, error) {
+	publicKeyData, err := getPublicKeyData(publicKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed get public key data: %w", err)
+	}
+	block, _ := pem.Decode(publicKeyData)
+	if block == nil {
+		return nil, fmt.Errorf("failed decode public key %s", publicKeyPath)
+	}
+	publicKey, err := x509.ParsePKCS1PublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed parse given public key: %w", err)
 	}
+	return publicKey, nil
 }
 
 func getPublicKeyData(publicKeyPath string) ([]byte, error) {
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 
 	"github.com/caarlos0/env"
 )
@@ -17,6 +19,25 @@ const (
 	defaultAgentSecretKeyValue      = ""
 	defaultAgentCryptoKeyValue      = ""
 	defaultAgentConfigValue         = ""
+	defaultAgentSpoolDirValue       = ""
+	defaultAgentHealthAddressValue  = ""
+	defaultAgentDestinationMode     = DestinationModeBroadcast
+	defaultAgentOutputValue         = ""
+	defaultAgentOutputFormatValue   = OutputFormatJSON
+	defaultAgentOutputMaxSizeValue  = 10 * 1024 * 1024
+	defaultAgentOutputMaxFilesValue = 5
+	defaultAgentImportValue         = ""
+	defaultAgentRuntimeSource       = RuntimeSourceMemStats
+	defaultAgentIDValue             = ""
+	defaultAgentRemoteConfigValue   = 0
+	defaultAgentSpoolMaxSizeValue   = 10 * 1024 * 1024
+	defaultAgentDeltaReportValue    = false
+	defaultAgentFullResyncValue     = 300
+	defaultAgentRequestTimeoutValue = 5
+	defaultAgentShutdownTimeout     = 5
+	defaultAgentRetryCountValue     = 3
+	defaultAgentBreakerThreshold    = 5
+	defaultAgentBreakerTimeout      = 30
 
 	nullStringValue = ""
 	nullIntValue    = 0
@@ -31,28 +52,78 @@ const (
 // 4. Add parsing new field in parseFromFileMethod.
 //
 // Note: for default config values use constants.
+// Note: collectors, aggregation, labels, rules and destinations settings are nested structs,
+// they are read only from json config file.
 type AgentConfig struct {
-	Host           string `env:"ADDRESS" json:"address,omitempty"`
-	SecretKey      string `env:"KEY" json:"secret_key,omitempty"`
-	CryptoKey      string `env:"CRYPTO_KEY" json:"crypto_key,omitempty"`
-	ConfigPath     string `env:"CONFIG"`
-	PollInterval   uint   `env:"POLL_INTERVAL" json:"poll_interval,omitempty"`
-	ReportInterval uint   `env:"REPORT_INTERVAL" json:"report_interval,omitempty"`
-	RateLimit      uint   `env:"RATE_LIMIT" json:"rate_limit,omitempty"`
+	Host             string               `env:"ADDRESS" json:"address,omitempty"`
+	SecretKey        string               `env:"KEY" json:"secret_key,omitempty"`
+	CryptoKey        string               `env:"CRYPTO_KEY" json:"crypto_key,omitempty"`
+	ConfigPath       string               `env:"CONFIG"`
+	SpoolDir         string               `env:"SPOOL_DIR" json:"spool_dir,omitempty"`
+	HealthAddress    string               `env:"HEALTH_ADDRESS" json:"health_address,omitempty"`
+	DestinationMode  string               `env:"DESTINATION_MODE" json:"destination_mode,omitempty"`
+	Output           string               `env:"OUTPUT" json:"output,omitempty"`
+	OutputFormat     string               `env:"OUTPUT_FORMAT" json:"output_format,omitempty"`
+	ImportPath       string               `env:"IMPORT"`
+	RuntimeSource    string               `env:"RUNTIME_SOURCE" json:"runtime_source,omitempty"`
+	AgentID          string               `env:"AGENT_ID" json:"agent_id,omitempty"`
+	RuntimeMetrics   RuntimeMetricsConfig `json:"runtime_metrics,omitempty"`
+	HostMetrics      HostMetricsConfig    `json:"host_metrics,omitempty"`
+	CgroupMetrics    CgroupMetricsConfig  `json:"cgroup_metrics,omitempty"`
+	ProcessMetrics   ProcessMetricsConfig `json:"process_metrics,omitempty"`
+	ScrapeMetrics    ScrapeMetricsConfig  `json:"scrape_metrics,omitempty"`
+	PushMetrics      PushMetricsConfig    `json:"push_metrics,omitempty"`
+	LogMetrics       LogMetricsConfig     `json:"log_metrics,omitempty"`
+	Aggregation      AggregationConfig    `json:"aggregation,omitempty"`
+	Labels           LabelsConfig         `json:"labels,omitempty"`
+	Rules            []RuleConfig         `json:"rules,omitempty"`
+	Destinations     []DestinationConfig  `json:"destinations,omitempty"`
+	ExecMetrics      ExecMetricsConfig    `json:"exec_metrics,omitempty"`
+	PollInterval     uint                 `env:"POLL_INTERVAL" json:"poll_interval,omitempty"`
+	ReportInterval   uint                 `env:"REPORT_INTERVAL" json:"report_interval,omitempty"`
+	RateLimit        uint                 `env:"RATE_LIMIT" json:"rate_limit,omitempty"`
+	SpoolMaxSize     uint                 `env:"SPOOL_MAX_SIZE" json:"spool_max_size,omitempty"`
+	FullResync       uint                 `env:"FULL_RESYNC_INTERVAL" json:"full_resync_interval,omitempty"`
+	RequestTimeout   uint                 `env:"REQUEST_TIMEOUT" json:"request_timeout,omitempty"`
+	ShutdownTimeout  uint                 `env:"SHUTDOWN_TIMEOUT" json:"shutdown_timeout,omitempty"`
+	RetryCount       uint                 `env:"RETRY_COUNT" json:"retry_count,omitempty"`
+	BreakerThreshold uint                 `env:"BREAKER_THRESHOLD" json:"breaker_threshold,omitempty"`
+	BreakerTimeout   uint                 `env:"BREAKER_TIMEOUT" json:"breaker_timeout,omitempty"`
+	OutputMaxSize    uint                 `env:"OUTPUT_MAX_SIZE" json:"output_max_size,omitempty"`
+	OutputMaxFiles   uint                 `env:"OUTPUT_MAX_FILES" json:"output_max_files,omitempty"`
+	RemoteConfig     uint                 `env:"REMOTE_CONFIG_INTERVAL" json:"remote_config_interval,omitempty"`
+	DeltaReport      bool                 `env:"DELTA_REPORT" json:"delta_report,omitempty"`
 }
 
 // NewAgent constructor for AgentConfig.
 func NewAgent() (*AgentConfig, error) {
-	agentConfig := &AgentConfig{}
-	agentConfig.parseFlags()
-	if err := env.Parse(agentConfig); err != nil {
+	return NewAgentLoader().Load()
+}
+
+// AgentLoader reads agent config. Command line flags are parsed once, environment
+// and config file are read again on every Load, so config can be reloaded without restart.
+type AgentLoader struct {
+	flags AgentConfig
+}
+
+// NewAgentLoader constructor for AgentLoader. Parses command line flags.
+func NewAgentLoader() *AgentLoader {
+	loader := &AgentLoader{}
+	loader.flags.parseFlags()
+	return loader
+}
+
+// Load read agent config.
+func (l *AgentLoader) Load() (*AgentConfig, error) {
+	agentConfig := l.flags
+	if err := env.Parse(&agentConfig); err != nil {
 		return nil, fmt.Errorf("failed parse agent flags: %w", err)
 	}
 	if err := agentConfig.parseFromFile(); err != nil {
 		return nil, fmt.Errorf("failed create agent config: %w", err)
 	}
 
-	return agentConfig, nil
+	return &agentConfig, nil
 }
 
 func (c *AgentConfig) parseFlags() {
@@ -66,6 +137,61 @@ func (c *AgentConfig) parseFlags() {
 	flag.UintVar(&c.RateLimit, "l", defaultAgentRateLimitValue, "limit of simultaneously requests to server")
 	flag.StringVar(&c.CryptoKey, "crypto-key", defaultAgentCryptoKeyValue, "public crypto key for cipher transferred data")
 	flag.StringVar(&c.ConfigPath, "c", defaultAgentConfigValue, "file path to json configuration file")
+	flag.StringVar(&c.SpoolDir, "spool-dir", defaultAgentSpoolDirValue, "directory for metrics failed to send")
+	flag.UintVar(&c.SpoolMaxSize, "spool-max-size", defaultAgentSpoolMaxSizeValue, "max size of spool dir in bytes")
+	flag.BoolVar(&c.DeltaReport, "delta-report", defaultAgentDeltaReportValue, "send only changed metrics")
+	flag.UintVar(
+		&c.FullResync, "full-resync",
+		defaultAgentFullResyncValue, "interval agent send all metrics in delta report mode",
+	)
+	flag.UintVar(
+		&c.RequestTimeout, "request-timeout",
+		defaultAgentRequestTimeoutValue, "timeout of request to server in seconds",
+	)
+	flag.UintVar(
+		&c.ShutdownTimeout, "shutdown-timeout",
+		defaultAgentShutdownTimeout, "deadline of final report on shutdown in seconds, 0 disable final report",
+	)
+	flag.UintVar(&c.RetryCount, "retry-count", defaultAgentRetryCountValue, "count of retries of failed request")
+	flag.UintVar(
+		&c.BreakerThreshold, "breaker-threshold",
+		defaultAgentBreakerThreshold, "count of failed requests which open circuit breaker, 0 disable breaker",
+	)
+	flag.UintVar(
+		&c.BreakerTimeout, "breaker-timeout",
+		defaultAgentBreakerTimeout, "seconds circuit breaker stay open before probe request",
+	)
+	flag.StringVar(
+		&c.HealthAddress, "health-address",
+		defaultAgentHealthAddressValue, "local address of /healthz and /status endpoints",
+	)
+	flag.StringVar(
+		&c.DestinationMode, "destination-mode",
+		defaultAgentDestinationMode, "mode of reporting to multiple destinations: broadcast or failover",
+	)
+	flag.StringVar(
+		&c.Output, "output",
+		defaultAgentOutputValue, "write reports to stdout or given file instead of sending them to server",
+	)
+	flag.StringVar(&c.OutputFormat, "output-format", defaultAgentOutputFormatValue, "format of output: json or prometheus")
+	flag.UintVar(&c.OutputMaxSize, "output-max-size", defaultAgentOutputMaxSizeValue, "max size of output file in bytes")
+	flag.UintVar(
+		&c.OutputMaxFiles, "output-max-files",
+		defaultAgentOutputMaxFilesValue, "count of kept rotated output files",
+	)
+	flag.StringVar(
+		&c.ImportPath, "import",
+		defaultAgentImportValue, "send reports from output file in json format and exit",
+	)
+	flag.StringVar(&c.AgentID, "agent-id", defaultAgentIDValue, "identifier of agent, hostname by default")
+	flag.UintVar(
+		&c.RemoteConfig, "remote-config-interval",
+		defaultAgentRemoteConfigValue, "interval agent poll its config from server in seconds, 0 disable polling",
+	)
+	flag.StringVar(
+		&c.RuntimeSource, "runtime-source",
+		defaultAgentRuntimeSource, "source of go runtime metrics: memstats or runtime",
+	)
 	flag.Parse()
 }
 
@@ -80,13 +206,31 @@ func (c *AgentConfig) parseFromFile() error {
 	}
 
 	tempConfig := AgentConfig{
-		Host:           defaultAgentAddressValue,
-		SecretKey:      defaultAgentSecretKeyValue,
-		CryptoKey:      defaultAgentCryptoKeyValue,
-		ConfigPath:     defaultAgentConfigValue,
-		PollInterval:   defaultAgentPollIntervalValue,
-		ReportInterval: defaultAgentReportIntervalValue,
-		RateLimit:      defaultAgentRateLimitValue,
+		Host:             defaultAgentAddressValue,
+		SecretKey:        defaultAgentSecretKeyValue,
+		CryptoKey:        defaultAgentCryptoKeyValue,
+		ConfigPath:       defaultAgentConfigValue,
+		PollInterval:     defaultAgentPollIntervalValue,
+		ReportInterval:   defaultAgentReportIntervalValue,
+		RateLimit:        defaultAgentRateLimitValue,
+		SpoolDir:         defaultAgentSpoolDirValue,
+		HealthAddress:    defaultAgentHealthAddressValue,
+		DestinationMode:  defaultAgentDestinationMode,
+		Output:           defaultAgentOutputValue,
+		OutputFormat:     defaultAgentOutputFormatValue,
+		OutputMaxSize:    defaultAgentOutputMaxSizeValue,
+		OutputMaxFiles:   defaultAgentOutputMaxFilesValue,
+		RuntimeSource:    defaultAgentRuntimeSource,
+		AgentID:          defaultAgentIDValue,
+		RemoteConfig:     defaultAgentRemoteConfigValue,
+		SpoolMaxSize:     defaultAgentSpoolMaxSizeValue,
+		FullResync:       defaultAgentFullResyncValue,
+		RequestTimeout:   defaultAgentRequestTimeoutValue,
+		ShutdownTimeout:  defaultAgentShutdownTimeout,
+		RetryCount:       defaultAgentRetryCountValue,
+		BreakerThreshold: defaultAgentBreakerThreshold,
+		BreakerTimeout:   defaultAgentBreakerTimeout,
+		DeltaReport:      defaultAgentDeltaReportValue,
 	}
 
 	if err = json.Unmarshal(fileContent, &tempConfig); err != nil {
@@ -111,15 +255,112 @@ func (c *AgentConfig) parseFromFile() error {
 	if c.RateLimit == defaultAgentRateLimitValue || c.RateLimit == nullIntValue {
 		c.RateLimit = tempConfig.RateLimit
 	}
+	if c.SpoolDir == defaultAgentSpoolDirValue {
+		c.SpoolDir = tempConfig.SpoolDir
+	}
+	if c.HealthAddress == defaultAgentHealthAddressValue {
+		c.HealthAddress = tempConfig.HealthAddress
+	}
+	if c.DestinationMode == defaultAgentDestinationMode || c.DestinationMode == nullStringValue {
+		c.DestinationMode = tempConfig.DestinationMode
+	}
+	if c.Output == defaultAgentOutputValue {
+		c.Output = tempConfig.Output
+	}
+	if c.OutputFormat == defaultAgentOutputFormatValue || c.OutputFormat == nullStringValue {
+		c.OutputFormat = tempConfig.OutputFormat
+	}
+	if c.OutputMaxSize == defaultAgentOutputMaxSizeValue || c.OutputMaxSize == nullIntValue {
+		c.OutputMaxSize = tempConfig.OutputMaxSize
+	}
+	if c.OutputMaxFiles == defaultAgentOutputMaxFilesValue {
+		c.OutputMaxFiles = tempConfig.OutputMaxFiles
+	}
+	if c.RuntimeSource == defaultAgentRuntimeSource || c.RuntimeSource == nullStringValue {
+		c.RuntimeSource = tempConfig.RuntimeSource
+	}
+	if c.AgentID == defaultAgentIDValue {
+		c.AgentID = tempConfig.AgentID
+	}
+	if c.RemoteConfig == defaultAgentRemoteConfigValue {
+		c.RemoteConfig = tempConfig.RemoteConfig
+	}
+	if c.SpoolMaxSize == defaultAgentSpoolMaxSizeValue || c.SpoolMaxSize == nullIntValue {
+		c.SpoolMaxSize = tempConfig.SpoolMaxSize
+	}
+	if c.FullResync == defaultAgentFullResyncValue || c.FullResync == nullIntValue {
+		c.FullResync = tempConfig.FullResync
+	}
+	if c.RequestTimeout == defaultAgentRequestTimeoutValue || c.RequestTimeout == nullIntValue {
+		c.RequestTimeout = tempConfig.RequestTimeout
+	}
+	if c.ShutdownTimeout == defaultAgentShutdownTimeout {
+		c.ShutdownTimeout = tempConfig.ShutdownTimeout
+	}
+	if c.RetryCount == defaultAgentRetryCountValue {
+		c.RetryCount = tempConfig.RetryCount
+	}
+	if c.BreakerThreshold == defaultAgentBreakerThreshold {
+		c.BreakerThreshold = tempConfig.BreakerThreshold
+	}
+	if c.BreakerTimeout == defaultAgentBreakerTimeout || c.BreakerTimeout == nullIntValue {
+		c.BreakerTimeout = tempConfig.BreakerTimeout
+	}
+	if !c.DeltaReport {
+		c.DeltaReport = tempConfig.DeltaReport
+	}
+	c.RuntimeMetrics = tempConfig.RuntimeMetrics
+	c.HostMetrics = tempConfig.HostMetrics
+	c.CgroupMetrics = tempConfig.CgroupMetrics
+	c.ProcessMetrics = tempConfig.ProcessMetrics
+	c.ExecMetrics = tempConfig.ExecMetrics
+	c.ScrapeMetrics = tempConfig.ScrapeMetrics
+	c.PushMetrics = tempConfig.PushMetrics
+	c.LogMetrics = tempConfig.LogMetrics
+	c.Aggregation = tempConfig.Aggregation
+	c.Labels = tempConfig.Labels
+	c.Rules = tempConfig.Rules
+	c.Destinations = tempConfig.Destinations
 
 	return nil
 }
 
+// ID return identifier of agent. Hostname is used, when identifier is not configured.
+func (c *AgentConfig) ID() string {
+	if c.AgentID != "" {
+		return c.AgentID
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return hostname
+}
+
+// ShouldPollRemoteConfig check if agent configured for polling its settings from server.
+func (c *AgentConfig) ShouldPollRemoteConfig() bool {
+	return c.RemoteConfig > 0
+}
+
 // ShouldSignData check if agent configured for sign sending data.
 func (c *AgentConfig) ShouldSignData() bool {
 	return c.SecretKey != ""
 }
 
+// ShouldSpoolData check if agent configured for keep metrics failed to send on disk.
+func (c *AgentConfig) ShouldSpoolData() bool {
+	return c.SpoolDir != ""
+}
+
+// ShutdownSpoolDir return directory for metrics failed to send on shutdown. It is spool dir, if it is configured.
+// Otherwise it is directory of agent in system temp dir, which is drained on next start.
+func (c *AgentConfig) ShutdownSpoolDir() string {
+	if c.ShouldSpoolData() {
+		return c.SpoolDir
+	}
+	return filepath.Join(os.TempDir(), "metrics-agent-spool", url.PathEscape(c.ID()))
+}
+
 // ShouldCipherData check if agent configured for crypt sending data.
 func (c *AgentConfig) ShouldCipherData() bool {
 	if c.CryptoKey == "" {
@@ -30,62 +30,168 @@ func TestAgentConfig_parseFromFile(t *testing.T) {
 		{
 			name: "success case with default base config",
 			baseConfig: AgentConfig{
-				Host:           defaultAgentAddressValue,
-				SecretKey:      defaultAgentSecretKeyValue,
-				CryptoKey:      defaultAgentCryptoKeyValue,
-				PollInterval:   defaultAgentPollIntervalValue,
-				ReportInterval: defaultAgentReportIntervalValue,
-				RateLimit:      defaultAgentRateLimitValue,
-				ConfigPath:     defaultAgentConfigValue,
+				Host:             defaultAgentAddressValue,
+				SecretKey:        defaultAgentSecretKeyValue,
+				CryptoKey:        defaultAgentCryptoKeyValue,
+				PollInterval:     defaultAgentPollIntervalValue,
+				ReportInterval:   defaultAgentReportIntervalValue,
+				RateLimit:        defaultAgentRateLimitValue,
+				ConfigPath:       defaultAgentConfigValue,
+				SpoolDir:         defaultAgentSpoolDirValue,
+				HealthAddress:    defaultAgentHealthAddressValue,
+				DestinationMode:  defaultAgentDestinationMode,
+				OutputFormat:     defaultAgentOutputFormatValue,
+				OutputMaxSize:    defaultAgentOutputMaxSizeValue,
+				OutputMaxFiles:   defaultAgentOutputMaxFilesValue,
+				RuntimeSource:    defaultAgentRuntimeSource,
+				AgentID:          defaultAgentIDValue,
+				RemoteConfig:     defaultAgentRemoteConfigValue,
+				SpoolMaxSize:     defaultAgentSpoolMaxSizeValue,
+				FullResync:       defaultAgentFullResyncValue,
+				RequestTimeout:   defaultAgentRequestTimeoutValue,
+				ShutdownTimeout:  defaultAgentShutdownTimeout,
+				RetryCount:       defaultAgentRetryCountValue,
+				BreakerThreshold: defaultAgentBreakerThreshold,
+				BreakerTimeout:   defaultAgentBreakerTimeout,
 			},
 			fileConfigs: AgentConfig{
-				Host:           "localhost:9090",
-				SecretKey:      "123",
-				CryptoKey:      "321",
-				PollInterval:   5,
-				ReportInterval: 15,
-				RateLimit:      20,
+				Host:             "localhost:9090",
+				SecretKey:        "123",
+				CryptoKey:        "321",
+				PollInterval:     5,
+				ReportInterval:   15,
+				RateLimit:        20,
+				SpoolDir:         "/var/spool/agent",
+				HealthAddress:    "localhost:9100",
+				DestinationMode:  DestinationModeFailover,
+				Output:           "/var/log/agent/metrics.jsonl",
+				OutputFormat:     OutputFormatPrometheus,
+				OutputMaxSize:    1024,
+				OutputMaxFiles:   2,
+				RuntimeSource:    RuntimeSourceRuntime,
+				AgentID:          "agent-1",
+				RemoteConfig:     60,
+				SpoolMaxSize:     1024,
+				FullResync:       60,
+				RequestTimeout:   3,
+				ShutdownTimeout:  8,
+				RetryCount:       1,
+				BreakerThreshold: 2,
+				BreakerTimeout:   10,
+				DeltaReport:      true,
 			},
 			filePath: tempFileConfigPath,
 			wantErr:  false,
 			want: AgentConfig{
-				Host:           "localhost:9090",
-				SecretKey:      "123",
-				CryptoKey:      "321",
-				PollInterval:   5,
-				ReportInterval: 15,
-				RateLimit:      20,
-				ConfigPath:     tempFileConfigPath,
+				Host:             "localhost:9090",
+				SecretKey:        "123",
+				CryptoKey:        "321",
+				PollInterval:     5,
+				ReportInterval:   15,
+				RateLimit:        20,
+				SpoolDir:         "/var/spool/agent",
+				HealthAddress:    "localhost:9100",
+				DestinationMode:  DestinationModeFailover,
+				Output:           "/var/log/agent/metrics.jsonl",
+				OutputFormat:     OutputFormatPrometheus,
+				OutputMaxSize:    1024,
+				OutputMaxFiles:   2,
+				RuntimeSource:    RuntimeSourceRuntime,
+				AgentID:          "agent-1",
+				RemoteConfig:     60,
+				SpoolMaxSize:     1024,
+				FullResync:       60,
+				RequestTimeout:   3,
+				ShutdownTimeout:  8,
+				RetryCount:       1,
+				BreakerThreshold: 2,
+				BreakerTimeout:   10,
+				DeltaReport:      true,
+				ConfigPath:       tempFileConfigPath,
 			},
 		},
 		{
 			name: "no effect case",
 			baseConfig: AgentConfig{
-				Host:           "localhost:8090",
-				SecretKey:      "123",
-				CryptoKey:      "123",
-				PollInterval:   4,
-				ReportInterval: 5,
-				RateLimit:      6,
+				Host:             "localhost:8090",
+				SecretKey:        "123",
+				CryptoKey:        "123",
+				PollInterval:     4,
+				ReportInterval:   5,
+				RateLimit:        6,
+				SpoolDir:         "/tmp/spool",
+				HealthAddress:    "localhost:9200",
+				DestinationMode:  DestinationModeFailover,
+				Output:           OutputStdout,
+				OutputFormat:     OutputFormatPrometheus,
+				OutputMaxSize:    2048,
+				OutputMaxFiles:   3,
+				RuntimeSource:    RuntimeSourceRuntime,
+				AgentID:          "agent-2",
+				RemoteConfig:     30,
+				SpoolMaxSize:     2048,
+				FullResync:       30,
+				RequestTimeout:   10,
+				ShutdownTimeout:  12,
+				RetryCount:       5,
+				BreakerThreshold: 8,
+				BreakerTimeout:   60,
+				DeltaReport:      true,
 			},
 			fileConfigs: AgentConfig{
-				Host:           "localhost:8091",
-				SecretKey:      "321",
-				CryptoKey:      "321",
-				PollInterval:   5,
-				ReportInterval: 6,
-				RateLimit:      7,
+				Host:             "localhost:8091",
+				SecretKey:        "321",
+				CryptoKey:        "321",
+				PollInterval:     5,
+				ReportInterval:   6,
+				RateLimit:        7,
+				SpoolDir:         "/var/spool/agent",
+				HealthAddress:    "localhost:9100",
+				DestinationMode:  DestinationModeBroadcast,
+				Output:           "/tmp/metrics.jsonl",
+				OutputFormat:     OutputFormatJSON,
+				OutputMaxSize:    4096,
+				OutputMaxFiles:   1,
+				RuntimeSource:    RuntimeSourceMemStats,
+				AgentID:          "agent-3",
+				RemoteConfig:     90,
+				SpoolMaxSize:     1024,
+				FullResync:       60,
+				RequestTimeout:   3,
+				ShutdownTimeout:  7,
+				RetryCount:       1,
+				BreakerThreshold: 2,
+				BreakerTimeout:   10,
+				DeltaReport:      true,
 			},
 			filePath: tempFileConfigPath,
 			wantErr:  false,
 			want: AgentConfig{
-				Host:           "localhost:8090",
-				SecretKey:      "123",
-				CryptoKey:      "123",
-				PollInterval:   4,
-				ReportInterval: 5,
-				RateLimit:      6,
-				ConfigPath:     tempFileConfigPath,
+				Host:             "localhost:8090",
+				SecretKey:        "123",
+				CryptoKey:        "123",
+				PollInterval:     4,
+				ReportInterval:   5,
+				RateLimit:        6,
+				SpoolDir:         "/tmp/spool",
+				HealthAddress:    "localhost:9200",
+				DestinationMode:  DestinationModeFailover,
+				Output:           OutputStdout,
+				OutputFormat:     OutputFormatPrometheus,
+				OutputMaxSize:    2048,
+				OutputMaxFiles:   3,
+				RuntimeSource:    RuntimeSourceRuntime,
+				AgentID:          "agent-2",
+				RemoteConfig:     30,
+				SpoolMaxSize:     2048,
+				FullResync:       30,
+				RequestTimeout:   10,
+				ShutdownTimeout:  12,
+				RetryCount:       5,
+				BreakerThreshold: 8,
+				BreakerTimeout:   60,
+				DeltaReport:      true,
+				ConfigPath:       tempFileConfigPath,
 			},
 		},
 	}
@@ -112,3 +218,29 @@ func TestAgentConfig_parseFromFile(t *testing.T) {
 		})
 	}
 }
+
+func TestAgentLoader_Load(t *testing.T) {
+	configPath := t.TempDir() + "/agent.json"
+	loader := &AgentLoader{flags: AgentConfig{
+		Host:           defaultAgentAddressValue,
+		PollInterval:   defaultAgentPollIntervalValue,
+		ReportInterval: 20,
+		ConfigPath:     configPath,
+	}}
+
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"poll_interval": 5, "report_interval": 30}`), 0600))
+	cnfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, uint(5), cnfg.PollInterval)
+	assert.Equal(t, uint(20), cnfg.ReportInterval, "flag takes precedence over file")
+
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"poll_interval": 7, "address": "localhost:9090"}`), 0600))
+	cnfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, uint(7), cnfg.PollInterval)
+	assert.Equal(t, "localhost:9090", cnfg.Host)
+
+	require.NoError(t, os.WriteFile(configPath, []byte(`{`), 0600))
+	_, err = loader.Load()
+	require.Error(t, err)
+}
@@ -0,0 +1,15 @@
+package config
+
+// AggregationConfig settings of gauges aggregation over report interval.
+//
+// Metrics map metric name to aggregation mode: "last", "suffixed" or "summary".
+// Default mode is applied to gauges not listed in Metrics, empty mode means "last".
+type AggregationConfig struct {
+	Metrics map[string]string `json:"metrics,omitempty"`
+	Default string            `json:"default,omitempty"`
+}
+
+// IsEnabled check if any gauge may be aggregated.
+func (c *AggregationConfig) IsEnabled() bool {
+	return len(c.Metrics) > 0 || c.Default != ""
+}
@@ -0,0 +1,153 @@
+package config
+
+// Sources of go runtime metrics of agent.
+const (
+	RuntimeSourceMemStats = "memstats"
+	RuntimeSourceRuntime  = "runtime"
+)
+
+// Modes of reporting runtime histograms.
+const (
+	HistogramModeQuantiles = "quantiles"
+	HistogramModeBuckets   = "buckets"
+)
+
+// RuntimeMetricsConfig settings of collector based on runtime/metrics package.
+//
+// Histograms is "quantiles" (default) for reporting Quantiles of histograms as gauges
+// or "buckets" for reporting every bucket as counter with "le" label.
+type RuntimeMetricsConfig struct {
+	Labels     map[string]string `json:"labels,omitempty"`
+	Histograms string            `json:"histograms,omitempty"`
+	Quantiles  []float64         `json:"quantiles,omitempty"`
+}
+
+// HostMetricsConfig settings of host metrics collector.
+//
+// Names map allow to override base metric name, for example {"LoadAverage1": "Load1"}.
+// Per instance metrics (disks, network interfaces) got instance name as suffix after override.
+type HostMetricsConfig struct {
+	Names      map[string]string `json:"names,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Prefix     string            `json:"prefix,omitempty"`
+	Disable    []string          `json:"disable,omitempty"`
+	Mounts     []string          `json:"mounts,omitempty"`
+	Interfaces []string          `json:"interfaces,omitempty"`
+}
+
+// IsGroupEnabled check if given group of host metrics not disabled by config.
+func (c *HostMetricsConfig) IsGroupEnabled(group string) bool {
+	for _, disabled := range c.Disable {
+		if disabled == group {
+			return false
+		}
+	}
+	return true
+}
+
+// CgroupMetricsConfig settings of container cgroup metrics collector.
+type CgroupMetricsConfig struct {
+	Labels  map[string]string `json:"labels,omitempty"`
+	Root    string            `json:"root,omitempty"`
+	Enabled bool              `json:"enabled,omitempty"`
+}
+
+// ProcessMetricsConfig settings of per process metrics collector.
+//
+// Processes are grouped by name. When patterns are given only processes with matched names are reported,
+// otherwise top processes ordered by TopBy ("cpu" or "rss") are reported.
+// Number of reported process groups never exceed Limit.
+type ProcessMetricsConfig struct {
+	Labels   map[string]string `json:"labels,omitempty"`
+	TopBy    string            `json:"top_by,omitempty"`
+	Patterns []string          `json:"patterns,omitempty"`
+	Limit    uint              `json:"limit,omitempty"`
+	Enabled  bool              `json:"enabled,omitempty"`
+}
+
+// ExecMetricsConfig settings of collector, which runs commands and parses their output as metrics.
+type ExecMetricsConfig struct {
+	Commands       []ExecCommandConfig `json:"commands,omitempty"`
+	MaxConcurrency uint                `json:"max_concurrency,omitempty"`
+	MaxOutputSize  uint                `json:"max_output_size,omitempty"`
+}
+
+// ExecCommandConfig command for exec collector.
+//
+// Format is "lines" for lines in "name type value" form or "json" for list of metrics in /updates format.
+// Interval and Timeout are in seconds, zero interval means agent poll interval.
+type ExecCommandConfig struct {
+	Labels   map[string]string `json:"labels,omitempty"`
+	Name     string            `json:"name"`
+	Command  string            `json:"command"`
+	Format   string            `json:"format,omitempty"`
+	Args     []string          `json:"args,omitempty"`
+	Interval uint              `json:"interval,omitempty"`
+	Timeout  uint              `json:"timeout,omitempty"`
+}
+
+// ScrapeMetricsConfig settings of Prometheus endpoints scraping by agent.
+type ScrapeMetricsConfig struct {
+	Targets []ScrapeTargetConfig `json:"targets,omitempty"`
+}
+
+// ScrapeTargetConfig Prometheus endpoint, which exposes metrics in text format.
+//
+// Labels are added to labels of every scraped sample.
+// Prefix is added to every scraped metric name. Interval and Timeout are in seconds,
+// zero interval means agent poll interval.
+type ScrapeTargetConfig struct {
+	Labels   map[string]string `json:"labels,omitempty"`
+	URL      string            `json:"url"`
+	Prefix   string            `json:"prefix,omitempty"`
+	Interval uint              `json:"interval,omitempty"`
+	Timeout  uint              `json:"timeout,omitempty"`
+}
+
+// PushMetricsConfig settings of local endpoint, which accepts metrics pushed by applications.
+//
+// Address is host:port on loopback interface or path to Unix socket with "unix:" prefix.
+// MaxSeries limits number of different metrics kept by agent, new metrics over limit are rejected.
+// Gauges, which are not pushed again during GaugeTTL seconds, are expired and not reported anymore.
+// Zero GaugeTTL means agent report interval.
+type PushMetricsConfig struct {
+	Labels    map[string]string `json:"labels,omitempty"`
+	Address   string            `json:"address,omitempty"`
+	MaxSeries uint              `json:"max_series,omitempty"`
+	GaugeTTL  uint              `json:"gauge_ttl,omitempty"`
+}
+
+// LogMetricsConfig settings of collector, which tails log files and extracts metrics from their lines.
+//
+// Offsets of files are saved to StatePath, so lines are not counted twice after agent restart.
+// Offsets are saved as soon as lines are read, before metrics built from them are delivered to server,
+// so delivery is at-most-once: metrics of lines read just before agent crash or lost report
+// without configured spool are not counted again. Empty StatePath means offsets are kept only in memory.
+// Interval is in seconds, zero interval means agent poll interval.
+type LogMetricsConfig struct {
+	StatePath string          `json:"state_path,omitempty"`
+	Files     []LogFileConfig `json:"files,omitempty"`
+	Interval  uint            `json:"interval,omitempty"`
+}
+
+// LogFileConfig log file and rules applied to its lines.
+// File found on agent start is read from the end, unless FromStart is set.
+type LogFileConfig struct {
+	Labels    map[string]string `json:"labels,omitempty"`
+	Path      string            `json:"path"`
+	Rules     []LogRuleConfig   `json:"rules"`
+	FromStart bool              `json:"from_start,omitempty"`
+}
+
+// LogRuleConfig rule, which matches log lines by regular expression.
+//
+// Counter is incremented by one on every matched line or by number captured by Value group.
+// Gauge is set to number captured by Value group. Value is name or number of group.
+// Label values may reference groups as $name or ${name}.
+type LogRuleConfig struct {
+	Labels  map[string]string `json:"labels,omitempty"`
+	Name    string            `json:"name"`
+	Pattern string            `json:"pattern"`
+	Type    string            `json:"type"`
+	Value   string            `json:"value,omitempty"`
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// Modes of reporting metrics to multiple destinations.
+const (
+	DestinationModeBroadcast = "broadcast"
+	DestinationModeFailover  = "failover"
+)
+
+// Protocols of destination.
+const (
+	ProtocolHTTP  = "http"
+	ProtocolHTTPS = "https"
+)
+
+// DestinationConfig server, which agent reports metrics to.
+//
+// In "broadcast" mode every destination receives all metrics and has own spool in subdirectory Name
+// of spool dir. In "failover" mode metrics are sent to first available destination in order.
+// Empty Name means destination address, empty Protocol means "http".
+type DestinationConfig struct {
+	Name      string `json:"name,omitempty"`
+	Address   string `json:"address"`
+	SecretKey string `json:"secret_key,omitempty"`
+	CryptoKey string `json:"crypto_key,omitempty"`
+	Protocol  string `json:"protocol,omitempty"`
+}
+
+// DestinationList return configured destinations. If destinations are not configured,
+// single destination with agent address and keys is returned.
+func (c *AgentConfig) DestinationList() []DestinationConfig {
+	if len(c.Destinations) == 0 {
+		return []DestinationConfig{{
+			Address:   c.Host,
+			SecretKey: c.SecretKey,
+			CryptoKey: c.CryptoKey,
+		}}
+	}
+	destinations := make([]DestinationConfig, len(c.Destinations))
+	for i, destination := range c.Destinations {
+		if destination.Name == "" {
+			destination.Name = strings.NewReplacer(":", "_", "/", "_").Replace(destination.Address)
+		}
+		destinations[i] = destination
+	}
+	return destinations
+}
+
+// ShouldSignData check if destination configured for sign sending data.
+func (c *DestinationConfig) ShouldSignData() bool {
+	return c.SecretKey != ""
+}
+
+// ShouldCipherData check if destination configured for crypt sending data.
+func (c *DestinationConfig) ShouldCipherData() bool {
+	if c.CryptoKey == "" {
+		return false
+	}
+
+	if _, err := os.Stat(c.CryptoKey); os.IsNotExist(err) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentConfig_DestinationList(t *testing.T) {
+	tests := []struct {
+		name string
+		cnfg AgentConfig
+		want []DestinationConfig
+	}{
+		{
+			name: "agent address case",
+			cnfg: AgentConfig{Host: "localhost:8080", SecretKey: "secret", CryptoKey: "key.pem"},
+			want: []DestinationConfig{{Address: "localhost:8080", SecretKey: "secret", CryptoKey: "key.pem"}},
+		},
+		{
+			name: "destinations case",
+			cnfg: AgentConfig{
+				Host: "localhost:8080",
+				Destinations: []DestinationConfig{
+					{Address: "old.example.com:8080"},
+					{Name: "new", Address: "new.example.com:443", Protocol: ProtocolHTTPS},
+				},
+			},
+			want: []DestinationConfig{
+				{Name: "old.example.com_8080", Address: "old.example.com:8080"},
+				{Name: "new", Address: "new.example.com:443", Protocol: ProtocolHTTPS},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.cnfg.DestinationList())
+		})
+	}
+}
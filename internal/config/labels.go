@@ -0,0 +1,17 @@
+package config
+
+// LabelsConfig static labels, which agent attaches to every sent metric.
+//
+// Hostname is detected automatically, when it is empty and DisableHostname is not set.
+// Mode is "labels" for sending labels in labels field of metrics or "id" for encoding them into metric ID
+// for servers without labels support. IDTemplate is text/template with .Name and .Labels of metric,
+// when it is empty ID is encoded as name{label="value",...}.
+type LabelsConfig struct {
+	Custom          map[string]string `json:"custom,omitempty"`
+	Hostname        string            `json:"hostname,omitempty"`
+	Environment     string            `json:"environment,omitempty"`
+	Region          string            `json:"region,omitempty"`
+	Mode            string            `json:"mode,omitempty"`
+	IDTemplate      string            `json:"id_template,omitempty"`
+	DisableHostname bool              `json:"disable_hostname,omitempty"`
+}
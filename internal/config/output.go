@@ -0,0 +1,15 @@
+package config
+
+// Formats of agent output.
+const (
+	OutputFormatJSON       = "json"
+	OutputFormatPrometheus = "prometheus"
+)
+
+// OutputStdout value of agent output, which means writing reports to standard output.
+const OutputStdout = "stdout"
+
+// ShouldWriteOutput check if agent configured for write reports to stdout or file instead of sending them to server.
+func (c *AgentConfig) ShouldWriteOutput() bool {
+	return c.Output != ""
+}
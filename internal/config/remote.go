@@ -0,0 +1,149 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+)
+
+// RemoteAgentConfig agent settings managed by server.
+//
+// Zero fields keep values of local agent config, set nested settings replace local ones.
+// Exec and push collectors and destinations are not managed by server on purpose,
+// they run commands and listen addresses on agent host.
+type RemoteAgentConfig struct {
+	HostMetrics    *HostMetricsConfig    `json:"host_metrics,omitempty"`
+	CgroupMetrics  *CgroupMetricsConfig  `json:"cgroup_metrics,omitempty"`
+	ProcessMetrics *ProcessMetricsConfig `json:"process_metrics,omitempty"`
+	RuntimeMetrics *RuntimeMetricsConfig `json:"runtime_metrics,omitempty"`
+	ScrapeMetrics  *ScrapeMetricsConfig  `json:"scrape_metrics,omitempty"`
+	Aggregation    *AggregationConfig    `json:"aggregation,omitempty"`
+	Labels         *LabelsConfig         `json:"labels,omitempty"`
+	DeltaReport    *bool                 `json:"delta_report,omitempty"`
+	RuntimeSource  string                `json:"runtime_source,omitempty"`
+	Rules          []RuleConfig          `json:"rules,omitempty"`
+	PollInterval   uint                  `json:"poll_interval,omitempty"`
+	ReportInterval uint                  `json:"report_interval,omitempty"`
+	RateLimit      uint                  `json:"rate_limit,omitempty"`
+	FullResync     uint                  `json:"full_resync_interval,omitempty"`
+}
+
+// ApplyRemote return copy of config with settings received from server.
+func (c *AgentConfig) ApplyRemote(remote *RemoteAgentConfig) *AgentConfig {
+	applied := *c
+	if remote == nil {
+		return &applied
+	}
+	if remote.PollInterval != nullIntValue {
+		applied.PollInterval = remote.PollInterval
+	}
+	if remote.ReportInterval != nullIntValue {
+		applied.ReportInterval = remote.ReportInterval
+	}
+	if remote.RateLimit != nullIntValue {
+		applied.RateLimit = remote.RateLimit
+	}
+	if remote.FullResync != nullIntValue {
+		applied.FullResync = remote.FullResync
+	}
+	if remote.RuntimeSource != nullStringValue {
+		applied.RuntimeSource = remote.RuntimeSource
+	}
+	if remote.DeltaReport != nil {
+		applied.DeltaReport = *remote.DeltaReport
+	}
+	if remote.HostMetrics != nil {
+		applied.HostMetrics = *remote.HostMetrics
+	}
+	if remote.CgroupMetrics != nil {
+		applied.CgroupMetrics = *remote.CgroupMetrics
+	}
+	if remote.ProcessMetrics != nil {
+		applied.ProcessMetrics = *remote.ProcessMetrics
+	}
+	if remote.RuntimeMetrics != nil {
+		applied.RuntimeMetrics = *remote.RuntimeMetrics
+	}
+	if remote.ScrapeMetrics != nil {
+		applied.ScrapeMetrics = *remote.ScrapeMetrics
+	}
+	if remote.Aggregation != nil {
+		applied.Aggregation = *remote.Aggregation
+	}
+	if remote.Labels != nil {
+		applied.Labels = *remote.Labels
+	}
+	if remote.Rules != nil {
+		applied.Rules = remote.Rules
+	}
+	return &applied
+}
+
+// AgentConfigs settings of agents served by server.
+//
+// Settings of agent are merged by top level keys from Default, group of agent and agent own settings,
+// later ones take precedence. Agents not listed in Agents get Default settings.
+type AgentConfigs struct {
+	Groups  map[string]json.RawMessage `json:"groups,omitempty"`
+	Agents  map[string]AgentEntry      `json:"agents,omitempty"`
+	Default json.RawMessage            `json:"default,omitempty"`
+}
+
+// AgentEntry settings of single agent.
+type AgentEntry struct {
+	Group    string          `json:"group,omitempty"`
+	Settings json.RawMessage `json:"settings,omitempty"`
+}
+
+// LoadAgentConfigs read agent settings from json file.
+func LoadAgentConfigs(path string) (*AgentConfigs, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed read agent configs: %w", err)
+	}
+	configs := &AgentConfigs{}
+	if err = json.Unmarshal(data, configs); err != nil {
+		return nil, fmt.Errorf("invalid agent configs content: %w", err)
+	}
+	return configs, nil
+}
+
+// Resolve return merged settings of agent in json format. Result is validated as RemoteAgentConfig.
+func (c *AgentConfigs) Resolve(agentID string) ([]byte, error) {
+	layers := []json.RawMessage{c.Default}
+	if entry, ok := c.Agents[agentID]; ok {
+		if entry.Group != "" {
+			group, ok := c.Groups[entry.Group]
+			if !ok {
+				return nil, fmt.Errorf("unknown group %q of agent %q", entry.Group, agentID)
+			}
+			layers = append(layers, group)
+		}
+		layers = append(layers, entry.Settings)
+	}
+
+	merged := make(map[string]json.RawMessage)
+	for _, layer := range layers {
+		if len(layer) == 0 {
+			continue
+		}
+		fields := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(layer, &fields); err != nil {
+			return nil, fmt.Errorf("invalid settings of agent %q: %w", agentID, err)
+		}
+		maps.Copy(merged, fields)
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed serialize settings of agent %q: %w", agentID, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&RemoteAgentConfig{}); err != nil {
+		return nil, fmt.Errorf("invalid settings of agent %q: %w", agentID, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentConfig_ApplyRemote(t *testing.T) {
+	deltaReport := true
+	local := AgentConfig{
+		Host:           "localhost:8080",
+		PollInterval:   2,
+		ReportInterval: 10,
+		RateLimit:      1,
+		RuntimeSource:  RuntimeSourceMemStats,
+		Rules:          []RuleConfig{{Action: "drop", Match: "Random*"}},
+		ExecMetrics:    ExecMetricsConfig{Commands: []ExecCommandConfig{{Name: "queue", Command: "queue-stats"}}},
+	}
+	tests := []struct {
+		remote *RemoteAgentConfig
+		want   AgentConfig
+		name   string
+	}{
+		{
+			name: "no remote settings case",
+			want: local,
+		},
+		{
+			name: "override case",
+			remote: &RemoteAgentConfig{
+				PollInterval:  5,
+				RuntimeSource: RuntimeSourceRuntime,
+				DeltaReport:   &deltaReport,
+				CgroupMetrics: &CgroupMetricsConfig{Enabled: true},
+				Rules:         []RuleConfig{},
+			},
+			want: AgentConfig{
+				Host:           "localhost:8080",
+				PollInterval:   5,
+				ReportInterval: 10,
+				RateLimit:      1,
+				RuntimeSource:  RuntimeSourceRuntime,
+				DeltaReport:    true,
+				CgroupMetrics:  CgroupMetricsConfig{Enabled: true},
+				Rules:          []RuleConfig{},
+				ExecMetrics:    local.ExecMetrics,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := local.ApplyRemote(tt.remote)
+			assert.Equal(t, tt.want, *got)
+			assert.Equal(t, uint(2), local.PollInterval, "local config is not changed")
+		})
+	}
+}
+
+func TestAgentConfigs_Resolve(t *testing.T) {
+	configs := AgentConfigs{
+		Default: json.RawMessage(`{"poll_interval": 2, "report_interval": 10}`),
+		Groups: map[string]json.RawMessage{
+			"web": json.RawMessage(`{"report_interval": 20, "cgroup_metrics": {"enabled": true}}`),
+			"bad": json.RawMessage(`{"exec_metrics": {}}`),
+		},
+		Agents: map[string]AgentEntry{
+			"web-1":   {Group: "web", Settings: json.RawMessage(`{"poll_interval": 1}`)},
+			"web-2":   {Group: "web"},
+			"lost":    {Group: "unknown"},
+			"invalid": {Group: "bad"},
+		},
+	}
+	tests := []struct {
+		name    string
+		agentID string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "agent settings case",
+			agentID: "web-1",
+			want:    `{"cgroup_metrics":{"enabled":true},"poll_interval":1,"report_interval":20}`,
+		},
+		{
+			name:    "group settings case",
+			agentID: "web-2",
+			want:    `{"cgroup_metrics":{"enabled":true},"poll_interval":2,"report_interval":20}`,
+		},
+		{name: "default settings case", agentID: "db-1", want: `{"poll_interval":2,"report_interval":10}`},
+		{name: "unknown group case", agentID: "lost", wantErr: true},
+		{name: "not managed setting case", agentID: "invalid", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := configs.Resolve(tt.agentID)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package config
+
+// RuleConfig rule of pipeline, which agent applies to metrics before report.
+//
+// Rules are applied in order, Match is regular expression of metric name. Actions:
+//   - "include" drops metrics with not matched names;
+//   - "exclude" drops metrics with matched names;
+//   - "rename" replaces matched name with Replacement, $1 is expanded to submatch;
+//   - "replace" replaces every match in name with Replacement, empty Replacement removes matches;
+//   - "set_type" changes type of matched metrics to Type ("gauge" or "counter"),
+//     gauges converted to counters are reported with increase since previous delivered report;
+//   - "drop_unchanged" drops matched gauges, which value is the same as in previous report.
+type RuleConfig struct {
+	Action      string `json:"action"`
+	Match       string `json:"match"`
+	Replacement string `json:"replacement,omitempty"`
+	Type        string `json:"type,omitempty"`
+}
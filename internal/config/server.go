@@ -18,18 +18,22 @@ const (
 	defaultServerSecretKeyValue     = ""
 	defaultServerCryptoKeyValue     = ""
 	defaultServerConfigPathValue    = ""
+	defaultServerAgentConfigValue   = ""
+	defaultServerStaleIntervals     = 3
 )
 
 // ServerConfig server configs.
 type ServerConfig struct {
-	Host          string `env:"ADDRESS" json:"address,omitempty"`
-	FilePath      string `env:"FILE_STORAGE_PATH" json:"store_file,omitempty"`
-	DatabaseDSN   string `env:"DATABASE_DSN" json:"database_dsn,omitempty"`
-	ConfigPath    string `env:"CONFIG"`
-	SecretKey     string `env:"KEY" json:"secret_key,omitempty"`
-	CryptoKey     string `env:"CRYPTO_KEY" json:"crypto_key,omitempty"`
-	StoreInterval uint   `env:"STORE_INTERVAL" json:"store_interval,omitempty"`
-	Restore       bool   `env:"RESTORE" json:"restore,omitempty"`
+	Host           string `env:"ADDRESS" json:"address,omitempty"`
+	FilePath       string `env:"FILE_STORAGE_PATH" json:"store_file,omitempty"`
+	DatabaseDSN    string `env:"DATABASE_DSN" json:"database_dsn,omitempty"`
+	ConfigPath     string `env:"CONFIG"`
+	SecretKey      string `env:"KEY" json:"secret_key,omitempty"`
+	CryptoKey      string `env:"CRYPTO_KEY" json:"crypto_key,omitempty"`
+	AgentConfig    string `env:"AGENT_CONFIG" json:"agent_config,omitempty"`
+	StoreInterval  uint   `env:"STORE_INTERVAL" json:"store_interval,omitempty"`
+	StaleIntervals uint   `env:"AGENT_STALE_INTERVALS" json:"agent_stale_intervals,omitempty"`
+	Restore        bool   `env:"RESTORE" json:"restore,omitempty"`
 }
 
 // NewServer constructor for ServerConfig.
@@ -63,6 +67,10 @@ func (c *ServerConfig) parseFlags() {
 	flag.StringVar(&c.SecretKey, "k", defaultServerSecretKeyValue, "Secret key for sign")
 	flag.StringVar(&c.CryptoKey, "crypto-key", defaultServerCryptoKeyValue, "Private crypto key for RSA decryption")
 	flag.StringVar(&c.ConfigPath, "c", defaultServerConfigPathValue, "file path to json configuration file")
+	flag.StringVar(&c.AgentConfig, "agent-config", defaultServerAgentConfigValue, "file path to json settings of agents")
+	flag.UintVar(&c.StaleIntervals, "agent-stale-intervals", defaultServerStaleIntervals,
+		"count of missed report intervals, after which agent is stale",
+	)
 	flag.Parse()
 }
 
@@ -110,6 +118,21 @@ func (c *ServerConfig) parseFromFile() error {
 		c.SecretKey = tempConfig.SecretKey
 	}
 
+	if c.AgentConfig == defaultServerAgentConfigValue {
+		c.AgentConfig = tempConfig.AgentConfig
+	}
+
+	// Zero stale intervals disables staleness, so it is applied only when file contains the key.
+	fileStaleIntervals := struct {
+		StaleIntervals *uint `json:"agent_stale_intervals"`
+	}{}
+	if err = json.Unmarshal(configData, &fileStaleIntervals); err != nil {
+		return fmt.Errorf("invalid data in server file config: %w", err)
+	}
+	if c.StaleIntervals == defaultServerStaleIntervals && fileStaleIntervals.StaleIntervals != nil {
+		c.StaleIntervals = *fileStaleIntervals.StaleIntervals
+	}
+
 	return nil
 }
 
@@ -123,6 +146,11 @@ func (c *ServerConfig) ShouldSignData() bool {
 	return c.SecretKey != ""
 }
 
+// ShouldServeAgentConfig check if server configured for serving settings of agents.
+func (c *ServerConfig) ShouldServeAgentConfig() bool {
+	return c.AgentConfig != ""
+}
+
 // ShouldDecryptData check for server should decrypt request body.
 func (c *ServerConfig) ShouldDecryptData() bool {
 	if c.CryptoKey == "" {
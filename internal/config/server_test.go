@@ -19,12 +19,13 @@ func TestNewServer(t *testing.T) {
 		{
 			name: "success default case",
 			want: &ServerConfig{
-				Host:          ":8080",
-				FilePath:      "/tmp/metrics-db.json",
-				DatabaseDSN:   "",
-				SecretKey:     "",
-				StoreInterval: 300,
-				Restore:       true,
+				Host:           ":8080",
+				FilePath:       "/tmp/metrics-db.json",
+				DatabaseDSN:    "",
+				SecretKey:      "",
+				StoreInterval:  300,
+				StaleIntervals: 3,
+				Restore:        true,
 			},
 			wantErr: false,
 		},
@@ -64,67 +65,93 @@ func TestServerConfig_parseFromFile(t *testing.T) {
 		{
 			name: "success case with default base config",
 			baseConfig: ServerConfig{
-				Host:          defaultServerHostValue,
-				FilePath:      defaultServerFilePathValue,
-				DatabaseDSN:   defaultServerDatabaseDSNValue,
-				SecretKey:     defaultServerSecretKeyValue,
-				CryptoKey:     defaultServerCryptoKeyValue,
-				StoreInterval: defaultServerStoreIntervalValue,
-				Restore:       defaultServerRestoreValue,
+				Host:           defaultServerHostValue,
+				FilePath:       defaultServerFilePathValue,
+				DatabaseDSN:    defaultServerDatabaseDSNValue,
+				SecretKey:      defaultServerSecretKeyValue,
+				CryptoKey:      defaultServerCryptoKeyValue,
+				AgentConfig:    defaultServerAgentConfigValue,
+				StoreInterval:  defaultServerStoreIntervalValue,
+				StaleIntervals: defaultServerStaleIntervals,
+				Restore:        defaultServerRestoreValue,
 			},
 			fileConfigs: ServerConfig{
-				Host:          ":8090",
-				FilePath:      "/tmp/some-test.db",
-				DatabaseDSN:   "test dsn",
-				SecretKey:     "secret-key-test",
-				CryptoKey:     "123",
-				StoreInterval: 400,
-				Restore:       false,
+				Host:           ":8090",
+				FilePath:       "/tmp/some-test.db",
+				DatabaseDSN:    "test dsn",
+				SecretKey:      "secret-key-test",
+				CryptoKey:      "123",
+				AgentConfig:    "/etc/metrics/agents.json",
+				StoreInterval:  400,
+				StaleIntervals: 5,
+				Restore:        false,
 			},
 			filePath: tempFileConfigPath,
 			wantErr:  false,
 			want: ServerConfig{
-				Host:          ":8090",
-				FilePath:      "/tmp/some-test.db",
-				DatabaseDSN:   "test dsn",
-				SecretKey:     "secret-key-test",
-				ConfigPath:    tempFileConfigPath,
-				CryptoKey:     "123",
-				StoreInterval: 400,
-				Restore:       false,
+				Host:           ":8090",
+				FilePath:       "/tmp/some-test.db",
+				DatabaseDSN:    "test dsn",
+				SecretKey:      "secret-key-test",
+				ConfigPath:     tempFileConfigPath,
+				CryptoKey:      "123",
+				AgentConfig:    "/etc/metrics/agents.json",
+				StoreInterval:  400,
+				StaleIntervals: 5,
+				Restore:        false,
 			},
 		},
 		{
 			name: "no effect case",
 			baseConfig: ServerConfig{
-				Host:          ":8090",
-				FilePath:      "test-123-file",
-				DatabaseDSN:   "123-123-123",
-				SecretKey:     "123",
-				CryptoKey:     "321",
-				StoreInterval: 500,
-				Restore:       false,
+				Host:           ":8090",
+				FilePath:       "test-123-file",
+				DatabaseDSN:    "123-123-123",
+				SecretKey:      "123",
+				CryptoKey:      "321",
+				AgentConfig:    "/etc/metrics/agents.json",
+				StoreInterval:  500,
+				StaleIntervals: 2,
+				Restore:        false,
 			},
 			fileConfigs: ServerConfig{
-				Host:          ":8091",
-				FilePath:      "test-321-file",
-				DatabaseDSN:   "321-321-321",
-				SecretKey:     "321",
-				CryptoKey:     "123",
-				StoreInterval: 600,
-				Restore:       true,
+				Host:           ":8091",
+				FilePath:       "test-321-file",
+				DatabaseDSN:    "321-321-321",
+				SecretKey:      "321",
+				CryptoKey:      "123",
+				AgentConfig:    "/tmp/agents.json",
+				StoreInterval:  600,
+				StaleIntervals: 4,
+				Restore:        true,
 			},
 			filePath: tempFileConfigPath,
 			wantErr:  false,
 			want: ServerConfig{
-				Host:          ":8090",
-				FilePath:      "test-123-file",
-				DatabaseDSN:   "123-123-123",
-				ConfigPath:    tempFileConfigPath,
-				SecretKey:     "123",
-				CryptoKey:     "321",
-				StoreInterval: 500,
-				Restore:       false,
+				Host:           ":8090",
+				FilePath:       "test-123-file",
+				DatabaseDSN:    "123-123-123",
+				ConfigPath:     tempFileConfigPath,
+				SecretKey:      "123",
+				CryptoKey:      "321",
+				AgentConfig:    "/etc/metrics/agents.json",
+				StoreInterval:  500,
+				StaleIntervals: 2,
+				Restore:        false,
+			},
+		},
+		{
+			name: "stale intervals missing in file case",
+			baseConfig: ServerConfig{
+				Host:           ":8090",
+				StaleIntervals: defaultServerStaleIntervals,
+			},
+			fileConfigs: ServerConfig{},
+			filePath:    tempFileConfigPath,
+			want: ServerConfig{
+				Host:           ":8090",
+				ConfigPath:     tempFileConfigPath,
+				StaleIntervals: defaultServerStaleIntervals,
 			},
 		},
 	}
@@ -0,0 +1,112 @@
+package dto
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+// Headers, by which agent identify itself in every sent batch.
+const (
+	AgentIDHeader             = "X-Agent-ID"
+	AgentHostnameHeader       = "X-Agent-Hostname"
+	AgentVersionHeader        = "X-Agent-Version"
+	AgentStartedHeader        = "X-Agent-Started"
+	AgentReportIntervalHeader = "X-Agent-Report-Interval"
+)
+
+// ErrNoAgentIdentity returned, when request is not sent by identified agent.
+var ErrNoAgentIdentity = errors.New("request is not sent by identified agent")
+
+// AgentIdentity DTO for representing agent, which sent metrics.
+type AgentIdentity struct {
+	Started        time.Time
+	ID             string
+	Hostname       string
+	Version        string
+	ReportInterval uint
+}
+
+// Headers return identity in form of request headers.
+func (dto AgentIdentity) Headers() map[string]string {
+	return map[string]string{
+		AgentIDHeader:             dto.ID,
+		AgentHostnameHeader:       dto.Hostname,
+		AgentVersionHeader:        dto.Version,
+		AgentStartedHeader:        dto.Started.UTC().Format(time.RFC3339),
+		AgentReportIntervalHeader: strconv.FormatUint(uint64(dto.ReportInterval), 10),
+	}
+}
+
+// NewAgentIdentityFromRequest create AgentIdentity from request headers.
+// Returns ErrNoAgentIdentity, if request is not sent by identified agent.
+func NewAgentIdentityFromRequest(r *http.Request) (*AgentIdentity, error) {
+	id := r.Header.Get(AgentIDHeader)
+	if id == "" {
+		return nil, ErrNoAgentIdentity
+	}
+	identity := &AgentIdentity{
+		ID:       id,
+		Hostname: r.Header.Get(AgentHostnameHeader),
+		Version:  r.Header.Get(AgentVersionHeader),
+	}
+	if started := r.Header.Get(AgentStartedHeader); started != "" {
+		startedTime, err := time.Parse(time.RFC3339, started)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start time of agent %s: %w", id, err)
+		}
+		identity.Started = startedTime
+	}
+	if interval := r.Header.Get(AgentReportIntervalHeader); interval != "" {
+		reportInterval, err := strconv.ParseUint(interval, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid report interval of agent %s: %w", id, err)
+		}
+		identity.ReportInterval = uint(reportInterval)
+	}
+
+	return identity, nil
+}
+
+// ConvertToAgent create agent entity, which was seen at given time with given hashes of metric series.
+func (dto AgentIdentity) ConvertToAgent(lastSeen time.Time, series []uint64) entity.Agent {
+	return entity.Agent{
+		StartedAt:      dto.Started,
+		LastSeen:       lastSeen,
+		ID:             dto.ID,
+		Hostname:       dto.Hostname,
+		Version:        dto.Version,
+		Series:         series,
+		ReportInterval: dto.ReportInterval,
+	}
+}
+
+// AgentInfo DTO for representing registered agent in response.
+type AgentInfo struct {
+	StartedAt      time.Time `json:"started_at"`
+	LastSeen       time.Time `json:"last_seen"`
+	ID             string    `json:"id"`
+	Hostname       string    `json:"hostname"`
+	Version        string    `json:"version"`
+	MetricCount    int       `json:"metric_count"`
+	ReportInterval uint      `json:"report_interval"`
+	Stale          bool      `json:"stale"`
+}
+
+// NewAgentInfoFromAgent create AgentInfo DTO from agent entity.
+func NewAgentInfoFromAgent(agent entity.Agent, now time.Time, staleIntervals uint) AgentInfo {
+	return AgentInfo{
+		StartedAt:      agent.StartedAt,
+		LastSeen:       agent.LastSeen,
+		ID:             agent.ID,
+		Hostname:       agent.Hostname,
+		Version:        agent.Version,
+		MetricCount:    agent.MetricCount,
+		ReportInterval: agent.ReportInterval,
+		Stale:          agent.IsStale(now, staleIntervals),
+	}
+}
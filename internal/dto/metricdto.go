@@ -9,6 +9,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/ilya372317/must-have-metrics/internal/dto/validator"
+	"github.com/ilya372317/must-have-metrics/internal/promtext"
 	"github.com/ilya372317/must-have-metrics/internal/server/entity"
 )
 
@@ -26,10 +27,11 @@ func NewMetricsListDTOFromRequest(r *http.Request) (MetricsList, error) {
 
 // Metrics DTO for representing received metrics from agent.
 type Metrics struct {
-	Delta *int64   `json:"delta,omitempty" valid:"optional"` // Значение метрики в случае передачи counter
-	Value *float64 `json:"value,omitempty" valid:"optional"` // Значение метрики в случае передачи gauge
-	ID    string   `json:"id" valid:"type(string)"`          // Имя метрики
-	MType string   `json:"type" valid:"in(gauge|counter)"`   // параметр, принимающий значение gauge или counter
+	Delta  *int64            `json:"delta,omitempty" valid:"optional"`  // Значение метрики в случае передачи counter
+	Value  *float64          `json:"value,omitempty" valid:"optional"`  // Значение метрики в случае передачи gauge
+	Labels map[string]string `json:"labels,omitempty" valid:"optional"` // Метки метрики, например host или region
+	ID     string            `json:"id" valid:"type(string)"`           // Имя метрики
+	MType  string            `json:"type" valid:"in(gauge|counter)"`    // параметр, принимающий значение gauge или counter
 }
 
 // NewMetricsDTOFromRequest create Metrics DTO from given request.
@@ -102,6 +104,16 @@ func (dto *Metrics) ConvertToAlert() entity.Alert {
 	return alert
 }
 
+// FlattenLabels move labels into ID in form name{label="value",...}, so metrics with different labels
+// are stored separately.
+func (dto *Metrics) FlattenLabels() {
+	if len(dto.Labels) == 0 {
+		return
+	}
+	dto.ID = promtext.SeriesID(dto.ID, dto.Labels)
+	dto.Labels = nil
+}
+
 // Validate perform validation on Metrics
 func (dto *Metrics) Validate() (bool, error) {
 	switch dto.MType {
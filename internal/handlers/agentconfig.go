@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+)
+
+const agentIDParam = "agent_id"
+
+// AgentConfigHandler return settings of agent given in agent_id query parameter.
+// Settings file is read on every request, so changes are served without server restart.
+func AgentConfigHandler(serverConfig *config.ServerConfig) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		agentID := request.URL.Query().Get(agentIDParam)
+		if agentID == "" {
+			http.Error(writer, "agent_id parameter is required", http.StatusBadRequest)
+			return
+		}
+		agentConfigs, err := config.LoadAgentConfigs(serverConfig.AgentConfig)
+		if err != nil {
+			logger.Log.Errorf("failed load agents settings: %v", err)
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response, err := agentConfigs.Resolve(agentID)
+		if err != nil {
+			logger.Log.Errorf("failed resolve agent settings: %v", err)
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writer.Header().Set(contentTypeHeader, jsonContentHeaderValue)
+		if _, err = writer.Write(response); err != nil {
+			logger.Log.Warnf("failed write agent settings: %v", err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentConfigHandler(t *testing.T) {
+	require.NoError(t, logger.Init())
+	agentConfigPath := filepath.Join(t.TempDir(), "agents.json")
+	require.NoError(t, os.WriteFile(agentConfigPath, []byte(`{
+		"default": {"poll_interval": 2},
+		"agents": {"web-1": {"settings": {"poll_interval": 1}}}
+	}`), 0o600))
+
+	tests := []struct {
+		name       string
+		configPath string
+		query      string
+		want       string
+		status     int
+	}{
+		{
+			name:       "agent case",
+			configPath: agentConfigPath,
+			query:      "?agent_id=web-1",
+			status:     http.StatusOK,
+			want:       `{"poll_interval":1}`,
+		},
+		{
+			name:       "default case",
+			configPath: agentConfigPath,
+			query:      "?agent_id=db-1",
+			status:     http.StatusOK,
+			want:       `{"poll_interval":2}`,
+		},
+		{name: "missing agent id case", configPath: agentConfigPath, status: http.StatusBadRequest},
+		{
+			name:       "missing file case",
+			configPath: "/not/exists.json",
+			query:      "?agent_id=web-1",
+			status:     http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/agent/config"+tt.query, nil)
+			recorder := httptest.NewRecorder()
+			AgentConfigHandler(&config.ServerConfig{AgentConfig: tt.configPath}).ServeHTTP(recorder, request)
+
+			response := recorder.Result()
+			defer func() {
+				_ = response.Body.Close()
+			}()
+			assert.Equal(t, tt.status, response.StatusCode)
+			if tt.status != http.StatusOK {
+				return
+			}
+			body, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(body))
+		})
+	}
+}
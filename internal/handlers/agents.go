@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+type agentsStorage interface {
+	AllAgents(ctx context.Context) ([]entity.Agent, error)
+}
+
+// AgentsHandler give list of registered agents with last seen time and count of metrics in json format.
+func AgentsHandler(storage agentsStorage, serverConfig *config.ServerConfig) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("content-type", "application/json")
+		agents, err := storage.AllAgents(request.Context())
+		if err != nil {
+			http.Error(writer, fmt.Sprintf("failed get agents from storage: %v", err), http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		agentInfos := make([]dto.AgentInfo, 0, len(agents))
+		for _, agent := range agents {
+			agentInfos = append(agentInfos, dto.NewAgentInfoFromAgent(agent, now, serverConfig.StaleIntervals))
+		}
+		response, err := json.Marshal(agentInfos)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			logger.Log.Warn(err)
+			return
+		}
+		if _, err = writer.Write(response); err != nil {
+			logger.Log.Warn(err)
+		}
+	}
+}
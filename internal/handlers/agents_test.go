@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/server/service"
+	"github.com/ilya372317/must-have-metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentsHandler(t *testing.T) {
+	require.NoError(t, logger.Init())
+	repo := storage.NewInMemoryStorage()
+	identity := dto.AgentIdentity{
+		Started:        time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		ID:             "web-1",
+		Hostname:       "web-host",
+		Version:        "v1.0.0",
+		ReportInterval: 10,
+	}
+
+	tests := []struct {
+		headers map[string]string
+		name    string
+		status  int
+	}{
+		{name: "identified agent case", headers: identity.Headers(), status: http.StatusOK},
+		{name: "anonymous client case", status: http.StatusOK},
+		{
+			name:    "invalid identity case",
+			headers: map[string]string{dto.AgentIDHeader: "web-2", dto.AgentStartedHeader: "yesterday"},
+			status:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/updates",
+				strings.NewReader(`[{"id":"Alloc","type":"gauge","value":1.5},{"id":"PollCount","type":"counter","delta":1}]`))
+			for name, value := range tt.headers {
+				request.Header.Set(name, value)
+			}
+			recorder := httptest.NewRecorder()
+			BulkUpdate(repo, service.NewAgentRegistry(repo, 0)).ServeHTTP(recorder, request)
+			response := recorder.Result()
+			require.NoError(t, response.Body.Close())
+			assert.Equal(t, tt.status, response.StatusCode)
+		})
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/api/agents", nil)
+	recorder := httptest.NewRecorder()
+	AgentsHandler(repo, &config.ServerConfig{StaleIntervals: 3}).ServeHTTP(recorder, request)
+	response := recorder.Result()
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	var agents []dto.AgentInfo
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&agents))
+	require.Len(t, agents, 1)
+	assert.Equal(t, "web-1", agents[0].ID)
+	assert.Equal(t, "web-host", agents[0].Hostname)
+	assert.Equal(t, "v1.0.0", agents[0].Version)
+	assert.Equal(t, identity.Started, agents[0].StartedAt)
+	assert.Equal(t, 2, agents[0].MetricCount)
+	assert.Equal(t, uint(10), agents[0].ReportInterval)
+	assert.False(t, agents[0].Stale)
+	assert.WithinDuration(t, time.Now(), agents[0].LastSeen, time.Second)
+}
@@ -10,10 +10,12 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/ilya372317/must-have-metrics/internal/config"
 	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/ilya372317/must-have-metrics/internal/server/service"
 	"github.com/ilya372317/must-have-metrics/internal/storage"
 )
 
@@ -193,7 +195,7 @@ func ExampleBulkUpdate() {
 	)
 	w := httptest.NewRecorder()
 
-	handler := BulkUpdate(memStrg)
+	handler := BulkUpdate(memStrg, service.NewAgentRegistry(memStrg, time.Second))
 	handler.ServeHTTP(w, r)
 
 	res := w.Result()
@@ -36,6 +36,7 @@ func UpdateJSONHandler(storage updateJSONStorage, serverConfig *config.ServerCon
 			http.Error(writer, validErr.Error(), http.StatusBadRequest)
 			return
 		}
+		metrics.FlattenLabels()
 		newAlert, err := service.AddAlert(request.Context(), storage, metrics, serverConfig)
 		if err != nil {
 			http.Error(writer, err.Error(), http.StatusInternalServerError)
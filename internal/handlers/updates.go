@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -22,20 +23,29 @@ type bulkUpdateStorage interface {
 }
 
 // BulkUpdate allow to update multiply metrics by request in json format.
-func BulkUpdate(storage bulkUpdateStorage) http.HandlerFunc {
+// Agent, which identified itself in request headers, is registered as seen in given registry.
+func BulkUpdate(storage bulkUpdateStorage, registry *service.AgentRegistry) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		writer.Header().Set("content-type", "application/json")
+		identity, err := dto.NewAgentIdentityFromRequest(request)
+		if err != nil && !errors.Is(err, dto.ErrNoAgentIdentity) {
+			http.Error(writer, fmt.Sprintf("invalid agent identity: %v", err), http.StatusBadRequest)
+			return
+		}
 		metricsList, err := dto.NewMetricsListDTOFromRequest(request)
 		if err != nil {
 			http.Error(writer, fmt.Sprintf("failed create metricsList dto: %v", err), http.StatusBadRequest)
 			return
 		}
 
-		for _, metric := range metricsList {
+		series := make([]string, 0, len(metricsList))
+		for i, metric := range metricsList {
 			ok, err := metric.Validate()
 			if !ok {
 				http.Error(writer, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
 			}
+			metricsList[i].FlattenLabels()
+			series = append(series, metricsList[i].ID)
 		}
 
 		alerts, err := service.BulkAddAlerts(request.Context(), storage, metricsList)
@@ -44,6 +54,11 @@ func BulkUpdate(storage bulkUpdateStorage) http.HandlerFunc {
 			http.Error(writer, fmt.Sprintf("failed insert metrics: %v", err), http.StatusInternalServerError)
 			return
 		}
+		if identity != nil {
+			if err = registry.Register(request.Context(), *identity, series); err != nil {
+				logger.Log.Warn(err)
+			}
+		}
 
 		responseMetricsList := make([]dto.Metrics, 0, len(alerts))
 		for _, alert := range alerts {
@@ -0,0 +1,239 @@
+// Package promtext implements Prometheus text exposition format.
+package promtext
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Types of metrics families.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+	TypeSummary   = "summary"
+	TypeUntyped   = "untyped"
+)
+
+// Suffixes of histogram and summary samples.
+const (
+	SuffixBucket = "_bucket"
+	SuffixSum    = "_sum"
+	SuffixCount  = "_count"
+)
+
+const typeCommentFieldsCount = 4
+
+var errInvalidSample = errors.New("invalid sample")
+
+// Family group of samples with the same metric name and type.
+type Family struct {
+	Name    string
+	Type    string
+	Samples []Sample
+}
+
+// Sample single value of metric.
+type Sample struct {
+	Labels map[string]string
+	Name   string
+	Value  float64
+}
+
+// Parse read families from text exposition format. Families are returned in order of their first appearance.
+func Parse(r io.Reader) ([]Family, error) {
+	families := make([]*Family, 0)
+	byName := make(map[string]*Family)
+	getFamily := func(name, typ string) *Family {
+		family, ok := byName[name]
+		if !ok {
+			family = &Family{Name: name, Type: typ}
+			byName[name] = family
+			families = append(families, family)
+		}
+		return family
+	}
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) == typeCommentFieldsCount && fields[1] == "TYPE" {
+				getFamily(fields[2], fields[3]).Type = fields[3]
+			}
+			continue
+		}
+
+		sample, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		familyName := sample.Name
+		for _, suffix := range []string{SuffixBucket, SuffixSum, SuffixCount} {
+			baseName := strings.TrimSuffix(sample.Name, suffix)
+			if family, ok := byName[baseName]; ok && baseName != sample.Name &&
+				(family.Type == TypeHistogram || family.Type == TypeSummary) {
+				familyName = baseName
+				break
+			}
+		}
+		family := getFamily(familyName, TypeUntyped)
+		family.Samples = append(family.Samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed read exposition: %w", err)
+	}
+
+	result := make([]Family, 0, len(families))
+	for _, family := range families {
+		result = append(result, *family)
+	}
+	return result, nil
+}
+
+// SeriesID build unique identifier of sample in form name{label="value",...} with sorted labels.
+func SeriesID(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	builder := strings.Builder{}
+	builder.WriteString(name)
+	builder.WriteString("{")
+	for i, key := range keys {
+		if i > 0 {
+			builder.WriteString(",")
+		}
+		builder.WriteString(key)
+		builder.WriteString("=")
+		builder.WriteString(strconv.Quote(labels[key]))
+	}
+	builder.WriteString("}")
+	return builder.String()
+}
+
+// ParseSeriesID split identifier built by SeriesID into name and labels.
+func ParseSeriesID(id string) (string, map[string]string, error) {
+	nameEnd := strings.IndexByte(id, '{')
+	if nameEnd < 0 {
+		return id, nil, nil
+	}
+	labels, consumed, err := parseLabels(id[nameEnd:])
+	if err != nil {
+		return "", nil, err
+	}
+	if nameEnd+consumed != len(id) {
+		return "", nil, fmt.Errorf("%w: unexpected text after labels of %s", errInvalidSample, id)
+	}
+	return id[:nameEnd], labels, nil
+}
+
+func parseSample(line string) (Sample, error) {
+	sample := Sample{}
+	nameEnd := strings.IndexAny(line, "{ \t")
+	if nameEnd <= 0 {
+		return sample, fmt.Errorf("%w: missing value", errInvalidSample)
+	}
+	sample.Name = line[:nameEnd]
+	rest := line[nameEnd:]
+
+	if strings.HasPrefix(rest, "{") {
+		labels, consumed, err := parseLabels(rest)
+		if err != nil {
+			return sample, err
+		}
+		sample.Labels = labels
+		rest = rest[consumed:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return sample, fmt.Errorf("%w: missing value of %s", errInvalidSample, sample.Name)
+	}
+	value, err := parseValue(fields[0])
+	if err != nil {
+		return sample, fmt.Errorf("%w: value of %s: %w", errInvalidSample, sample.Name, err)
+	}
+	sample.Value = value
+
+	return sample, nil
+}
+
+// parseLabels parse labels block started with "{". Returns labels and count of consumed bytes.
+func parseLabels(input string) (map[string]string, int, error) {
+	labels := make(map[string]string)
+	i := 1
+	for {
+		for i < len(input) && (input[i] == ' ' || input[i] == ',') {
+			i++
+		}
+		if i >= len(input) {
+			return nil, 0, fmt.Errorf("%w: unclosed labels", errInvalidSample)
+		}
+		if input[i] == '}' {
+			return labels, i + 1, nil
+		}
+
+		eq := strings.IndexByte(input[i:], '=')
+		if eq <= 0 {
+			return nil, 0, fmt.Errorf("%w: invalid label", errInvalidSample)
+		}
+		key := strings.TrimSpace(input[i : i+eq])
+		i += eq + 1
+		if i >= len(input) || input[i] != '"' {
+			return nil, 0, fmt.Errorf("%w: label %s value is not quoted", errInvalidSample, key)
+		}
+		i++
+
+		value := strings.Builder{}
+		for ; i < len(input) && input[i] != '"'; i++ {
+			if input[i] == '\\' && i+1 < len(input) {
+				i++
+				switch input[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(input[i])
+				}
+				continue
+			}
+			value.WriteByte(input[i])
+		}
+		if i >= len(input) {
+			return nil, 0, fmt.Errorf("%w: unclosed label %s value", errInvalidSample, key)
+		}
+		i++
+		labels[key] = value.String()
+	}
+}
+
+func parseValue(raw string) (float64, error) {
+	switch raw {
+	case "+Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed parse float: %w", err)
+	}
+	return value, nil
+}
@@ -0,0 +1,178 @@
+package promtext
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Family
+		wantErr bool
+	}{
+		{
+			name: "counter and gauge case",
+			input: `# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="get",code="400"} 3
+# TYPE queue_depth gauge
+queue_depth -4.5
+`,
+			want: []Family{
+				{
+					Name: "http_requests_total",
+					Type: TypeCounter,
+					Samples: []Sample{
+						{Name: "http_requests_total", Labels: map[string]string{"method": "post", "code": "200"}, Value: 1027},
+						{Name: "http_requests_total", Labels: map[string]string{"method": "get", "code": "400"}, Value: 3},
+					},
+				},
+				{
+					Name:    "queue_depth",
+					Type:    TypeGauge,
+					Samples: []Sample{{Name: "queue_depth", Value: -4.5}},
+				},
+			},
+		},
+		{
+			name: "histogram case",
+			input: `# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 5
+latency_seconds_bucket{le="+Inf"} 7
+latency_seconds_sum 1.25
+latency_seconds_count 7
+`,
+			want: []Family{
+				{
+					Name: "latency_seconds",
+					Type: TypeHistogram,
+					Samples: []Sample{
+						{Name: "latency_seconds_bucket", Labels: map[string]string{"le": "0.1"}, Value: 5},
+						{Name: "latency_seconds_bucket", Labels: map[string]string{"le": "+Inf"}, Value: 7},
+						{Name: "latency_seconds_sum", Value: 1.25},
+						{Name: "latency_seconds_count", Value: 7},
+					},
+				},
+			},
+		},
+		{
+			name:  "untyped with escaped label case",
+			input: `build_info{version="1.0 \"beta\"",path="C:\\app"} 1`,
+			want: []Family{
+				{
+					Name: "build_info",
+					Type: TypeUntyped,
+					Samples: []Sample{
+						{Name: "build_info", Labels: map[string]string{"version": `1.0 "beta"`, "path": `C:\app`}, Value: 1},
+					},
+				},
+			},
+		},
+		{
+			name:    "missing value case",
+			input:   "queue_depth\n",
+			wantErr: true,
+		},
+		{
+			name:    "unclosed labels case",
+			input:   `queue_depth{queue="a" 1`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid value case",
+			input:   "queue_depth abc\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.input))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParse_specialValues(t *testing.T) {
+	families, err := Parse(strings.NewReader("a +Inf\nb -Inf\nc NaN\n"))
+	require.NoError(t, err)
+	require.Len(t, families, 3)
+	assert.True(t, math.IsInf(families[0].Samples[0].Value, 1))
+	assert.True(t, math.IsInf(families[1].Samples[0].Value, -1))
+	assert.True(t, math.IsNaN(families[2].Samples[0].Value))
+}
+
+func TestSeriesID(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric string
+		labels map[string]string
+		want   string
+	}{
+		{
+			name:   "without labels",
+			metric: "queue_depth",
+			want:   "queue_depth",
+		},
+		{
+			name:   "sorted labels",
+			metric: "http_requests_total",
+			labels: map[string]string{"method": "get", "code": "200"},
+			want:   `http_requests_total{code="200",method="get"}`,
+		},
+		{
+			name:   "escaped label value",
+			metric: "build_info",
+			labels: map[string]string{"version": `1.0 "beta"`},
+			want:   `build_info{version="1.0 \"beta\""}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SeriesID(tt.metric, tt.labels))
+		})
+	}
+}
+
+func TestParseSeriesID(t *testing.T) {
+	tests := []struct {
+		labels  map[string]string
+		name    string
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{name: "without labels", id: "queue_depth", want: "queue_depth"},
+		{
+			name:   "with labels",
+			id:     `http_requests_total{code="200",method="get"}`,
+			want:   "http_requests_total",
+			labels: map[string]string{"method": "get", "code": "200"},
+		},
+		{name: "unclosed labels", id: `build_info{version="1.0"`, wantErr: true},
+		{name: "text after labels", id: `build_info{version="1.0"}.total`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, labels, err := ParseSeriesID(tt.id)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.labels, labels)
+		})
+	}
+}
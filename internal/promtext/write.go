@@ -0,0 +1,62 @@
+package promtext
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Write write families in text exposition format. Empty sample name means name of family.
+func Write(w io.Writer, families []Family) error {
+	buffer := bufio.NewWriter(w)
+	for _, family := range families {
+		if _, err := fmt.Fprintf(buffer, "# TYPE %s %s\n", family.Name, family.Type); err != nil {
+			return fmt.Errorf("failed write family %s: %w", family.Name, err)
+		}
+		for _, sample := range family.Samples {
+			name := sample.Name
+			if name == "" {
+				name = family.Name
+			}
+			if _, err := fmt.Fprintf(buffer, "%s %s\n", SeriesID(name, sample.Labels), formatValue(sample.Value)); err != nil {
+				return fmt.Errorf("failed write sample %s: %w", name, err)
+			}
+		}
+	}
+	if err := buffer.Flush(); err != nil {
+		return fmt.Errorf("failed write families: %w", err)
+	}
+	return nil
+}
+
+// SanitizeName replace characters, which are not allowed in metric name, with underscores.
+func SanitizeName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	sanitized := []byte(name)
+	for i, c := range sanitized {
+		isLetter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':'
+		if !isLetter && (c < '0' || c > '9') {
+			sanitized[i] = '_'
+		}
+	}
+	if sanitized[0] >= '0' && sanitized[0] <= '9' {
+		return "_" + string(sanitized)
+	}
+	return string(sanitized)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
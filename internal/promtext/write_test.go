@@ -0,0 +1,48 @@
+package promtext
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	families := []Family{
+		{Name: "alloc", Type: TypeGauge, Samples: []Sample{{Value: 1.5, Labels: map[string]string{"host": "web-1"}}}},
+		{Name: "requests", Type: TypeCounter, Samples: []Sample{{Name: "requests", Value: 3}}},
+		{Name: "temperature", Type: TypeGauge, Samples: []Sample{{Value: math.Inf(-1)}}},
+	}
+	builder := strings.Builder{}
+	require.NoError(t, Write(&builder, families))
+	assert.Equal(t, "# TYPE alloc gauge\n"+
+		"alloc{host=\"web-1\"} 1.5\n"+
+		"# TYPE requests counter\n"+
+		"requests 3\n"+
+		"# TYPE temperature gauge\n"+
+		"temperature -Inf\n", builder.String())
+
+	parsed, err := Parse(strings.NewReader(builder.String()))
+	require.NoError(t, err)
+	assert.Len(t, parsed, len(families), "written text is parsed back")
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "valid name", in: "process_cpu_seconds_total", want: "process_cpu_seconds_total"},
+		{name: "dots and dashes", in: "web-1.requests", want: "web_1_requests"},
+		{name: "leading digit", in: "1m_load", want: "_1m_load"},
+		{name: "empty name", in: "", want: "_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SanitizeName(tt.in))
+		})
+	}
+}
@@ -4,14 +4,19 @@ import (
 	"context"
 	"net/http"
 	"net/http/pprof"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/ilya372317/must-have-metrics/internal/config"
 	"github.com/ilya372317/must-have-metrics/internal/handlers"
 	"github.com/ilya372317/must-have-metrics/internal/server/entity"
 	"github.com/ilya372317/must-have-metrics/internal/server/middleware"
+	"github.com/ilya372317/must-have-metrics/internal/server/service"
 )
 
+// agentRegisterInterval minimal interval between saves of same agent into registry.
+const agentRegisterInterval = time.Second
+
 // AlertStorage interface with all storage methods. Different handler will use different methods from here.
 type AlertStorage interface {
 	Save(ctx context.Context, name string, alert entity.Alert) error
@@ -23,6 +28,8 @@ type AlertStorage interface {
 	Fill(context.Context, map[string]entity.Alert) error
 	GetByIDs(ctx context.Context, ids []string) ([]entity.Alert, error)
 	BulkInsertOrUpdate(ctx context.Context, alerts []entity.Alert) error
+	SaveAgent(ctx context.Context, agent entity.Agent) error
+	AllAgents(ctx context.Context) ([]entity.Agent, error)
 	Ping() error
 }
 
@@ -42,8 +49,15 @@ func AlertRouter(repository AlertStorage, serverConfig *config.ServerConfig) *ch
 	})
 	router.Route("/updates", func(r chi.Router) {
 		r.Use(middleware.WithSign(serverConfig))
-		r.Post("/", handlers.BulkUpdate(repository))
+		r.Post("/", handlers.BulkUpdate(repository, service.NewAgentRegistry(repository, agentRegisterInterval)))
 	})
+	if serverConfig.ShouldServeAgentConfig() {
+		router.Route("/agent/config", func(r chi.Router) {
+			r.Use(middleware.WithQuerySign(serverConfig))
+			r.Get("/", handlers.AgentConfigHandler(serverConfig))
+		})
+	}
+	router.Get("/api/agents", handlers.AgentsHandler(repository, serverConfig))
 	router.Route("/value", func(r chi.Router) {
 		r.Post("/", handlers.ShowJSONHandler(repository))
 	})
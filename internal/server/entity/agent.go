@@ -0,0 +1,35 @@
+package entity
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// Agent entity representing agent, which sends metrics to server.
+type Agent struct {
+	StartedAt      time.Time `json:"started_at"`
+	LastSeen       time.Time `json:"last_seen"`
+	ID             string    `json:"id"`
+	Hostname       string    `json:"hostname"`
+	Version        string    `json:"version"`
+	Series         []uint64  `json:"-"`
+	MetricCount    int       `json:"metric_count"`
+	ReportInterval uint      `json:"report_interval"`
+}
+
+// SeriesHash return hash of metric series id, by which distinct series of agent are counted.
+func SeriesHash(id string) uint64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(id))
+	return hash.Sum64()
+}
+
+// IsStale check if agent missed more than given count of report intervals.
+// Agent with unknown report interval or zero count is never stale.
+func (a *Agent) IsStale(now time.Time, staleIntervals uint) bool {
+	if a.ReportInterval == 0 || staleIntervals == 0 {
+		return false
+	}
+	missed := time.Duration(a.ReportInterval*staleIntervals) * time.Second
+	return now.Sub(a.LastSeen) > missed
+}
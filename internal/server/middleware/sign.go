@@ -6,12 +6,20 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/ilya372317/must-have-metrics/internal/config"
 	"github.com/ilya372317/must-have-metrics/internal/logger"
 	"github.com/ilya372317/must-have-metrics/internal/signature"
 )
 
+// TimestampParam query parameter with unix time of request, which is signed along with other parameters.
+const TimestampParam = "timestamp"
+
+// maxQuerySignAge max difference between timestamp of signed query and server time.
+const maxQuerySignAge = 5 * time.Minute
+
 type signWriter struct {
 	http.ResponseWriter
 	serverConfig *config.ServerConfig
@@ -73,3 +81,31 @@ func WithSign(serverConfig *config.ServerConfig) Middleware {
 		})
 	}
 }
+
+// WithQuerySign check sign of query string for requests without body and add sign to response.
+// Query must contain timestamp parameter close to server time, so captured request can not be replayed later.
+func WithQuerySign(serverConfig *config.ServerConfig) Middleware {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if !serverConfig.ShouldSignData() {
+				handler.ServeHTTP(writer, request)
+				return
+			}
+			sign := signature.CreateSign([]byte(request.URL.RawQuery), serverConfig.SecretKey)
+			if request.Header.Get("HashSHA256") != base64.StdEncoding.EncodeToString(sign) {
+				http.Error(writer, "invalid sign", http.StatusBadRequest)
+				return
+			}
+			timestamp, err := strconv.ParseInt(request.URL.Query().Get(TimestampParam), 10, 64)
+			if err != nil {
+				http.Error(writer, "invalid timestamp of signed request", http.StatusBadRequest)
+				return
+			}
+			if age := time.Since(time.Unix(timestamp, 0)); age > maxQuerySignAge || age < -maxQuerySignAge {
+				http.Error(writer, "signed request is expired", http.StatusBadRequest)
+				return
+			}
+			handler.ServeHTTP(signWriter{ResponseWriter: writer, serverConfig: serverConfig}, request)
+		})
+	}
+}
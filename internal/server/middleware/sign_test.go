@@ -5,7 +5,9 @@ import (
 	"encoding/base64"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/ilya372317/must-have-metrics/internal/config"
 	"github.com/ilya372317/must-have-metrics/internal/signature"
@@ -67,3 +69,60 @@ func Test_isCorrectSigned(t *testing.T) {
 		})
 	}
 }
+
+func TestWithQuerySign(t *testing.T) {
+	const secretKey = "secret"
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	expired := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	tests := []struct {
+		name          string
+		query         string
+		signedQuery   string
+		wantStatus    int
+		wantSignature bool
+	}{
+		{
+			name:          "correct sign case",
+			query:         "agent_id=web-1&timestamp=" + now,
+			signedQuery:   "agent_id=web-1&timestamp=" + now,
+			wantStatus:    http.StatusOK,
+			wantSignature: true,
+		},
+		{
+			name:        "replayed for other agent case",
+			query:       "agent_id=web-2&timestamp=" + now,
+			signedQuery: "agent_id=web-1&timestamp=" + now,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "expired sign case",
+			query:       "agent_id=web-1&timestamp=" + expired,
+			signedQuery: "agent_id=web-1&timestamp=" + expired,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "missing timestamp case",
+			query:       "agent_id=web-1",
+			signedQuery: "agent_id=web-1",
+			wantStatus:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := WithQuerySign(&config.ServerConfig{SecretKey: secretKey})(
+				http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+					_, _ = writer.Write([]byte(`{}`))
+				}))
+			request := httptest.NewRequest(http.MethodGet, "/agent/config?"+tt.query, nil)
+			sign := signature.CreateSign([]byte(tt.signedQuery), secretKey)
+			request.Header.Set("HashSHA256", base64.StdEncoding.EncodeToString(sign))
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			response := recorder.Result()
+			require.NoError(t, response.Body.Close())
+			assert.Equal(t, tt.wantStatus, response.StatusCode)
+			assert.Equal(t, tt.wantSignature, response.Header.Get("HashSHA256") != "")
+		})
+	}
+}
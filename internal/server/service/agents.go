@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/promtext"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+// StaleMetricName name of synthetic gauge, which is 1 for stale agent and 0 otherwise.
+const StaleMetricName = "agent_stale"
+
+const (
+	minStaleCheckInterval     = time.Second
+	defaultStaleCheckInterval = 10 * time.Second
+)
+
+type agentStorage interface {
+	SaveAgent(ctx context.Context, agent entity.Agent) error
+	AllAgents(ctx context.Context) ([]entity.Agent, error)
+}
+
+type agentRegisterStorage interface {
+	SaveAgent(ctx context.Context, agent entity.Agent) error
+}
+
+type staleStorage interface {
+	AllAgents(ctx context.Context) ([]entity.Agent, error)
+	BulkInsertOrUpdate(ctx context.Context, alerts []entity.Alert) error
+}
+
+// AgentRegistry registers agents, which send metrics, in storage. Agent is saved not more often than
+// once per interval, so storage is not written on every batch. Hashes of series of batches received
+// in between are collected and saved with next save of agent.
+type AgentRegistry struct {
+	storage  agentRegisterStorage
+	agents   map[string]*pendingAgent
+	interval time.Duration
+	mu       sync.Mutex
+}
+
+type pendingAgent struct {
+	savedAt time.Time
+	started time.Time
+	series  map[uint64]struct{}
+}
+
+// NewAgentRegistry constructor for AgentRegistry.
+func NewAgentRegistry(storage agentRegisterStorage, interval time.Duration) *AgentRegistry {
+	return &AgentRegistry{
+		storage:  storage,
+		agents:   make(map[string]*pendingAgent),
+		interval: interval,
+	}
+}
+
+// Register mark agent, which sent batch with given metric series, as seen now.
+// Storage counts distinct series of agent across all batches of its run.
+func (r *AgentRegistry) Register(ctx context.Context, identity dto.AgentIdentity, series []string) error {
+	now := time.Now()
+	r.mu.Lock()
+	pending, ok := r.agents[identity.ID]
+	if !ok || !pending.started.Equal(identity.Started) {
+		pending = &pendingAgent{started: identity.Started, series: make(map[uint64]struct{}, len(series))}
+		r.agents[identity.ID] = pending
+	}
+	for _, id := range series {
+		pending.series[entity.SeriesHash(id)] = struct{}{}
+	}
+	if now.Sub(pending.savedAt) < r.interval {
+		r.mu.Unlock()
+		return nil
+	}
+	hashes := make([]uint64, 0, len(pending.series))
+	for hash := range pending.series {
+		hashes = append(hashes, hash)
+	}
+	pending.series = make(map[uint64]struct{}, len(hashes))
+	pending.savedAt = now
+	r.mu.Unlock()
+
+	if err := r.storage.SaveAgent(ctx, identity.ConvertToAgent(now, hashes)); err != nil {
+		r.mu.Lock()
+		for _, hash := range hashes {
+			pending.series[hash] = struct{}{}
+		}
+		pending.savedAt = time.Time{}
+		r.mu.Unlock()
+		return fmt.Errorf("failed register agent %s: %w", identity.ID, err)
+	}
+	return nil
+}
+
+// StaleTracker keeps staleness metrics of registered agents. Metric is written only when staleness
+// of agent is changed or agent is seen first time, so storage is not updated on every check.
+type StaleTracker struct {
+	storage        staleStorage
+	written        map[string]bool
+	staleIntervals uint
+}
+
+// NewStaleTracker constructor for StaleTracker.
+func NewStaleTracker(storage staleStorage, staleIntervals uint) *StaleTracker {
+	return &StaleTracker{
+		storage:        storage,
+		written:        make(map[string]bool),
+		staleIntervals: staleIntervals,
+	}
+}
+
+// Update save changed staleness metrics of agents. Returns interval of next check,
+// which is the shortest report interval of agents.
+func (t *StaleTracker) Update(ctx context.Context, now time.Time) (time.Duration, error) {
+	agents, err := t.storage.AllAgents(ctx)
+	if err != nil {
+		return defaultStaleCheckInterval, fmt.Errorf("failed get agents: %w", err)
+	}
+	next := time.Duration(0)
+	alerts := make([]entity.Alert, 0)
+	stale := make(map[string]bool, len(agents))
+	for _, agent := range agents {
+		if interval := time.Duration(agent.ReportInterval) * time.Second; interval > 0 && (next == 0 || interval < next) {
+			next = interval
+		}
+		stale[agent.ID] = agent.IsStale(now, t.staleIntervals)
+		if written, ok := t.written[agent.ID]; ok && written == stale[agent.ID] {
+			continue
+		}
+		var value float64
+		if stale[agent.ID] {
+			value = 1
+		}
+		name := promtext.SeriesID(StaleMetricName, map[string]string{"agent_id": agent.ID})
+		alerts = append(alerts, entity.MakeGaugeAlert(name, value))
+	}
+	if next < minStaleCheckInterval {
+		next = defaultStaleCheckInterval
+	}
+	if len(alerts) == 0 {
+		return next, nil
+	}
+	if err = t.storage.BulkInsertOrUpdate(ctx, alerts); err != nil {
+		return next, fmt.Errorf("failed save staleness of agents: %w", err)
+	}
+	t.written = stale
+	return next, nil
+}
+
+// Run periodically update staleness metrics of agents until context is done.
+func (t *StaleTracker) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-timer.C:
+			next, err := t.Update(ctx, now)
+			if err != nil {
+				logger.Log.Warnf("failed update staleness of agents: %v", err)
+			}
+			timer.Reset(next)
+		}
+	}
+}
+
+// AgentsFilePath return path of file, where agents are stored next to metrics file.
+func AgentsFilePath(metricsFilePath string) string {
+	ext := filepath.Ext(metricsFilePath)
+	return strings.TrimSuffix(metricsFilePath, ext) + ".agents" + ext
+}
+
+// StoreAgentsToFilesystem save all registered agents to filesystem.
+func StoreAgentsToFilesystem(ctx context.Context, storage agentStorage, filePath string) error {
+	agents, err := storage.AllAgents(ctx)
+	if err != nil {
+		return fmt.Errorf("failed get agents: %w", err)
+	}
+	data, err := json.Marshal(agents)
+	if err != nil {
+		return fmt.Errorf("failed serialize agents: %w", err)
+	}
+	if err = os.WriteFile(filePath, data, filePermission); err != nil {
+		return fmt.Errorf("failed save agents on disk: %w", err)
+	}
+	return nil
+}
+
+// FillAgentsFromFilesystem save agents from filesystem to storage. Missing file is not an error.
+func FillAgentsFromFilesystem(ctx context.Context, storage agentStorage, filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed read agents from file system: %w", err)
+	}
+	var agents []entity.Agent
+	if err = json.Unmarshal(data, &agents); err != nil {
+		return fmt.Errorf("agents in file is invalid: %w", err)
+	}
+	for _, agent := range agents {
+		if err = storage.SaveAgent(ctx, agent); err != nil {
+			return fmt.Errorf("failed restore agent %s: %w", agent.ID, err)
+		}
+	}
+	return nil
+}
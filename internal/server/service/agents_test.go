@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+	"github.com/ilya372317/must-have-metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingStorage struct {
+	*storage.InMemoryStorage
+	writes int
+}
+
+func (s *countingStorage) BulkInsertOrUpdate(ctx context.Context, alerts []entity.Alert) error {
+	s.writes++
+	return s.InMemoryStorage.BulkInsertOrUpdate(ctx, alerts) //nolint:wrapcheck
+}
+
+func TestStaleTracker_Update(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		lastSeen  time.Time
+		interval  uint
+		wantStale float64
+		wantNext  time.Duration
+	}{
+		{
+			name:      "fresh agent case",
+			lastSeen:  now.Add(-25 * time.Second),
+			interval:  10,
+			wantStale: 0,
+			wantNext:  10 * time.Second,
+		},
+		{
+			name:      "stale agent case",
+			lastSeen:  now.Add(-31 * time.Second),
+			interval:  10,
+			wantStale: 1,
+			wantNext:  10 * time.Second,
+		},
+		{
+			name:      "unknown report interval case",
+			lastSeen:  now.Add(-time.Hour),
+			wantStale: 0,
+			wantNext:  defaultStaleCheckInterval,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := storage.NewInMemoryStorage()
+			ctx := context.Background()
+			agent := entity.Agent{ID: "web-1", LastSeen: tt.lastSeen, ReportInterval: tt.interval}
+			require.NoError(t, repo.SaveAgent(ctx, agent))
+
+			next, err := NewStaleTracker(repo, 3).Update(ctx, now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantNext, next)
+			alert, err := repo.Get(ctx, `agent_stale{agent_id="web-1"}`)
+			require.NoError(t, err)
+			assert.Equal(t, entity.TypeGauge, alert.Type)
+			assert.Equal(t, tt.wantStale, *alert.FloatValue)
+		})
+	}
+}
+
+func TestStaleTracker_UpdateOnlyChanged(t *testing.T) {
+	repo := &countingStorage{InMemoryStorage: storage.NewInMemoryStorage()}
+	ctx := context.Background()
+	now := time.Now()
+	require.NoError(t, repo.SaveAgent(ctx, entity.Agent{ID: "web-1", LastSeen: now, ReportInterval: 10}))
+	tracker := NewStaleTracker(repo, 3)
+
+	_, err := tracker.Update(ctx, now)
+	require.NoError(t, err)
+	_, err = tracker.Update(ctx, now.Add(10*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.writes, "unchanged staleness is not written")
+
+	_, err = tracker.Update(ctx, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 2, repo.writes)
+	alert, err := repo.Get(ctx, `agent_stale{agent_id="web-1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), *alert.FloatValue)
+}
+
+func TestAgentRegistry_Register(t *testing.T) {
+	repo := storage.NewInMemoryStorage()
+	registry := NewAgentRegistry(repo, 0)
+	ctx := context.Background()
+	identity := dto.AgentIdentity{ID: "web-1", Hostname: "web-host", Version: "v1.0.0", ReportInterval: 10}
+	require.NoError(t, registry.Register(ctx, identity, []string{"Alloc", "PollCount", "RandomValue"}))
+	require.NoError(t, registry.Register(ctx, identity, []string{"PollCount", "TotalMemory"}))
+
+	agents, err := repo.AllAgents(ctx)
+	require.NoError(t, err)
+	require.Len(t, agents, 1)
+	assert.Equal(t, "web-host", agents[0].Hostname)
+	assert.Equal(t, 4, agents[0].MetricCount, "distinct series of all batches are counted")
+	assert.WithinDuration(t, time.Now(), agents[0].LastSeen, time.Second)
+
+	identity.Started = time.Now()
+	require.NoError(t, registry.Register(ctx, identity, []string{"Alloc"}))
+	agents, err = repo.AllAgents(ctx)
+	require.NoError(t, err)
+	require.Len(t, agents, 1)
+	assert.Equal(t, 1, agents[0].MetricCount, "series of previous agent run are not counted")
+}
+
+type failingAgentStorage struct {
+	*storage.InMemoryStorage
+	fail  bool
+	saves int
+}
+
+func (s *failingAgentStorage) SaveAgent(ctx context.Context, agent entity.Agent) error {
+	s.saves++
+	if s.fail {
+		return errors.New("storage is unavailable")
+	}
+	return s.InMemoryStorage.SaveAgent(ctx, agent) //nolint:wrapcheck
+}
+
+func TestAgentRegistry_RegisterThrottled(t *testing.T) {
+	repo := &failingAgentStorage{InMemoryStorage: storage.NewInMemoryStorage(), fail: true}
+	registry := NewAgentRegistry(repo, time.Hour)
+	ctx := context.Background()
+	identity := dto.AgentIdentity{ID: "web-1"}
+
+	require.Error(t, registry.Register(ctx, identity, []string{"Alloc"}))
+	repo.fail = false
+	require.NoError(t, registry.Register(ctx, identity, []string{"PollCount"}),
+		"agent is saved again after failed save")
+	require.NoError(t, registry.Register(ctx, identity, []string{"RandomValue"}))
+	assert.Equal(t, 2, repo.saves, "agent is not saved more often than interval")
+
+	agents, err := repo.AllAgents(ctx)
+	require.NoError(t, err)
+	require.Len(t, agents, 1)
+	assert.Equal(t, 2, agents[0].MetricCount, "series of failed save are saved with next one")
+}
+
+func TestStoreAndFillAgentsFromFilesystem(t *testing.T) {
+	filePath := AgentsFilePath(filepath.Join(t.TempDir(), "metrics.json"))
+	ctx := context.Background()
+
+	require.NoError(t, FillAgentsFromFilesystem(ctx, storage.NewInMemoryStorage(), filePath),
+		"missing file is not an error")
+
+	agent := entity.Agent{
+		StartedAt:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		LastSeen:       time.Date(2024, 1, 2, 4, 4, 5, 0, time.UTC),
+		ID:             "web-1",
+		Hostname:       "web-host",
+		Version:        "v1.0.0",
+		MetricCount:    2,
+		ReportInterval: 10,
+	}
+	repo := storage.NewInMemoryStorage()
+	require.NoError(t, repo.SaveAgent(ctx, agent))
+	require.NoError(t, StoreAgentsToFilesystem(ctx, repo, filePath))
+
+	restored := storage.NewInMemoryStorage()
+	require.NoError(t, FillAgentsFromFilesystem(ctx, restored, filePath))
+	agents, err := restored.AllAgents(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Agent{agent}, agents)
+}
+
+func TestAgentsFilePath(t *testing.T) {
+	assert.Equal(t, "/tmp/metrics-db.agents.json", AgentsFilePath("/tmp/metrics-db.json"))
+	assert.Equal(t, "/tmp/metrics.agents", AgentsFilePath("/tmp/metrics"))
+}
@@ -21,11 +21,16 @@ type filesystemSupportStorage interface {
 	Fill(context.Context, map[string]entity.Alert) error
 }
 
-// SaveDataToFilesystemByInterval by configured interval saving data from storage to filesystem.
+type snapshotStorage interface {
+	filesystemSupportStorage
+	agentStorage
+}
+
+// SaveDataToFilesystemByInterval by configured interval saving data and registered agents from storage to filesystem.
 func SaveDataToFilesystemByInterval(
 	ctx context.Context,
 	wg *sync.WaitGroup,
-	serverConfig *config.ServerConfig, repository filesystemSupportStorage) {
+	serverConfig *config.ServerConfig, repository snapshotStorage) {
 	defer wg.Done()
 	ticker := time.NewTicker(time.Duration(serverConfig.StoreInterval) * time.Second)
 	defer ticker.Stop()
@@ -40,6 +45,10 @@ func SaveDataToFilesystemByInterval(
 				logger.Log.Errorf("failed save data to filesystem: %v", err)
 				return
 			}
+			err = StoreAgentsToFilesystem(ctx, repository, AgentsFilePath(serverConfig.FilePath))
+			if err != nil {
+				logger.Log.Errorf("failed save agents to filesystem: %v", err)
+			}
 		}
 	}
 }
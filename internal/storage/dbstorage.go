@@ -333,6 +333,110 @@ func (d *DatabaseStorage) GetByIDs(ctx context.Context, ids []string) ([]entity.
 	return alerts, nil
 }
 
+// SaveAgent insert agent into registry in database or update existing one. Hashes of series of agent
+// are added to hashes saved by same agent run in one statement, which also increases count of distinct
+// series by number of new hashes. Series of previous runs are removed and count is reset.
+func (d *DatabaseStorage) SaveAgent(ctx context.Context, agent entity.Agent) error {
+	series := make([]int64, 0, len(agent.Series))
+	for _, hash := range agent.Series {
+		series = append(series, int64(hash))
+	}
+	operation := func() (err error) {
+		tx, err := d.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed begin transaction on save agent: %w", err)
+		}
+		defer func() {
+			if err != nil {
+				if rollbackErr := tx.Rollback(); rollbackErr != nil {
+					logger.Log.Warnf(failedMakeRollbackErrPattern, rollbackErr)
+				}
+				return
+			}
+			if err = tx.Commit(); err != nil {
+				err = fmt.Errorf("failed to commit transaction: %w", err)
+			}
+		}()
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO agents ("id", "hostname", "version", "started_at", "last_seen", "report_interval",
+	"metric_count")
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (id)
+	DO UPDATE SET "hostname" = excluded.hostname, "version" = excluded.version, "started_at" = excluded.started_at,
+	"last_seen" = excluded.last_seen, "report_interval" = excluded.report_interval,
+	"metric_count" = CASE WHEN agents.started_at = excluded.started_at
+		THEN GREATEST(agents.metric_count, excluded.metric_count) ELSE excluded.metric_count END`,
+			agent.ID, agent.Hostname, agent.Version, agent.StartedAt, agent.LastSeen, int64(agent.ReportInterval),
+			int64(agent.MetricCount))
+		if err != nil {
+			return fmt.Errorf("failed save agent %s: %w", agent.ID, err)
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM agent_series WHERE "agent_id" = $1 AND "started_at" <> $2`,
+			agent.ID, agent.StartedAt)
+		if err != nil {
+			return fmt.Errorf("failed delete series of previous run of agent %s: %w", agent.ID, err)
+		}
+		if len(series) == 0 {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, `WITH inserted AS (
+		INSERT INTO agent_series ("agent_id", "series_hash", "started_at")
+		SELECT $1::VARCHAR, unnest($2::BIGINT[]), $3::TIMESTAMPTZ
+		ON CONFLICT ("agent_id", "series_hash") DO NOTHING
+		RETURNING 1)
+	UPDATE agents SET "metric_count" = "metric_count" + (SELECT COUNT(*) FROM inserted) WHERE "id" = $1`,
+			agent.ID, series, agent.StartedAt)
+		if err != nil {
+			return fmt.Errorf("failed save series of agent %s: %w", agent.ID, err)
+		}
+		return nil
+	}
+	return withRetries(operation)
+}
+
+// AllAgents retrieve all registered agents from database sorted by id.
+// MetricCount is count of distinct series saved by current run of agent, Series are not loaded.
+func (d *DatabaseStorage) AllAgents(ctx context.Context) ([]entity.Agent, error) {
+	agents := make([]entity.Agent, 0)
+	operation := func() error {
+		agents = agents[:0]
+		rows, err := d.DB.QueryContext(ctx,
+			`SELECT "id", "hostname", "version", "started_at", "last_seen", "metric_count", "report_interval"
+	FROM agents ORDER BY "id"`)
+		if err != nil {
+			return fmt.Errorf(failedExecuteQueryErrPattern, err)
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			var agent entity.Agent
+			var reportInterval int64
+			if err = rows.Scan(&agent.ID, &agent.Hostname, &agent.Version, &agent.StartedAt, &agent.LastSeen,
+				&agent.MetricCount, &reportInterval); err != nil {
+				return fmt.Errorf(failedScanRowErrPattern, err)
+			}
+			agent.ReportInterval = uint(reportInterval)
+			agents = append(agents, agent)
+		}
+
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf(iterationInRowsErrPattern, err)
+		}
+		return nil
+	}
+
+	if err := withRetries(operation); err != nil {
+		return nil, err
+	}
+
+	return agents, nil
+}
+
 // Ping make test request to database for check connection.
 func (d *DatabaseStorage) Ping() error {
 	if pingErr := d.DB.Ping(); pingErr != nil {
@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/pgx"
@@ -395,6 +397,35 @@ func TestDatabaseStorage_GetByIds(t *testing.T) {
 	}
 }
 
+func TestDatabaseStorage_SaveAgent(t *testing.T) {
+	storage := DatabaseStorage{DB: db}
+	ctx := context.Background()
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	agent := entity.Agent{StartedAt: started, LastSeen: started, ID: "web-1", ReportInterval: 10}
+	defer func() {
+		_, err := db.ExecContext(ctx, `DELETE FROM agents`)
+		require.NoError(t, err)
+	}()
+
+	agent.Series = []uint64{1, 2}
+	require.NoError(t, storage.SaveAgent(ctx, agent))
+	agent.Series = []uint64{2, math.MaxUint64}
+	require.NoError(t, storage.SaveAgent(ctx, agent))
+	agents, err := storage.AllAgents(ctx)
+	require.NoError(t, err)
+	require.Len(t, agents, 1)
+	assert.Equal(t, 3, agents[0].MetricCount, "distinct series of all batches are counted")
+	assert.Equal(t, uint(10), agents[0].ReportInterval)
+
+	agent.StartedAt = started.Add(time.Hour)
+	agent.Series = []uint64{1}
+	require.NoError(t, storage.SaveAgent(ctx, agent))
+	agents, err = storage.AllAgents(ctx)
+	require.NoError(t, err)
+	require.Len(t, agents, 1)
+	assert.Equal(t, 1, agents[0].MetricCount, "series of previous agent run are not counted")
+}
+
 func floatPointer(f float64) *float64 {
 	return &f
 }
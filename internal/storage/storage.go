@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/ilya372317/must-have-metrics/internal/server/entity"
@@ -16,14 +17,18 @@ func (e *AlertNotFoundError) Error() string {
 
 // InMemoryStorage storage representing data in memory.
 type InMemoryStorage struct {
-	Records map[string]entity.Alert `json:"records"`
+	Records     map[string]entity.Alert `json:"records"`
+	Agents      map[string]entity.Agent `json:"agents"`
+	agentSeries map[string]map[uint64]struct{}
 	sync.Mutex
 }
 
 // NewInMemoryStorage constructor for InMemoryStorage.
 func NewInMemoryStorage() *InMemoryStorage {
 	return &InMemoryStorage{
-		Records: make(map[string]entity.Alert, 1000),
+		Records:     make(map[string]entity.Alert, 1000),
+		Agents:      make(map[string]entity.Agent),
+		agentSeries: make(map[string]map[uint64]struct{}),
 	}
 }
 
@@ -124,3 +129,49 @@ func (storage *InMemoryStorage) GetByIDs(_ context.Context, ids []string) ([]ent
 func (storage *InMemoryStorage) Ping() error {
 	return nil
 }
+
+// SaveAgent save agent into registry in memory. Existing agent with same id is replaced,
+// hashes of series reported by same agent run are kept to count distinct series. Count of agent
+// restored from snapshot is kept until its run reports more series, since hashes are not stored.
+func (storage *InMemoryStorage) SaveAgent(_ context.Context, agent entity.Agent) error {
+	storage.Mutex.Lock()
+	defer storage.Mutex.Unlock()
+	if storage.Agents == nil {
+		storage.Agents = make(map[string]entity.Agent)
+	}
+	if storage.agentSeries == nil {
+		storage.agentSeries = make(map[string]map[uint64]struct{})
+	}
+	previous, ok := storage.Agents[agent.ID]
+	sameRun := ok && previous.StartedAt.Equal(agent.StartedAt)
+	series, ok := storage.agentSeries[agent.ID]
+	if !ok || !sameRun {
+		series = make(map[uint64]struct{}, len(agent.Series))
+		storage.agentSeries[agent.ID] = series
+	}
+	for _, hash := range agent.Series {
+		series[hash] = struct{}{}
+	}
+	agent.MetricCount = max(agent.MetricCount, len(series))
+	if sameRun {
+		agent.MetricCount = max(agent.MetricCount, previous.MetricCount)
+	}
+	agent.Series = nil
+	storage.Agents[agent.ID] = agent
+	return nil
+}
+
+// AllAgents retrieve all registered agents from memory sorted by id.
+func (storage *InMemoryStorage) AllAgents(context.Context) ([]entity.Agent, error) {
+	storage.Mutex.Lock()
+	agents := make([]entity.Agent, 0, len(storage.Agents))
+	for _, agent := range storage.Agents {
+		agents = append(agents, agent)
+	}
+	storage.Mutex.Unlock()
+
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].ID < agents[j].ID
+	})
+	return agents, nil
+}
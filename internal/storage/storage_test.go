@@ -263,3 +263,21 @@ func newAlertFromTestAlert(testAlert testAlert) entity.Alert {
 
 	return wantAlert
 }
+
+func TestInMemoryStorage_SaveAgent(t *testing.T) {
+	strg := NewInMemoryStorage()
+	ctx := context.Background()
+	require.NoError(t, strg.SaveAgent(ctx, entity.Agent{ID: "web-2", Series: []uint64{2, 1}}))
+	require.NoError(t, strg.SaveAgent(ctx, entity.Agent{ID: "web-1", Series: []uint64{1}}))
+	require.NoError(t, strg.SaveAgent(ctx, entity.Agent{ID: "web-2", Series: []uint64{3, 1}}))
+	require.NoError(t, strg.SaveAgent(ctx, entity.Agent{ID: "web-3", MetricCount: 5}))
+	require.NoError(t, strg.SaveAgent(ctx, entity.Agent{ID: "web-3", Series: []uint64{1}}))
+
+	agents, err := strg.AllAgents(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Agent{
+		{ID: "web-1", MetricCount: 1},
+		{ID: "web-2", MetricCount: 3},
+		{ID: "web-3", MetricCount: 5},
+	}, agents, "count of restored agent is kept")
+}
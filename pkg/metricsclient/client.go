@@ -0,0 +1,316 @@
+// Package metricsclient client for pushing metrics from Go services to metrics server.
+//
+// Values are accumulated in memory by Counter and Gauge handles and sent to /updates endpoint
+// by batches in background or by explicit Flush. Requests are compatible with server middlewares:
+// body is signed by HMAC, compressed by gzip and encrypted by RSA public key, if they are configured.
+package metricsclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/cmiddleware"
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/promtext"
+	"github.com/ilya372317/must-have-metrics/internal/signature"
+	"github.com/ilya372317/must-have-metrics/internal/utils/compress"
+)
+
+const (
+	// DefaultBatchSize max count of metrics sent by one request.
+	DefaultBatchSize = 50
+	// DefaultFlushInterval interval of background flushing.
+	DefaultFlushInterval = 10 * time.Second
+	// DefaultTimeout timeout of single request.
+	DefaultTimeout = 5 * time.Second
+)
+
+const maxErrorBodySize = 512
+
+// ErrClosed returned by Flush after client is closed.
+var ErrClosed = errors.New("metrics client is closed")
+
+// Option configure Client.
+type Option func(*Client)
+
+// WithSecretKey sign requests by HMAC with given key. Should be the same as server key.
+func WithSecretKey(secretKey string) Option {
+	return func(c *Client) {
+		c.secretKey = secretKey
+	}
+}
+
+// WithPublicKey encrypt requests by given RSA public key.
+func WithPublicKey(publicKey *rsa.PublicKey) Option {
+	return func(c *Client) {
+		c.publicKey = publicKey
+	}
+}
+
+// WithPublicKeyFile encrypt requests by RSA public key from file in PEM format.
+func WithPublicKeyFile(path string) Option {
+	return func(c *Client) {
+		c.publicKeyPath = path
+	}
+}
+
+// WithBatchSize set max count of metrics sent by one request.
+func WithBatchSize(batchSize int) Option {
+	return func(c *Client) {
+		c.batchSize = batchSize
+	}
+}
+
+// WithFlushInterval set interval of background flushing. Zero interval disable background flushing.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.flushInterval = interval
+	}
+}
+
+// WithHTTPClient set http client used for requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithErrorHandler set function, which is called with errors of background flushing.
+func WithErrorHandler(handler func(error)) Option {
+	return func(c *Client) {
+		c.errorHandler = handler
+	}
+}
+
+// Client accumulate metrics and push them to server. Client is safe for concurrent use.
+type Client struct {
+	httpClient    *http.Client
+	publicKey     *rsa.PublicKey
+	errorHandler  func(error)
+	counters      map[string]*Counter
+	gauges        map[string]*Gauge
+	done          chan struct{}
+	stopped       chan struct{}
+	requestURL    string
+	secretKey     string
+	publicKeyPath string
+	batchSize     int
+	flushInterval time.Duration
+	closeOnce     sync.Once
+	closed        bool
+	flushMu       sync.Mutex
+	mu            sync.Mutex
+}
+
+// New constructor for Client. Address is server address in form host:port or URL with scheme.
+// Background flushing is started, Close should be called to stop it and flush remaining metrics.
+func New(address string, opts ...Option) (*Client, error) {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	c := &Client{
+		httpClient:    &http.Client{Timeout: DefaultTimeout},
+		counters:      make(map[string]*Counter),
+		gauges:        make(map[string]*Gauge),
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+		requestURL:    strings.TrimSuffix(address, "/") + "/updates",
+		batchSize:     DefaultBatchSize,
+		flushInterval: DefaultFlushInterval,
+		errorHandler:  func(error) {},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.batchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be positive, got %d", c.batchSize)
+	}
+	if c.flushInterval < 0 {
+		return nil, fmt.Errorf("flush interval must not be negative, got %s", c.flushInterval)
+	}
+	if c.publicKey == nil && c.publicKeyPath != "" {
+		publicKey, err := cmiddleware.LoadPublicKey(c.publicKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed create metrics client: %w", err)
+		}
+		c.publicKey = publicKey
+	}
+
+	go c.run()
+	return c, nil
+}
+
+// Counter return handle of counter with given name and labels. Handles are cached,
+// so the same name and labels give the same handle.
+func (c *Client) Counter(name string, labels map[string]string) *Counter {
+	id := promtext.SeriesID(name, labels)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counter, ok := c.counters[id]
+	if !ok {
+		counter = &Counter{series: newSeries(name, labels)}
+		c.counters[id] = counter
+	}
+	return counter
+}
+
+// Gauge return handle of gauge with given name and labels. Handles are cached,
+// so the same name and labels give the same handle.
+func (c *Client) Gauge(name string, labels map[string]string) *Gauge {
+	id := promtext.SeriesID(name, labels)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	gauge, ok := c.gauges[id]
+	if !ok {
+		gauge = &Gauge{series: newSeries(name, labels)}
+		c.gauges[id] = gauge
+	}
+	return gauge
+}
+
+// Flush send accumulated metrics to server by batches. Metrics of failed batches are kept
+// and sent with next flush.
+func (c *Client) Flush(ctx context.Context) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	return c.flush(ctx)
+}
+
+// Close stop background flushing and flush remaining metrics.
+func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		<-c.stopped
+		c.flushMu.Lock()
+		defer c.flushMu.Unlock()
+		err = c.flush(context.Background())
+		c.closed = true
+	})
+	return err
+}
+
+func (c *Client) run() {
+	defer close(c.stopped)
+	if c.flushInterval == 0 {
+		<-c.done
+		return
+	}
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Flush(context.Background()); err != nil {
+				c.errorHandler(err)
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Client) flush(ctx context.Context) error {
+	pending := c.takePending()
+	var errs []error
+	for len(pending) > 0 {
+		size := min(c.batchSize, len(pending))
+		batch := pending[:size]
+		pending = pending[size:]
+		if err := c.send(ctx, batch); err != nil {
+			for _, p := range batch {
+				p.restore()
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// takePending take changed values of all handles.
+func (c *Client) takePending() []pendingValue {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending := make([]pendingValue, 0, len(c.counters)+len(c.gauges))
+	for _, counter := range c.counters {
+		if p, ok := counter.take(); ok {
+			pending = append(pending, p)
+		}
+	}
+	for _, gauge := range c.gauges {
+		if p, ok := gauge.take(); ok {
+			pending = append(pending, p)
+		}
+	}
+	return pending
+}
+
+func (c *Client) send(ctx context.Context, batch []pendingValue) error {
+	metricsList := make([]dto.Metrics, 0, len(batch))
+	for _, p := range batch {
+		metricsList = append(metricsList, p.metrics)
+	}
+	body, err := json.Marshal(metricsList)
+	if err != nil {
+		return fmt.Errorf("failed serialize metrics: %w", err)
+	}
+
+	request, err := c.newRequest(ctx, body)
+	if err != nil {
+		return err
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed send metrics: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodySize))
+		return fmt.Errorf("server not accepted metrics, status %d: %s", response.StatusCode, bytes.TrimSpace(message))
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+	return nil
+}
+
+// newRequest create request in the same way as agent does: body is signed, compressed and then encrypted.
+func (c *Client) newRequest(ctx context.Context, body []byte) (*http.Request, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("Content-Encoding", "gzip")
+	if c.secretKey != "" {
+		header.Set("HashSHA256", base64.StdEncoding.EncodeToString(signature.CreateSign(body, c.secretKey)))
+	}
+	payload, err := compress.Do(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed compress metrics: %w", err)
+	}
+	if c.publicKey != nil {
+		cipherBody, err := cmiddleware.Encrypt(c.publicKey, payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed encrypt metrics: %w", err)
+		}
+		payload = []byte(cipherBody)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.requestURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed create request: %w", err)
+	}
+	request.Header = header
+	return request, nil
+}
@@ -0,0 +1,221 @@
+package metricsclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/logger"
+	"github.com/ilya372317/must-have-metrics/internal/router"
+	"github.com/ilya372317/must-have-metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKeySize = 2048
+
+func TestMain(m *testing.M) {
+	if err := logger.Init(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestServer start server with router.AlertRouter. Requests count is stored in requests.
+func newTestServer(
+	t *testing.T,
+	serverConfig *config.ServerConfig,
+) (*httptest.Server, *storage.InMemoryStorage, *atomic.Int64) {
+	t.Helper()
+	strg := storage.NewInMemoryStorage()
+	requests := &atomic.Int64{}
+	alertRouter := router.AlertRouter(strg, serverConfig)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		alertRouter.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, strg, requests
+}
+
+func writeKeys(t *testing.T) (privateKeyPath string, publicKeyPath string) {
+	t.Helper()
+	privateKey, err := rsa.GenerateKey(rand.Reader, testKeySize)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	privateKeyPath = filepath.Join(dir, "private-key.pem")
+	publicKeyPath = filepath.Join(dir, "public-key.pem")
+	require.NoError(t, os.WriteFile(privateKeyPath, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}), 0o600))
+	require.NoError(t, os.WriteFile(publicKeyPath, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PUBLIC KEY",
+		Bytes: x509.MarshalPKCS1PublicKey(&privateKey.PublicKey),
+	}), 0o600))
+	return privateKeyPath, publicKeyPath
+}
+
+func TestClient_Flush(t *testing.T) {
+	privateKeyPath, publicKeyPath := writeKeys(t)
+	tests := []struct {
+		serverConfig *config.ServerConfig
+		name         string
+		options      []Option
+		wantErr      bool
+	}{
+		{
+			name:         "plain case",
+			serverConfig: &config.ServerConfig{},
+		},
+		{
+			name:         "signed case",
+			serverConfig: &config.ServerConfig{SecretKey: "secret"},
+			options:      []Option{WithSecretKey("secret")},
+		},
+		{
+			name:         "invalid sign case",
+			serverConfig: &config.ServerConfig{SecretKey: "secret"},
+			options:      []Option{WithSecretKey("other")},
+			wantErr:      true,
+		},
+		{
+			name:         "encrypted and signed case",
+			serverConfig: &config.ServerConfig{SecretKey: "secret", CryptoKey: privateKeyPath},
+			options:      []Option{WithSecretKey("secret"), WithPublicKeyFile(publicKeyPath)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, strg, _ := newTestServer(t, tt.serverConfig)
+			client, err := New(ts.URL, append(tt.options, WithFlushInterval(0))...)
+			require.NoError(t, err)
+			defer func() {
+				_ = client.Close()
+			}()
+
+			requests := client.Counter("requests", map[string]string{"code": "200"})
+			requests.Inc()
+			requests.Add(2)
+			client.Gauge("temperature", nil).Set(36.6)
+			err = client.Flush(context.Background())
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			counter, err := strg.Get(context.Background(), `requests{code="200"}`)
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), *counter.IntValue)
+			gauge, err := strg.Get(context.Background(), "temperature")
+			require.NoError(t, err)
+			assert.Equal(t, 36.6, *gauge.FloatValue)
+		})
+	}
+}
+
+func TestClient_FlushBatches(t *testing.T) {
+	ts, strg, requests := newTestServer(t, &config.ServerConfig{})
+	client, err := New(ts.URL, WithBatchSize(2), WithFlushInterval(0))
+	require.NoError(t, err)
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		client.Counter(name, nil).Inc()
+	}
+	require.NoError(t, client.Flush(context.Background()))
+	assert.Equal(t, int64(3), requests.Load())
+
+	// Unchanged metrics are not sent again.
+	require.NoError(t, client.Flush(context.Background()))
+	assert.Equal(t, int64(3), requests.Load())
+
+	alerts, err := strg.All(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, alerts, 5)
+	require.NoError(t, client.Close())
+}
+
+func TestClient_FlushKeepsFailedMetrics(t *testing.T) {
+	ts, strg, _ := newTestServer(t, &config.ServerConfig{})
+	available := atomic.Bool{}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		ts.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	client, err := New(proxy.URL, WithFlushInterval(0))
+	require.NoError(t, err)
+	counter := client.Counter("PollCount", nil)
+	counter.Add(2)
+	require.Error(t, client.Flush(context.Background()))
+
+	counter.Add(3)
+	available.Store(true)
+	require.NoError(t, client.Close())
+
+	alert, err := strg.Get(context.Background(), "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), *alert.IntValue)
+}
+
+func TestClient_Close(t *testing.T) {
+	ts, strg, _ := newTestServer(t, &config.ServerConfig{})
+	client, err := New(ts.URL)
+	require.NoError(t, err)
+	client.Gauge("Alloc", nil).Set(1.5)
+
+	require.NoError(t, client.Close())
+	require.NoError(t, client.Close())
+	require.ErrorIs(t, client.Flush(context.Background()), ErrClosed)
+
+	alert, err := strg.Get(context.Background(), "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, *alert.FloatValue)
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		wantErr bool
+	}{
+		{name: "default case"},
+		{name: "invalid batch size case", options: []Option{WithBatchSize(0)}, wantErr: true},
+		{name: "missing key case", options: []Option{WithPublicKeyFile("/not/exists.pem")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := New("localhost:8080", tt.options...)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "http://localhost:8080/updates", client.requestURL)
+			require.NoError(t, client.Close())
+		})
+	}
+}
+
+func TestClient_HandlesAreCached(t *testing.T) {
+	client, err := New("localhost:8080", WithFlushInterval(0))
+	require.NoError(t, err)
+	labels := map[string]string{"host": "web-1"}
+	assert.Same(t, client.Counter("requests", labels), client.Counter("requests", labels))
+	assert.NotSame(t, client.Counter("requests", labels), client.Counter("requests", nil))
+	assert.Same(t, client.Gauge("load", nil), client.Gauge("load", nil))
+}
@@ -0,0 +1,69 @@
+package metricsclient_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"time"
+
+	"github.com/ilya372317/must-have-metrics/internal/config"
+	"github.com/ilya372317/must-have-metrics/internal/router"
+	"github.com/ilya372317/must-have-metrics/internal/storage"
+	"github.com/ilya372317/must-have-metrics/pkg/metricsclient"
+)
+
+func Example() {
+	strg := storage.NewInMemoryStorage()
+	ts := httptest.NewServer(router.AlertRouter(strg, &config.ServerConfig{}))
+	defer ts.Close()
+
+	client, err := metricsclient.New(ts.URL, metricsclient.WithFlushInterval(time.Minute))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	requests := client.Counter("requests_total", map[string]string{"handler": "index"})
+	requests.Inc()
+	requests.Inc()
+	client.Gauge("queue_size", nil).Set(12)
+
+	// Close flush accumulated metrics.
+	if err = client.Close(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	counter, _ := strg.Get(context.Background(), `requests_total{handler="index"}`)
+	gauge, _ := strg.Get(context.Background(), "queue_size")
+	fmt.Println(*counter.IntValue, *gauge.FloatValue)
+	// Output:
+	// 2 12
+}
+
+func ExampleClient_Flush() {
+	strg := storage.NewInMemoryStorage()
+	ts := httptest.NewServer(router.AlertRouter(strg, &config.ServerConfig{SecretKey: "secret"}))
+	defer ts.Close()
+
+	client, err := metricsclient.New(ts.URL,
+		metricsclient.WithSecretKey("secret"),
+		metricsclient.WithFlushInterval(0),
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	client.Counter("jobs_done_total", nil).Add(5)
+	if err = client.Flush(context.Background()); err != nil {
+		fmt.Println(err)
+		return
+	}
+	counter, _ := strg.Get(context.Background(), "jobs_done_total")
+	fmt.Println(*counter.IntValue)
+	// Output:
+	// 5
+}
@@ -0,0 +1,81 @@
+package metricsclient
+
+import (
+	"maps"
+	"math"
+	"sync/atomic"
+
+	"github.com/ilya372317/must-have-metrics/internal/dto"
+	"github.com/ilya372317/must-have-metrics/internal/server/entity"
+)
+
+type series struct {
+	labels map[string]string
+	name   string
+}
+
+func newSeries(name string, labels map[string]string) series {
+	return series{name: name, labels: maps.Clone(labels)}
+}
+
+// pendingValue value taken from handle for sending. It is returned back to handle, if sending failed.
+type pendingValue struct {
+	restore func()
+	metrics dto.Metrics
+}
+
+// Counter handle of counter metric. Increments are summed until they are sent to server.
+type Counter struct {
+	series
+	delta atomic.Int64
+}
+
+// Add increase counter by delta.
+func (c *Counter) Add(delta int64) {
+	c.delta.Add(delta)
+}
+
+// Inc increase counter by one.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) take() (pendingValue, bool) {
+	delta := c.delta.Swap(0)
+	if delta == 0 {
+		return pendingValue{}, false
+	}
+	return pendingValue{
+		metrics: dto.Metrics{ID: c.name, MType: entity.TypeCounter, Labels: c.labels, Delta: &delta},
+		restore: func() { c.Add(delta) },
+	}, true
+}
+
+// Gauge handle of gauge metric. Only last set value is sent to server.
+type Gauge struct {
+	series
+	bits  atomic.Uint64
+	dirty atomic.Bool
+}
+
+// Set set gauge value.
+func (g *Gauge) Set(value float64) {
+	g.bits.Store(math.Float64bits(value))
+	g.dirty.Store(true)
+}
+
+// Value return last set value.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+func (g *Gauge) take() (pendingValue, bool) {
+	if !g.dirty.Swap(false) {
+		return pendingValue{}, false
+	}
+	value := g.Value()
+	return pendingValue{
+		metrics: dto.Metrics{ID: g.name, MType: entity.TypeGauge, Labels: g.labels, Value: &value},
+		restore: func() { g.dirty.Store(true) },
+	}, true
+}